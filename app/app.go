@@ -9,17 +9,32 @@
 // @license.name Apache 2.0
 // @license.url http://www.apache.org/licenses/LICENSE-2.0.html
 
+// @securityDefinitions.apikey AdminToken
+// @in header
+// @name Authorization
+// @description Admin token as "Bearer <ADMIN_TOKEN>"
+
 package app
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	// For swagger use
+	"geoai-app/cache"
 	"geoai-app/controller"
 	"geoai-app/docs"
+	"geoai-app/geocode"
+	"geoai-app/history"
+	"geoai-app/llm"
+	"geoai-app/ratelimit"
+	"geoai-app/repository"
+	"geoai-app/tools"
 	"github.com/gin-gonic/gin"
 	"github.com/swaggo/files"
 	"github.com/swaggo/gin-swagger"
@@ -70,10 +85,62 @@ func (a *App) CreateRoutes() {
 		}
 	})
 
+	// Initialize the LLM provider
+	provider, err := a.createProvider()
+	if err != nil {
+		log.Fatalf("Failed to create LLM provider: %v", err)
+	}
+
+	geocoder := a.createGeocoder()
+
+	historyPolicy, err := history.NewPolicy(history.Config{
+		Policy:    HISTORYPOLICY,
+		MaxTokens: HISTORYMAXTOKENS,
+		LastTurns: HISTORYLASTN,
+	}, provider, repository.NewConversationRepository(a.DB))
+	if err != nil {
+		log.Fatalf("Failed to create history policy: %v", err)
+	}
+
+	var titler *history.Titler
+	if AUTOTITLE {
+		titler = &history.Titler{
+			Provider:  provider,
+			Store:     repository.NewConversationRepository(a.DB),
+			Summarize: AUTOSUMMARY,
+		}
+	}
+
 	// Initialize controllers
 	userController := controller.NewUserController(a.DB)
-	conversationController := controller.NewConversationController(a.DB)
-	chatController := controller.NewChatController(a.DB)
+	conversationController := controller.NewConversationController(a.DB, LLMMODELS)
+	chatController := controller.NewChatController(a.DB, controller.ChatConfig{
+		Provider:        provider,
+		Geocoder:        geocoder,
+		Tools:           tools.NewRegistry(tools.GeospatialTools(geocoder)...),
+		MaxToolSteps:    MAXTOOLSTEPS,
+		History:         historyPolicy,
+		MaxPromptTokens: maxPromptTokens(),
+		Models:          LLMMODELS,
+		Cache:           createResponseCache(),
+		DefaultPersona:  DEFAULTPERSONA,
+		Titler:          titler,
+		PendingTimeout:  turnTimeout(),
+	})
+	modelController := controller.NewModelController(LLMMODELS)
+	usageController := controller.NewUsageController(a.DB, LLMPRICES)
+	promptController := controller.NewPromptController(a.DB)
+	searchController := controller.NewSearchController(a.DB)
+
+	// Limit the routes that call the LLM provider
+	rateLimit := ratelimit.Middleware(ratelimit.NewLimiter(ratelimit.Config{
+		User: ratelimit.Quota{
+			RequestsPerMinute: RATELIMITUSERRPM,
+			TokensPerDay:      QUOTATOKENSPERDAY,
+			TokensPerMonth:    QUOTATOKENSPERMONTH,
+		},
+		IPRequestsPerMinute: RATELIMITIPRPM,
+	}, a.createRateLimitStore()))
 
 	// User routes
 	routes.GET("/users", userController.GetUsers)
@@ -81,13 +148,202 @@ func (a *App) CreateRoutes() {
 
 	// Conversation routes
 	routes.GET("/conversations", conversationController.GetConversations)
+	routes.GET("/conversations/:uuid", conversationController.GetConversation)
+	routes.PATCH("/conversations/:uuid", conversationController.UpdateConversation)
+	routes.DELETE("/conversations/:uuid", conversationController.DeleteConversation)
+	routes.POST("/conversations/:uuid/restore", conversationController.RestoreConversation)
+	routes.GET("/conversations/:uuid/messages", conversationController.GetConversationMessages)
+	routes.GET("/conversations/:uuid/locations", conversationController.GetConversationLocations)
+	routes.GET("/conversations/:uuid/export", conversationController.ExportConversation)
+	routes.POST("/conversations/:uuid/retry", rateLimit, chatController.RetryChatRequest)
+	routes.POST("/conversations/:uuid/regenerate", rateLimit, chatController.RegenerateChatRequest)
+	routes.POST("/conversations/:uuid/messages/:id/edit", rateLimit, chatController.EditChatRequest)
+	routes.PUT("/conversations/:uuid/branch", conversationController.SwitchBranch)
+	routes.POST("/conversations/:uuid/fork", conversationController.ForkConversation)
+
+	// Search route
+	routes.GET("/search", searchController.Search)
+
+	// Prompt routes, changes need the admin token
+	admin := controller.RequireAdmin(ADMINTOKEN)
+	routes.GET("/prompts", promptController.GetPrompts)
+	routes.GET("/prompts/:name", promptController.GetPromptVersions)
+	routes.POST("/prompts", admin, promptController.CreatePrompt)
+	routes.PUT("/prompts/:name", admin, promptController.UpdatePrompt)
+	routes.POST("/prompts/:name/rollback", admin, promptController.RollbackPrompt)
+	routes.DELETE("/prompts/:name", admin, promptController.DeletePrompt)
 
-	// Chat route
-	routes.POST("/chat", chatController.HandleChatRequest)
+	// Usage route
+	routes.GET("/usage", usageController.GetUsage)
+
+	// Chat routes
+	routes.GET("/models", modelController.GetModels)
+	routes.POST("/chat", rateLimit, chatController.HandleChatRequest)
 
 	a.Routes = routes
 }
 
+// createProvider sets up the primary LLM provider followed by the
+// LLM_FALLBACKS chain. Each provider gets its own deadlines, retries and
+// circuit breaker.
+func (a *App) createProvider() (llm.Provider, error) {
+	resilience := llm.ResilienceConfig{
+		Timeout:          time.Duration(LLMTIMEOUT) * time.Second,
+		MaxRetries:       LLMMAXRETRIES,
+		BaseDelay:        500 * time.Millisecond,
+		MaxDelay:         10 * time.Second,
+		FailureThreshold: LLMBREAKERTHRESHOLD,
+		Cooldown:         time.Duration(LLMBREAKERCOOLDOWN) * time.Second,
+	}
+
+	primary, err := llm.NewProvider(llm.Config{
+		Provider: LLMPROVIDER,
+		Model:    LLMMODEL,
+		BaseURL:  LLMBASEURL,
+		APIKey:   LLMAPIKEY,
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Using LLM provider %s with model %s", primary.Name(), LLMMODEL)
+
+	// The primary keeps the model chosen by the request
+	targets := []llm.FallbackTarget{{Provider: llm.NewResilientProvider(primary, resilience)}}
+	for _, entry := range strings.Split(LLMFALLBACKS, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		// Split on the first colon only, Ollama model names contain one
+		name, modelName, _ := strings.Cut(entry, ":")
+
+		// Fallbacks on the primary's provider share its endpoint and key
+		cfg := llm.Config{Provider: name, Model: modelName}
+		if name == primary.Name() {
+			cfg.BaseURL = LLMBASEURL
+			cfg.APIKey = LLMAPIKEY
+		} else if name == "groq" {
+			cfg.APIKey = getEnv("GROQ_API_KEY", "")
+		}
+
+		fallback, err := llm.NewProvider(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("fallback %q: %w", entry, err)
+		}
+		log.Printf("Falling back to LLM provider %s with model %s", fallback.Name(), modelName)
+		targets = append(targets, llm.FallbackTarget{Provider: llm.NewResilientProvider(fallback, resilience), Model: modelName})
+	}
+
+	if len(targets) == 1 {
+		return targets[0].Provider, nil
+	}
+	return llm.NewFallbackProvider(targets...), nil
+}
+
+// turnTimeout is the longest a chat turn can take: a history summary, the
+// tool loop and a correction retry, each going through the whole fallback
+// chain with LLM_TIMEOUT per provider, which covers its retries
+func turnTimeout() time.Duration {
+	targets := 1
+	for _, entry := range strings.Split(LLMFALLBACKS, ",") {
+		if strings.TrimSpace(entry) != "" {
+			targets++
+		}
+	}
+	calls := MAXTOOLSTEPS + 2
+	return time.Duration(LLMTIMEOUT) * time.Second * time.Duration(targets*calls)
+}
+
+// maxPromptTokens is the budget of the history policies that have one,
+// which also holds for the tool loop and correction calls of a turn
+func maxPromptTokens() int {
+	switch HISTORYPOLICY {
+	case "", "window", "summary":
+		return HISTORYMAXTOKENS
+	}
+	return 0
+}
+
+// createRateLimitStore sets up where rate limits and token usage are kept
+func (a *App) createRateLimitStore() ratelimit.Store {
+	switch RATELIMITSTORE {
+	case "memory":
+		// Overrides are read once, changes to user_quotas need a restart
+		store := ratelimit.NewMemoryStore()
+		overrides, err := repository.NewRateLimitRepository(a.DB).GetUserQuotas()
+		if err != nil {
+			log.Fatalf("Failed to load user quotas: %v", err)
+		}
+		for _, override := range overrides {
+			store.SetOverride(override)
+		}
+		return store
+	case "postgres":
+		store := ratelimit.NewPostgresStore(repository.NewRateLimitRepository(a.DB))
+		store.StartPruning(context.Background())
+		return store
+	default:
+		log.Fatalf("Unknown RATE_LIMIT_STORE %q", RATELIMITSTORE)
+		return nil
+	}
+}
+
+// createResponseCache sets up the response cache, returning nil when it
+// is off
+func createResponseCache() *cache.Cache {
+	config := cache.Config{
+		TTL:        time.Duration(RESPONSECACHETTL) * time.Second,
+		MaxEntries: RESPONSECACHESIZE,
+	}
+	switch RESPONSECACHE {
+	case "", "off":
+		return nil
+	case "exact":
+	case "similar":
+		config.Matcher = cache.WordMatcher{}
+		config.Threshold = RESPONSECACHETHRESHOLD
+	default:
+		log.Fatalf("Unknown RESPONSE_CACHE %q", RESPONSECACHE)
+	}
+	log.Printf("Using %s response cache", RESPONSECACHE)
+	return cache.New(config)
+}
+
+// createGeocoder sets up the configured gazetteer, returning nil when
+// geocoding is disabled
+func (a *App) createGeocoder() geocode.Geocoder {
+	switch GEOCODER {
+	case "", "none":
+		log.Println("Geocoding disabled, set GAZETTEER_PATH to enable it")
+		return nil
+	case "memory":
+		geocoder, err := geocode.NewMemoryGeocoder(GAZETTEERPATH)
+		if err != nil {
+			log.Fatalf("Failed to load gazetteer: %v", err)
+		}
+		log.Printf("Loaded gazetteer from %s", GAZETTEERPATH)
+		return geocoder
+	case "postgres":
+		repo := repository.NewGazetteerRepository(a.DB)
+		count, err := repo.CountPlaces()
+		if err != nil {
+			log.Fatalf("Failed to read gazetteer: %v", err)
+		}
+		// Import the dump on first start
+		if count == 0 && GAZETTEERPATH != "" {
+			count, err = geocode.ImportGeoNames(repo, GAZETTEERPATH)
+			if err != nil {
+				log.Fatalf("Failed to import gazetteer: %v", err)
+			}
+			log.Printf("Imported %d places from %s", count, GAZETTEERPATH)
+		}
+		return geocode.NewPostgresGeocoder(repo)
+	default:
+		log.Fatalf("Unknown geocoder %q", GEOCODER)
+		return nil
+	}
+}
+
 func (a *App) Run() {
 	if err := a.Routes.Run(":10000"); err != nil {
 		log.Fatalf("Failed to start the server: %v", err)
@@ -3,8 +3,10 @@ package app
 import (
 	"log"
 	"os"
+	"strconv"
 	"strings"
 
+	"geoai-app/model"
 	"github.com/joho/godotenv"
 )
 
@@ -14,6 +16,46 @@ var (
 	HOSTDB  string
 	DBNAME  string
 	DBURL   string
+
+	LLMPROVIDER string
+	LLMMODEL    string
+	LLMBASEURL  string
+	LLMAPIKEY   string
+	LLMMODELS   []model.ModelInfo
+	// LLMFALLBACKS is a comma-separated chain of provider:model pairs
+	LLMFALLBACKS string
+	LLMPRICES    map[string]model.Price
+
+	LLMTIMEOUT          int
+	LLMMAXRETRIES       int
+	LLMBREAKERTHRESHOLD int
+	LLMBREAKERCOOLDOWN  int
+
+	GEOCODER      string
+	GAZETTEERPATH string
+
+	MAXTOOLSTEPS int
+
+	AUTOTITLE   bool
+	AUTOSUMMARY bool
+
+	DEFAULTPERSONA string
+	ADMINTOKEN     string
+
+	HISTORYPOLICY    string
+	HISTORYMAXTOKENS int
+	HISTORYLASTN     int
+
+	RESPONSECACHE          string
+	RESPONSECACHETTL       int
+	RESPONSECACHESIZE      int
+	RESPONSECACHETHRESHOLD float64
+
+	RATELIMITSTORE      string
+	RATELIMITUSERRPM    int
+	RATELIMITIPRPM      int
+	QUOTATOKENSPERDAY   int
+	QUOTATOKENSPERMONTH int
 )
 
 func init() {
@@ -32,12 +74,133 @@ func init() {
 	if DBURL == "" || !strings.Contains(DBURL, "@") {
 		DBURL = constructDBURL(UNAMEDB, PASSDB, HOSTDB, DBNAME)
 	}
+
+	LLMPROVIDER = getEnv("LLM_PROVIDER", "groq")
+	LLMMODEL = getEnv("LLM_MODEL", "llama-3.3-70b-versatile")
+	LLMBASEURL = getEnv("LLM_BASE_URL", "")
+	// Groq deployments only set GROQ_API_KEY
+	LLMAPIKEY = getEnv("LLM_API_KEY", getEnv("GROQ_API_KEY", ""))
+
+	LLMMODELS = parseModels(getEnv("LLM_MODELS", ""), LLMMODEL)
+	LLMFALLBACKS = getEnv("LLM_FALLBACKS", "")
+	LLMPRICES = parsePrices(getEnv("LLM_PRICES", "llama-3.3-70b-versatile:0.59:0.79,llama-3.1-8b-instant:0.05:0.08"))
+
+	// Timeouts and cooldowns are in seconds
+	LLMTIMEOUT = getEnvInt("LLM_TIMEOUT", 120)
+	LLMMAXRETRIES = getEnvInt("LLM_MAX_RETRIES", 3)
+	LLMBREAKERTHRESHOLD = getEnvInt("LLM_BREAKER_THRESHOLD", 5)
+	LLMBREAKERCOOLDOWN = getEnvInt("LLM_BREAKER_COOLDOWN", 30)
+
+	GAZETTEERPATH = getEnv("GAZETTEER_PATH", "")
+	GEOCODER = getEnv("GEOCODER", "")
+	if GEOCODER == "" && GAZETTEERPATH != "" {
+		GEOCODER = "memory"
+	}
+
+	MAXTOOLSTEPS = getEnvInt("MAX_TOOL_STEPS", 5)
+
+	AUTOTITLE = getEnv("AUTO_TITLE", "true") == "true"
+	AUTOSUMMARY = getEnv("AUTO_SUMMARY", "false") == "true"
+
+	DEFAULTPERSONA = getEnv("DEFAULT_PERSONA", "geoai")
+	// Admin endpoints are disabled without a token
+	ADMINTOKEN = getEnv("ADMIN_TOKEN", "")
+
+	HISTORYPOLICY = getEnv("HISTORY_POLICY", "window")
+	HISTORYMAXTOKENS = getEnvInt("HISTORY_MAX_TOKENS", 24000)
+	HISTORYLASTN = getEnvInt("HISTORY_LAST_N", 10)
+
+	RESPONSECACHE = getEnv("RESPONSE_CACHE", "off")
+	RESPONSECACHETTL = getEnvInt("RESPONSE_CACHE_TTL", 3600)
+	RESPONSECACHESIZE = getEnvInt("RESPONSE_CACHE_SIZE", 1000)
+	RESPONSECACHETHRESHOLD = getEnvFloat("RESPONSE_CACHE_SIMILARITY", 0.8)
+
+	// Limits of 0 are disabled
+	RATELIMITSTORE = getEnv("RATE_LIMIT_STORE", "postgres")
+	RATELIMITUSERRPM = getEnvInt("RATE_LIMIT_USER_PER_MINUTE", 20)
+	RATELIMITIPRPM = getEnvInt("RATE_LIMIT_IP_PER_MINUTE", 60)
+	QUOTATOKENSPERDAY = getEnvInt("QUOTA_TOKENS_PER_DAY", 0)
+	QUOTATOKENSPERMONTH = getEnvInt("QUOTA_TOKENS_PER_MONTH", 0)
 }
 
 func constructDBURL(username, password, host, dbname string) string {
 	return "postgres://" + username + ":" + password + "@" + host + "/" + dbname + "?sslmode=disable"
 }
 
+// parseModels reads the model allow-list, a comma-separated list of
+// name[:context_window[:max_output_tokens]] entries. Limits are split off
+// from the right since Ollama model names contain colons, a name tag made
+// of digits only needs its limits given. The default model is always
+// allowed.
+func parseModels(spec, defaultModel string) []model.ModelInfo {
+	var models []model.ModelInfo
+	hasDefault := false
+	for _, entry := range strings.Split(spec, ",") {
+		name := strings.TrimSpace(entry)
+		var limits []int
+		for len(limits) < 2 {
+			rest, last, ok := cutLast(name, ":")
+			limit, err := strconv.Atoi(last)
+			if !ok || err != nil || limit < 0 {
+				break
+			}
+			name = rest
+			limits = append([]int{limit}, limits...)
+		}
+		if name == "" {
+			continue
+		}
+
+		info := model.ModelInfo{Name: name, Default: name == defaultModel}
+		if len(limits) > 0 {
+			info.ContextWindow = limits[0]
+		}
+		if len(limits) > 1 {
+			info.MaxOutputTokens = limits[1]
+		}
+
+		hasDefault = hasDefault || info.Default
+		models = append(models, info)
+	}
+
+	if !hasDefault {
+		models = append([]model.ModelInfo{{Name: defaultModel, Default: true}}, models...)
+	}
+	return models
+}
+
+// parsePrices reads the price table, a comma-separated list of
+// model:input:output entries in USD per million tokens. Prices are split
+// off from the right since Ollama model names contain colons.
+func parsePrices(spec string) map[string]model.Price {
+	prices := make(map[string]model.Price)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		rest, output, _ := cutLast(entry, ":")
+		name, input, ok := cutLast(rest, ":")
+		inputPrice, inputErr := strconv.ParseFloat(input, 64)
+		outputPrice, outputErr := strconv.ParseFloat(output, 64)
+		if !ok || name == "" || inputErr != nil || outputErr != nil {
+			log.Printf("Warning: invalid LLM_PRICES entry %q", entry)
+			continue
+		}
+		prices[name] = model.Price{Input: inputPrice, Output: outputPrice}
+	}
+	return prices
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
 func getEnv(key, defaultValue string) string {
 	value, exists := os.LookupEnv(key)
 	if !exists {
@@ -45,3 +208,29 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Warning: %s=%q is not a number, using %g", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: %s=%q is not a number, using %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
@@ -0,0 +1,31 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	"geoai-app/model"
+)
+
+func TestParseModels(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []model.ModelInfo
+	}{
+		{"", []model.ModelInfo{{Name: "llama3", Default: true}}},
+		{"llama3:8192:2048", []model.ModelInfo{{Name: "llama3", ContextWindow: 8192, MaxOutputTokens: 2048, Default: true}}},
+		{"llama3:8b", []model.ModelInfo{{Name: "llama3", Default: true}, {Name: "llama3:8b"}}},
+		{"llama3:8b:8192", []model.ModelInfo{{Name: "llama3", Default: true}, {Name: "llama3:8b", ContextWindow: 8192}}},
+		{" llama3 , llama3.2:3b:131072:4096 ", []model.ModelInfo{
+			{Name: "llama3", Default: true},
+			{Name: "llama3.2:3b", ContextWindow: 131072, MaxOutputTokens: 4096},
+		}},
+		{"gpt-4o:128000:16384:1", []model.ModelInfo{{Name: "llama3", Default: true}, {Name: "gpt-4o:128000", ContextWindow: 16384, MaxOutputTokens: 1}}},
+		{",,:8192", []model.ModelInfo{{Name: "llama3", Default: true}}},
+	}
+	for _, tt := range tests {
+		if got := parseModels(tt.spec, "llama3"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseModels(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
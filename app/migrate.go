@@ -3,6 +3,7 @@ package app
 import (
 	"log"
 
+	"geoai-app/repository"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	// Import the file source driver for migrations
@@ -37,4 +38,14 @@ func (a *App) Migrate() {
 	}
 
 	log.Println("Migrations applied successfully!")
+
+	// Index assistant replies stored before search by their reply text
+	reindexed, err := repository.NewSearchRepository(a.DB).BackfillSearchText()
+	if err != nil {
+		log.Fatalf("Error reindexing messages for search: %v", err)
+		return
+	}
+	if reindexed > 0 {
+		log.Printf("Reindexed %d messages for search", reindexed)
+	}
 }
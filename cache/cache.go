@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"geoai-app/model"
+)
+
+// Request identifies a cacheable question
+type Request struct {
+	SystemPrompt string
+	Model        string
+	Prompt       string
+	// Params are the encoded sampling parameters
+	Params string
+	// History is the Digest of the turns before Prompt, empty on the first
+	// turn, so follow-ups only match within the same conversation context
+	History string
+}
+
+// Entry is a cached reply
+type Entry struct {
+	Message model.Message
+	Answer  *model.GeoAnswer
+}
+
+// Matcher scores how well the reply to a cached prompt answers a new prompt,
+// from 0 to 1. Prompts are normalized before they are compared.
+type Matcher interface {
+	Similarity(prompt, cached string) float64
+}
+
+// Config bounds the cache
+type Config struct {
+	TTL        time.Duration
+	MaxEntries int
+	// Matcher finds similar prompts when there is no exact match, nil only
+	// serves exact matches
+	Matcher Matcher
+	// Threshold is the lowest similarity served from the cache
+	Threshold float64
+}
+
+type element struct {
+	key       string
+	scope     string
+	prompt    string
+	entry     Entry
+	expiresAt time.Time
+}
+
+// Cache is an in-memory LRU cache of replies keyed on the normalized system
+// prompt, model, user message and sampling parameters
+type Cache struct {
+	mu      sync.Mutex
+	config  Config
+	entries map[string]*list.Element
+	order   *list.List
+	now     func() time.Time
+}
+
+// New creates a new instance of Cache
+func New(config Config) *Cache {
+	return &Cache{
+		config:  config,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// Get returns the cached reply for an exact match or, with a matcher, the
+// most similar prompt at or above the threshold
+func (c *Cache) Get(req Request) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	scope, prompt := keyParts(req)
+	now := c.now()
+
+	if found, ok := c.entries[key(scope, prompt)]; ok {
+		if c.expired(found, now) {
+			return nil, false
+		}
+		c.order.MoveToFront(found)
+		entry := found.Value.(*element).entry
+		return &entry, true
+	}
+	if c.config.Matcher == nil {
+		return nil, false
+	}
+
+	var best *list.Element
+	bestScore := c.config.Threshold
+	for e := c.order.Front(); e != nil; {
+		next := e.Next()
+		candidate := e.Value.(*element)
+		if !c.expired(e, now) && candidate.scope == scope {
+			if score := c.config.Matcher.Similarity(prompt, candidate.prompt); score >= bestScore {
+				best, bestScore = e, score
+			}
+		}
+		e = next
+	}
+	if best == nil {
+		return nil, false
+	}
+	c.order.MoveToFront(best)
+	entry := best.Value.(*element).entry
+	return &entry, true
+}
+
+// Put stores a reply, evicting the least recently used entries beyond
+// MaxEntries
+func (c *Cache) Put(req Request, entry Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	scope, prompt := keyParts(req)
+	k := key(scope, prompt)
+	stored := &element{key: k, scope: scope, prompt: prompt, entry: entry, expiresAt: c.now().Add(c.config.TTL)}
+
+	if found, ok := c.entries[k]; ok {
+		found.Value = stored
+		c.order.MoveToFront(found)
+		return
+	}
+	c.entries[k] = c.order.PushFront(stored)
+
+	for c.config.MaxEntries > 0 && c.order.Len() > c.config.MaxEntries {
+		c.remove(c.order.Back())
+	}
+}
+
+// expired removes the element when its TTL has passed
+func (c *Cache) expired(e *list.Element, now time.Time) bool {
+	if c.config.TTL <= 0 || now.Before(e.Value.(*element).expiresAt) {
+		return false
+	}
+	c.remove(e)
+	return true
+}
+
+func (c *Cache) remove(e *list.Element) {
+	c.order.Remove(e)
+	delete(c.entries, e.Value.(*element).key)
+}
+
+// keyParts returns the normalized scope, everything but the prompt, and the
+// normalized prompt
+func keyParts(req Request) (string, string) {
+	scope := strings.Join([]string{Normalize(req.SystemPrompt), req.Model, req.Params, req.History}, "\x00")
+	return scope, Normalize(req.Prompt)
+}
+
+// Digest identifies a sequence of messages by their roles and content, it
+// is empty for no messages
+func Digest(messages []model.Message) string {
+	if len(messages) == 0 {
+		return ""
+	}
+	hash := sha256.New()
+	for _, message := range messages {
+		hash.Write([]byte(message.Role + "\x00" + message.Content + "\x00"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func key(scope, prompt string) string {
+	sum := sha256.Sum256([]byte(scope + "\x00" + prompt))
+	return hex.EncodeToString(sum[:])
+}
+
+// Normalize lowercases text, drops punctuation and collapses whitespace so
+// trivially different questions share a key
+func Normalize(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"geoai-app/model"
+)
+
+func entry(content string) Entry {
+	return Entry{Message: model.Message{Role: "assistant", Content: content}}
+}
+
+func TestCacheGet(t *testing.T) {
+	base := Request{SystemPrompt: "Be brief.", Model: "llama", Prompt: "Distance from Paris to London?"}
+	with := func(change func(*Request)) Request {
+		req := base
+		change(&req)
+		return req
+	}
+
+	tests := []struct {
+		name    string
+		matcher Matcher
+		req     Request
+		want    string
+	}{
+		{"exact", nil, base, "344 km"},
+		{"normalized", nil, with(func(r *Request) { r.Prompt = "distance from paris to london" }), "344 km"},
+		{"normalized system prompt", nil, with(func(r *Request) { r.SystemPrompt = "be brief" }), "344 km"},
+		{"other model", nil, with(func(r *Request) { r.Model = "gpt" }), ""},
+		{"other parameters", nil, with(func(r *Request) { r.Params = `{"temperature":1}` }), ""},
+		{"other history", nil, with(func(r *Request) { r.History = "abc" }), ""},
+		{"similar without matcher", nil, with(func(r *Request) { r.Prompt = "the distance from Paris to London" }), ""},
+		{"similar", WordMatcher{}, with(func(r *Request) { r.Prompt = "the distance from Paris to London" }), "344 km"},
+		{"reversed route", WordMatcher{}, with(func(r *Request) { r.Prompt = "Distance from London to Paris?" }), ""},
+		{"similar in another scope", WordMatcher{}, with(func(r *Request) { r.Prompt = "the distance from Paris to London"; r.History = "abc" }), ""},
+	}
+	for _, tt := range tests {
+		c := New(Config{Matcher: tt.matcher, Threshold: 0.7})
+		c.Put(base, entry("344 km"))
+
+		got, ok := c.Get(tt.req)
+		if tt.want == "" {
+			if ok {
+				t.Errorf("%s: got %q, want a miss", tt.name, got.Message.Content)
+			}
+			continue
+		}
+		if !ok || got.Message.Content != tt.want {
+			t.Errorf("%s: got %v, %v, want %q", tt.name, got, ok, tt.want)
+		}
+	}
+}
+
+func TestCacheSimilarPicksBestMatch(t *testing.T) {
+	c := New(Config{Matcher: WordMatcher{}, Threshold: 0.3})
+	c.Put(Request{Prompt: "distance from Paris to London"}, entry("Paris-London"))
+	c.Put(Request{Prompt: "distance from Paris to Lyon"}, entry("Paris-Lyon"))
+
+	got, ok := c.Get(Request{Prompt: "the distance from Paris to Lyon"})
+	if !ok || got.Message.Content != "Paris-Lyon" {
+		t.Fatalf("got %v, %v, want the Paris-Lyon reply", got, ok)
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c := New(Config{TTL: time.Minute})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	req := Request{Prompt: "where is Paris"}
+	c.Put(req, entry("France"))
+
+	now = now.Add(59 * time.Second)
+	if _, ok := c.Get(req); !ok {
+		t.Fatal("entry expired before its TTL")
+	}
+	now = now.Add(time.Second)
+	if _, ok := c.Get(req); ok {
+		t.Fatal("entry served after its TTL")
+	}
+	if len(c.entries) != 0 || c.order.Len() != 0 {
+		t.Errorf("expired entry kept: %d entries", c.order.Len())
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New(Config{MaxEntries: 2})
+	paris, rome, oslo := Request{Prompt: "paris"}, Request{Prompt: "rome"}, Request{Prompt: "oslo"}
+	c.Put(paris, entry("France"))
+	c.Put(rome, entry("Italy"))
+	c.Get(paris)
+	c.Put(oslo, entry("Norway"))
+
+	if _, ok := c.Get(rome); ok {
+		t.Error("least recently used entry kept")
+	}
+	for _, req := range []Request{paris, oslo} {
+		if _, ok := c.Get(req); !ok {
+			t.Errorf("%q evicted", req.Prompt)
+		}
+	}
+}
+
+func TestCachePutReplaces(t *testing.T) {
+	c := New(Config{MaxEntries: 2})
+	req := Request{Prompt: "where is Paris"}
+	c.Put(req, entry("France"))
+	c.Put(req, entry("Île-de-France, France"))
+
+	got, ok := c.Get(req)
+	if !ok || got.Message.Content != "Île-de-France, France" || c.order.Len() != 1 {
+		t.Fatalf("got %v, %v with %d entries, want the replaced reply only", got, ok, c.order.Len())
+	}
+}
+
+func TestDigest(t *testing.T) {
+	turn := []model.Message{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello"}}
+	if Digest(nil) != "" {
+		t.Error("digest of no messages is not empty")
+	}
+	if Digest(turn) != Digest(append([]model.Message(nil), turn...)) {
+		t.Error("digest is not stable")
+	}
+	swapped := []model.Message{{Role: "assistant", Content: "hi"}, {Role: "user", Content: "hello"}}
+	if Digest(turn) == Digest(swapped) {
+		t.Error("digest ignores roles")
+	}
+}
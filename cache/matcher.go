@@ -0,0 +1,35 @@
+package cache
+
+import "strings"
+
+// WordMatcher scores prompts by the Dice coefficient of their sets of
+// adjacent word pairs. Pairs keep the word order, so "from Paris to London"
+// does not match "from London to Paris", while small rewordings still
+// share most pairs.
+type WordMatcher struct{}
+
+// Similarity returns twice the shared word pairs over the word pairs of
+// both prompts, after normalizing them
+func (WordMatcher) Similarity(prompt, cached string) float64 {
+	a := wordPairs(prompt)
+	b := wordPairs(cached)
+
+	shared := 0
+	for pair := range a {
+		if b[pair] {
+			shared++
+		}
+	}
+	return float64(2*shared) / float64(len(a)+len(b))
+}
+
+// wordPairs returns the adjacent word pairs of the normalized text, with
+// the start and end marked so first and last words count once more
+func wordPairs(text string) map[[2]string]bool {
+	words := append(append([]string{"\x00"}, strings.Fields(Normalize(text))...), "\x00")
+	pairs := make(map[[2]string]bool)
+	for i := 1; i < len(words); i++ {
+		pairs[[2]string{words[i-1], words[i]}] = true
+	}
+	return pairs
+}
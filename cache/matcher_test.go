@@ -0,0 +1,29 @@
+package cache
+
+import "testing"
+
+func TestWordMatcherSimilarity(t *testing.T) {
+	tests := []struct {
+		name     string
+		prompt   string
+		cached   string
+		min, max float64
+	}{
+		{"identical", "distance from Paris to London", "distance from Paris to London", 1, 1},
+		{"case and punctuation", "Distance from Paris to London?", "distance from paris, to london", 1, 1},
+		{"reversed route", "distance from Paris to London", "distance from London to Paris", 0, 0.4},
+		{"reworded", "what is the distance from Paris to London", "what's the distance from Paris to London", 0.5, 0.9},
+		{"unrelated", "population of Jakarta", "distance from Paris to London", 0, 0},
+		{"empty", "", "", 1, 1},
+		{"one empty", "Paris", "", 0, 0},
+	}
+	for _, tt := range tests {
+		got := WordMatcher{}.Similarity(tt.prompt, tt.cached)
+		if got < tt.min || got > tt.max {
+			t.Errorf("%s: Similarity(%q, %q) = %.2f, want between %.2f and %.2f", tt.name, tt.prompt, tt.cached, got, tt.min, tt.max)
+		}
+		if back := (WordMatcher{}).Similarity(tt.cached, tt.prompt); back != got {
+			t.Errorf("%s: similarity is not symmetric: %.2f and %.2f", tt.name, got, back)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin guards admin routes with a bearer token. Without a token
+// configured the routes are disabled.
+func RequireAdmin(token string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if token == "" {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin endpoints are disabled, set ADMIN_TOKEN to enable them"})
+			return
+		}
+
+		given, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "A valid admin token is required"})
+			return
+		}
+		ctx.Next()
+	}
+}
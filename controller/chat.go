@@ -1,50 +1,117 @@
 package controller
 
 import (
-	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
 	"strconv"
-	"sync"
+	"strings"
+	"time"
 
+	"geoai-app/cache"
+	"geoai-app/geoanswer"
+	"geoai-app/geocode"
+	"geoai-app/geojson"
+	"geoai-app/history"
+	"geoai-app/llm"
 	"geoai-app/model"
+	"geoai-app/ratelimit"
 	"geoai-app/repository"
+	"geoai-app/tools"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
 )
 
-type ChatController struct {
-	SystemPrompt map[string]string
-	ChatHistory  []map[string]string
-	mu           sync.Mutex
-	DB           *sql.DB
+// ChatConfig holds the collaborators of the chat controller
+type ChatConfig struct {
+	Provider llm.Provider
+	// Geocoder resolves answer locations, nil disables geocoding
+	Geocoder geocode.Geocoder
+	// Tools the model may call, nil or empty disables tool calling
+	Tools *tools.Registry
+	// MaxToolSteps caps the model round trips spent on tool calls per turn
+	MaxToolSteps int
+	// History fits the chat history into the model's context window, nil
+	// sends the full history
+	History history.Policy
+	// MaxPromptTokens caps the estimated prompt of every provider call of a
+	// turn, on top of the chosen model's context window. 0 sets no cap.
+	MaxPromptTokens int
+	// Models clients may select, requests naming none use the provider's
+	// default model
+	Models []model.ModelInfo
+	// Cache answers repeated questions without calling the provider, nil
+	// disables it
+	Cache *cache.Cache
+	// DefaultPersona names the prompt of conversations that select none
+	DefaultPersona string
+	// Titler names conversations after their first exchange, nil disables it
+	Titler *history.Titler
+	// PendingTimeout is how long a turn may stay pending before it counts
+	// as abandoned, by a crash or restart, and can be retried. It should
+	// cover every provider call of a turn.
+	PendingTimeout time.Duration
 }
 
-// NewChatController creates a new instance of ChatController
-func NewChatController(db *sql.DB) *ChatController {
-	if err := godotenv.Load(); err != nil {
-		fmt.Println("Warning: No .env file found")
-	}
+// titleTimeout bounds background title generation
+const titleTimeout = 30 * time.Second
+
+// defaultPersonaPrompt is used when the prompts table has no default persona
+const defaultPersonaPrompt = "You are a helpful assistant named GeoAI. Respond concisely to the user's queries."
 
-	systemPrompt := map[string]string{
-		"role": "system",
-		"content": `You are a helpful assistant named GeoAI. Respond concisely to the user's queries in the following format:
+// answerFormat is added to every persona since geoanswer.Parse relies on it
+const answerFormat = `Respond in the following format:
 		{
 		"locations": "comma-separated list of key locations",
 		"messages": "detailed response to the user's query"
-		}`,
-	}
+		}`
+
+// errUnknownPersona is returned for personas missing from the prompts table
+var errUnknownPersona = errors.New("unknown persona")
+
+// ChatController handles chat requests. It holds no per-conversation state,
+// the chat history of each request lives only on its loaded conversation.
+type ChatController struct {
+	ChatConfig
+	DB *sql.DB
+}
 
+// NewChatController creates a new instance of ChatController
+func NewChatController(db *sql.DB, config ChatConfig) *ChatController {
 	return &ChatController{
-		SystemPrompt: systemPrompt,
-		ChatHistory:  []map[string]string{systemPrompt},
-		DB:           db,
+		ChatConfig: config,
+		DB:         db,
+	}
+}
+
+// systemMessage builds the system prompt of a new conversation from the
+// selected persona's active version. It returns the prompt used, or nil for
+// the built-in default.
+func (cc *ChatController) systemMessage(persona string) (model.Message, *model.Prompt, error) {
+	name := persona
+	if name == "" {
+		name = cc.DefaultPersona
+	}
+
+	content := defaultPersonaPrompt
+	prompt, err := newPromptRepository(cc.DB).GetActivePrompt(name)
+	if err != nil {
+		return model.Message{}, nil, err
+	}
+	if prompt != nil {
+		content = prompt.Content
+	} else if persona != "" {
+		return model.Message{}, nil, errUnknownPersona
+	}
+
+	content += "\n" + answerFormat
+	if cc.Tools.Len() > 0 && cc.MaxToolSteps > 0 {
+		content += "\nUse the provided tools for geocoding, distances, bearings and areas instead of estimating them."
 	}
+	return model.Message{Role: "system", Content: content}, prompt, nil
 }
 
 // @Summary Handle chat requests
@@ -52,13 +119,21 @@ func NewChatController(db *sql.DB) *ChatController {
 // @Tags chat
 // @Accept json
 // @Produce json
+// @Produce text/event-stream
+// @Produce application/geo+json
 // @Param user_id query string true "User ID to associate the chat"
 // @Param uuid query string false "UUID of the existing conversation"
+// @Param stream query bool false "Stream the reply as server-sent events"
+// @Param format query string false "Set to geojson to get the answer as a GeoJSON FeatureCollection"
 // @Param requestBody body model.ChatRequest true "Chat request body"
-// @Success 200 {object} map[string]interface{} "Chat response"
+// @Success 200 {object} model.ChatResponse "Chat response"
 // @Failure 400 {object} map[string]interface{} "Bad request"
 // @Failure 404 {object} map[string]interface{} "Conversation not found"
+// @Failure 409 {object} map[string]interface{} "Conversation was updated concurrently"
+// @Failure 429 {object} map[string]interface{} "Rate limit or token quota exceeded"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Failure 503 {object} map[string]interface{} "LLM provider is temporarily unavailable"
+// @Failure 504 {object} map[string]interface{} "LLM provider timed out"
 // @Router /chat [post]
 func (cc *ChatController) HandleChatRequest(c *gin.Context) {
 	userIDStr := c.Query("user_id")
@@ -75,9 +150,15 @@ func (cc *ChatController) HandleChatRequest(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id format"})
 		return
 	}
+	if conversationUUID != "" {
+		if _, err := uuid.Parse(conversationUUID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation uuid"})
+			return
+		}
+	}
 
 	// Check if the user exists
-	userRepo := repository.NewUserRepository(cc.DB)
+	userRepo := newUserRepository(cc.DB)
 	user, err := userRepo.GetUserByID(strconv.Itoa(int(userID)))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking user existence"})
@@ -88,24 +169,54 @@ func (cc *ChatController) HandleChatRequest(c *gin.Context) {
 		return
 	}
 
+	var requestBody model.ChatRequest
+	err = c.ShouldBindJSON(&requestBody)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Settings of a new conversation are checked before it is created, those
+	// of an existing one once merged with its settings
+	if conversationUUID == "" {
+		if err := validateSettings(cc.Models, requestBody.ChatSettings); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// Initialize repositories
-	conversationRepo := repository.NewConversationRepository(cc.DB)
+	conversationRepo := newConversationRepository(cc.DB)
 
 	var conversation *model.Conversation
 
 	if conversationUUID == "" {
+		systemMessage, prompt, err := cc.systemMessage(requestBody.Persona)
+		if errors.Is(err, errUnknownPersona) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown persona, see GET /prompts"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch persona"})
+			return
+		}
+
 		// Create a new conversation
 		conversation = &model.Conversation{
 			UserID:         uint(userID),
 			ConversationID: uuid.New().String(),
-			ChatHistory:    []map[string]string{cc.SystemPrompt},
+			ChatHistory:    []model.Message{systemMessage},
+			Settings:       requestBody.ChatSettings,
+		}
+		if prompt != nil {
+			conversation.PromptID = prompt.ID
+			conversation.Persona = prompt.Name
+			conversation.PromptVersion = prompt.Version
 		}
 		err = conversationRepo.CreateConversation(conversation)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create a new conversation"})
 			return
 		}
-		cc.ChatHistory = conversation.ChatHistory
 	} else {
 		// Continue an existing conversation
 		conversation, err = conversationRepo.GetConversationByUUID(conversationUUID)
@@ -117,106 +228,736 @@ func (cc *ChatController) HandleChatRequest(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
 			return
 		}
-		cc.ChatHistory = conversation.ChatHistory
 	}
 
-	var requestBody model.ChatRequest
-	err = c.ShouldBindJSON(&requestBody)
+	// Request parameters override the conversation settings for this turn
+	settings := conversation.Settings.Merge(requestBody.ChatSettings)
+	if err := validateSettings(cc.Models, settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Save the user input before calling the provider so a failed turn can
+	// be retried without the client sending it again
+	newMessages := []model.Message{{
+		Role:    "user",
+		Content: requestBody.Content,
+		Status:  model.MessagePending,
+	}}
+	err = conversationRepo.AppendMessages(conversation, newMessages)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Conversation was updated by another request, please retry"})
+		return
+	}
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save conversation"})
+		return
+	}
+	userMessage := newMessages[0]
+
+	cc.answerTurn(c, conversationRepo, conversation, settings, &userMessage)
+}
+
+// @Summary Retry a failed chat turn
+// @Description Re-run the last user message of a conversation after its reply failed, or after it was left pending for longer than LLM_TIMEOUT, without sending the content again
+// @Tags chat
+// @Produce json
+// @Produce text/event-stream
+// @Produce application/geo+json
+// @Param uuid path string true "UUID of the conversation"
+// @Param user_id query string true "User ID owning the conversation"
+// @Param stream query bool false "Stream the reply as server-sent events"
+// @Param format query string false "Set to geojson to get the answer as a GeoJSON FeatureCollection"
+// @Success 200 {object} model.ChatResponse "Chat response"
+// @Failure 400 {object} map[string]interface{} "Bad request"
+// @Failure 404 {object} map[string]interface{} "Conversation not found"
+// @Failure 409 {object} map[string]interface{} "No failed turn to retry"
+// @Failure 429 {object} map[string]interface{} "Rate limit or token quota exceeded"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Failure 503 {object} map[string]interface{} "LLM provider is temporarily unavailable"
+// @Failure 504 {object} map[string]interface{} "LLM provider timed out"
+// @Router /conversations/{uuid}/retry [post]
+func (cc *ChatController) RetryChatRequest(c *gin.Context) {
+	conversationRepo := newConversationRepository(cc.DB)
+	conversation, ok := findUserConversation(c, conversationRepo)
+	if !ok {
+		return
+	}
+
+	// Only the last turn can be retried, later turns would lose their context
+	if len(conversation.ChatHistory) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "The conversation has no failed turn to retry"})
+		return
+	}
+	userMessage := conversation.ChatHistory[len(conversation.ChatHistory)-1]
+	if userMessage.Role != "user" || !cc.retryable(userMessage) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The conversation has no failed turn to retry"})
+		return
+	}
+
+	// Claim the turn and the conversation version, a concurrent retry fails
+	// with a conflict
+	err := conversationRepo.ReopenMessage(conversation, &userMessage)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The turn is already being retried"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save conversation"})
+		return
+	}
+
+	cc.answerTurn(c, conversationRepo, conversation, conversation.Settings, &userMessage)
+}
+
+// @Summary Regenerate the last reply
+// @Description Generate an alternative reply to the last user message of the active branch. The previous reply is kept on its own branch.
+// @Tags chat
+// @Produce json
+// @Produce text/event-stream
+// @Produce application/geo+json
+// @Param uuid path string true "UUID of the conversation"
+// @Param user_id query string true "User ID owning the conversation"
+// @Param stream query bool false "Stream the reply as server-sent events"
+// @Param format query string false "Set to geojson to get the answer as a GeoJSON FeatureCollection"
+// @Success 200 {object} model.ChatResponse "Chat response"
+// @Failure 400 {object} map[string]interface{} "Bad request"
+// @Failure 404 {object} map[string]interface{} "Conversation not found"
+// @Failure 409 {object} map[string]interface{} "No reply to regenerate"
+// @Failure 429 {object} map[string]interface{} "Rate limit or token quota exceeded"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Failure 503 {object} map[string]interface{} "LLM provider is temporarily unavailable"
+// @Failure 504 {object} map[string]interface{} "LLM provider timed out"
+// @Router /conversations/{uuid}/regenerate [post]
+func (cc *ChatController) RegenerateChatRequest(c *gin.Context) {
+	conversationRepo := newConversationRepository(cc.DB)
+	conversation, ok := findUserConversation(c, conversationRepo)
+	if !ok {
+		return
+	}
+
+	// Find the user message the last reply answers, unanswered turns are
+	// retried instead
+	var userMessage model.Message
+	if len(conversation.ChatHistory) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "The conversation has no reply to regenerate"})
+		return
+	}
+	if last := conversation.ChatHistory[len(conversation.ChatHistory)-1]; last.Role != "user" {
+		for i := len(conversation.ChatHistory) - 1; i >= 0; i-- {
+			if conversation.ChatHistory[i].Role == "user" {
+				userMessage = conversation.ChatHistory[i]
+				break
+			}
+		}
+	}
+	if userMessage.Status != model.MessageAnswered {
+		c.JSON(http.StatusConflict, gin.H{"error": "The conversation has no reply to regenerate"})
+		return
+	}
+
+	// Claim the turn, a concurrent change fails with a conflict
+	err := conversationRepo.ReopenMessage(conversation, &userMessage)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Conversation was updated by another request, please retry"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save conversation"})
+		return
+	}
+
+	// A cached reply would repeat the previous one
+	settings := conversation.Settings
+	settings.Cache = new(bool)
+	cc.answerTurn(c, conversationRepo, conversation, settings, &userMessage)
+}
+
+// @Summary Edit a user message
+// @Description Send a new version of an earlier user message. It starts a new branch next to the original, which stays available.
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Produce text/event-stream
+// @Produce application/geo+json
+// @Param uuid path string true "UUID of the conversation"
+// @Param id path int true "ID of the user message to edit"
+// @Param user_id query string true "User ID owning the conversation"
+// @Param stream query bool false "Stream the reply as server-sent events"
+// @Param format query string false "Set to geojson to get the answer as a GeoJSON FeatureCollection"
+// @Param requestBody body model.EditMessageRequest true "New message content"
+// @Success 200 {object} model.ChatResponse "Chat response"
+// @Failure 400 {object} map[string]interface{} "Bad request"
+// @Failure 404 {object} map[string]interface{} "Conversation or message not found"
+// @Failure 409 {object} map[string]interface{} "Conversation was updated concurrently"
+// @Failure 429 {object} map[string]interface{} "Rate limit or token quota exceeded"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Failure 503 {object} map[string]interface{} "LLM provider is temporarily unavailable"
+// @Failure 504 {object} map[string]interface{} "LLM provider timed out"
+// @Router /conversations/{uuid}/messages/{id}/edit [post]
+func (cc *ChatController) EditChatRequest(c *gin.Context) {
+	var requestBody model.EditMessageRequest
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Append user input
-	cc.mu.Lock()
-	cc.ChatHistory = append(cc.ChatHistory, map[string]string{
-		"role":    "user",
-		"content": requestBody.Content,
-	})
-	cc.mu.Unlock()
+	conversationRepo := newConversationRepository(cc.DB)
+	conversation, ok := findUserConversation(c, conversationRepo)
+	if !ok {
+		return
+	}
 
-	// Send to Groq API
-	responseMessage, err := sendToGroqAPI(cc.ChatHistory)
+	messageID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message id"})
+		return
+	}
+	branch, err := conversationRepo.GetBranch(conversation, uint(messageID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch response from Groq API"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch message"})
+		return
+	}
+	if len(branch) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+	original := branch[len(branch)-1]
+	if original.Role != "user" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only user messages can be edited"})
 		return
 	}
 
-	cc.mu.Lock()
-	cc.ChatHistory = append(cc.ChatHistory, responseMessage)
-	cc.mu.Unlock()
+	settings := conversation.Settings.Merge(requestBody.ChatSettings)
+	if err := validateSettings(cc.Models, settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Update conversation
-	conversation.ChatHistory = cc.ChatHistory
-	err = conversationRepo.UpdateConversation(conversation)
+	// The edit becomes a sibling of the original message
+	newMessages := []model.Message{{
+		Role:    "user",
+		Content: requestBody.Content,
+		Status:  model.MessagePending,
+	}}
+	err = conversationRepo.BranchMessages(conversation, original.ParentID, newMessages)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Conversation was updated by another request, please retry"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save conversation"})
 		return
 	}
+	userMessage := newMessages[0]
+
+	cc.answerTurn(c, conversationRepo, conversation, settings, &userMessage)
+}
+
+// retryable reports whether a user message can be retried: it failed, or it
+// has been pending for longer than PendingTimeout so the request answering
+// it is gone. Should that request still finish, the retry's claim on the
+// turn keeps it from storing its outcome.
+func (cc *ChatController) retryable(message model.Message) bool {
+	switch message.Status {
+	case model.MessageFailed:
+		return true
+	case model.MessagePending:
+		return cc.PendingTimeout > 0 && time.Since(message.ClaimedAt) > cc.PendingTimeout
+	default:
+		return false
+	}
+}
+
+// answerTurn asks the provider to reply to the pending user message, stores
+// the reply and writes the response. If the provider fails, the user
+// message is marked failed with the error detail.
+func (cc *ChatController) answerTurn(c *gin.Context, conversationRepo repository.ConversationRepositoryInterface, conversation *model.Conversation, settings model.ChatSettings, userMessage *model.Message) {
+	chatHistory := promptHistory(conversation.ChatHistory)
+
+	// Fit the history into the model's context window
+	if cc.History != nil {
+		var err error
+		ctx := history.WithTokenCharge(c.Request.Context(), func(tokens int) { ratelimit.RecordTokens(c, tokens) })
+		ctx = history.WithBudget(ctx, promptBudget(cc.Models, settings))
+		chatHistory, err = cc.History.Apply(ctx, conversation, chatHistory)
+		if err != nil {
+			fmt.Printf("Error applying history policy: %v\n", err)
+			failTurn(conversationRepo, conversation, userMessage, err)
+			status, message := http.StatusInternalServerError, "Failed to prepare conversation history"
+			if errors.Is(err, llm.ErrCircuitOpen) {
+				status, message = providerErrorResponse(err)
+			}
+			c.JSON(status, gin.H{"error": message, "message_id": userMessage.ID})
+			return
+		}
+	}
+
+	// Stream token deltas when the client asks for server-sent events
+	stream := c.Query("stream") == "true" || strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+	cacheRequest, useCache := cc.cacheRequest(conversation, settings, userMessage, stream)
+	if stream {
+		if cc.Cache != nil {
+			c.Header("X-Cache", "BYPASS")
+		}
+		cc.streamChatResponse(c, conversationRepo, conversation, settings, userMessage, chatHistory)
+		return
+	}
+
+	var toolMessages []model.Message
+	var responseMessage *model.Message
+	var answer *model.GeoAnswer
+	start := time.Now()
+	if entry, hit := cc.lookupCache(c, cacheRequest, useCache); hit {
+		responseMessage, answer = cachedMessage(entry, time.Since(start)), entry.Answer
+	} else {
+		// Send to the LLM provider
+		var err error
+		toolMessages, responseMessage, answer, err = cc.requestGeoAnswer(c.Request.Context(), settings, chatHistory)
+		if err != nil {
+			failTurn(conversationRepo, conversation, userMessage, err)
+			status, message := providerErrorResponse(err)
+			c.JSON(status, gin.H{"error": message, "message_id": userMessage.ID})
+			return
+		}
+		if answer != nil {
+			responseMessage.Locations = geocode.ResolveAll(c.Request.Context(), cc.Geocoder, answer.Locations)
+			if useCache {
+				cc.Cache.Put(cacheRequest, cache.Entry{Message: *responseMessage, Answer: answer})
+			}
+		}
+	}
+
+	// Append the reply, including tool calls and results, to the conversation
+	newMessages := append(toolMessages, *responseMessage)
+	err := conversationRepo.AnswerMessage(conversation, userMessage, newMessages)
+	ratelimit.RecordTokens(c, tokensUsed(newMessages))
+	if errors.Is(err, repository.ErrVersionConflict) {
+		failTurn(conversationRepo, conversation, userMessage, err)
+		c.JSON(http.StatusConflict, gin.H{"error": "Conversation was updated by another request, please retry", "message_id": userMessage.ID})
+		return
+	}
+	if err != nil {
+		failTurn(conversationRepo, conversation, userMessage, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save conversation", "message_id": userMessage.ID})
+		return
+	}
+	cc.titleConversation(c, conversation, userMessage, responseMessage, answer)
+
+	if c.Query("format") == "geojson" {
+		c.Header("Content-Type", geojson.ContentType)
+		c.JSON(http.StatusOK, answerFeatureCollection(conversation, newMessages[len(newMessages)-1], answer))
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"response": responseMessage})
+	c.JSON(http.StatusOK, model.ChatResponse{ConversationID: conversation.ConversationID, Response: newMessages[len(newMessages)-1], Answer: answer})
 }
 
-// Helper function to interact with the Groq API
-func sendToGroqAPI(chatHistory []map[string]string) (map[string]string, error) {
-	apiKey := os.Getenv("GROQ_API_KEY")
-	if apiKey == "" {
-		fmt.Println("GROQ_API_KEY is not set")
-		return nil, fmt.Errorf("GROQ_API_KEY not set")
+// streamChatResponse forwards the provider's token deltas as server-sent events
+// and persists the assistant message once the stream ends. If the client
+// disconnects midway, the partial message is saved. Tools are not offered
+// when streaming.
+func (cc *ChatController) streamChatResponse(c *gin.Context, conversationRepo repository.ConversationRepositoryInterface, conversation *model.Conversation, settings model.ChatSettings, userMessage *model.Message, chatHistory []model.Message) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.SSEvent("conversation", gin.H{"conversation_id": conversation.ConversationID, "message_id": userMessage.ID})
+	c.Writer.Flush()
+
+	start := time.Now()
+	chatHistory = cc.fitPrompt(settings, chatHistory)
+	resp, err := cc.Provider.StreamChatCompletion(c.Request.Context(), toLLMRequest(settings, chatHistory, nil), func(delta string) error {
+		c.SSEvent("delta", gin.H{"content": delta})
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Stream from %s provider interrupted: %v\n", cc.Provider.Name(), err)
+	}
+	if err != nil && (resp == nil || resp.Message.Content == "") {
+		failTurn(conversationRepo, conversation, userMessage, err)
+		_, message := providerErrorResponse(err)
+		c.SSEvent("error", gin.H{"error": message, "message_id": userMessage.ID})
+		return
+	}
+
+	responseMessage := toModelMessage(resp, time.Since(start))
+
+	// Streamed replies are parsed as-is since they were already sent
+	answer, parseErr := geoanswer.Parse(responseMessage.Content)
+	if parseErr != nil {
+		fmt.Printf("Invalid geo answer in streamed reply: %v\n", parseErr)
+	} else {
+		responseMessage.Locations = geocode.ResolveAll(c.Request.Context(), cc.Geocoder, answer.Locations)
 	}
 
-	payload := map[string]interface{}{
-		"model":    "llama-3.3-70b-versatile",
-		"messages": chatHistory,
+	// Append the reply, including partial replies from dropped streams
+	newMessages := []model.Message{responseMessage}
+	saveErr := conversationRepo.AnswerMessage(conversation, userMessage, newMessages)
+	ratelimit.RecordTokens(c, tokensUsed(newMessages))
+	if errors.Is(saveErr, repository.ErrVersionConflict) {
+		failTurn(conversationRepo, conversation, userMessage, saveErr)
+		c.SSEvent("error", gin.H{"error": "Conversation was updated by another request, please retry", "message_id": userMessage.ID})
+		return
 	}
+	if saveErr != nil {
+		failTurn(conversationRepo, conversation, userMessage, saveErr)
+		c.SSEvent("error", gin.H{"error": "Failed to save conversation", "message_id": userMessage.ID})
+		return
+	}
+	cc.titleConversation(c, conversation, userMessage, &responseMessage, answer)
 
-	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
-		fmt.Printf("Error marshaling payload: %v\n", err)
-		return nil, err
+		c.SSEvent("error", gin.H{"error": "Response from LLM provider was interrupted"})
+		return
 	}
-	fmt.Printf("Payload: %s\n", string(payloadBytes))
 
-	req, err := http.NewRequest("POST", "https://api.groq.com/openai/v1/chat/completions", bytes.NewReader(payloadBytes))
+	c.SSEvent("done", model.ChatResponse{ConversationID: conversation.ConversationID, Response: newMessages[0], Answer: answer})
+}
+
+// cacheRequest builds the response cache key of a turn. The key covers the
+// earlier turns of the branch, so a follow-up is never answered from another
+// conversation. Turns are not cached when the cache is disabled, the
+// conversation opted out or the reply is streamed.
+func (cc *ChatController) cacheRequest(conversation *model.Conversation, settings model.ChatSettings, userMessage *model.Message, stream bool) (cache.Request, bool) {
+	if cc.Cache == nil || stream || (settings.Cache != nil && !*settings.Cache) {
+		return cache.Request{}, false
+	}
+
+	params, err := json.Marshal(toLLMRequest(settings, nil, nil).Params)
 	if err != nil {
-		fmt.Printf("Error creating HTTP request: %v\n", err)
-		return nil, err
+		return cache.Request{}, false
+	}
+
+	modelName := settings.Model
+	if modelName == "" {
+		for _, info := range cc.Models {
+			if info.Default {
+				modelName = info.Name
+			}
+		}
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	systemPrompt := ""
+	var earlier []model.Message
+	for _, message := range promptHistory(conversation.ChatHistory) {
+		if message.ID == userMessage.ID {
+			break
+		}
+		if message.Role == "system" {
+			systemPrompt = message.Content
+		} else {
+			earlier = append(earlier, message)
+		}
+	}
+
+	return cache.Request{
+		SystemPrompt: systemPrompt,
+		Model:        modelName,
+		Prompt:       userMessage.Content,
+		Params:       string(params),
+		History:      cache.Digest(earlier),
+	}, true
+}
+
+// lookupCache reports the outcome in the X-Cache header: HIT, MISS, or
+// BYPASS when the turn is not cached
+func (cc *ChatController) lookupCache(c *gin.Context, req cache.Request, useCache bool) (*cache.Entry, bool) {
+	if cc.Cache == nil {
+		return nil, false
+	}
+	if !useCache {
+		c.Header("X-Cache", "BYPASS")
+		return nil, false
+	}
+
+	entry, hit := cc.Cache.Get(req)
+	if hit {
+		c.Header("X-Cache", "HIT")
+	} else {
+		c.Header("X-Cache", "MISS")
+	}
+	return entry, hit
+}
+
+// cachedMessage copies a cached reply into a new message. It records the
+// cache as provider and costs no tokens.
+func cachedMessage(entry *cache.Entry, latency time.Duration) *model.Message {
+	return &model.Message{
+		Role:      "assistant",
+		Content:   entry.Message.Content,
+		Model:     entry.Message.Model,
+		Provider:  "cache",
+		LatencyMS: int(latency.Milliseconds()),
+		Locations: entry.Message.Locations,
+	}
+}
+
+// titleConversation generates the title of an untitled conversation in the
+// background once a turn is answered. Its tokens are charged to the user.
+func (cc *ChatController) titleConversation(c *gin.Context, conversation *model.Conversation, userMessage, responseMessage *model.Message, answer *model.GeoAnswer) {
+	if cc.Titler == nil || conversation.Title != "" {
+		return
+	}
+
+	reply := responseMessage.Content
+	if answer != nil {
+		reply = answer.Message
+	}
+	conversationID, question := conversation.ID, userMessage.Content
+	request := c.Copy()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+		defer cancel()
+		ctx = history.WithTokenCharge(ctx, func(tokens int) { ratelimit.RecordTokens(request, tokens) })
+		if err := cc.Titler.Generate(ctx, conversationID, question, reply); err != nil {
+			fmt.Printf("Error saving conversation title: %v\n", err)
+		}
+	}()
+}
+
+// failTurn marks a user message failed so the turn can be retried. The
+// cause is logged, the message keeps a user-safe detail.
+func failTurn(conversationRepo repository.ConversationRepositoryInterface, conversation *model.Conversation, userMessage *model.Message, cause error) {
+	fmt.Printf("Turn %d failed: %v\n", userMessage.ID, cause)
+	err := conversationRepo.UpdateMessageStatus(conversation, userMessage, model.MessageFailed, failureDetail(cause))
 	if err != nil {
-		fmt.Printf("Error making HTTP request: %v\n", err)
-		return nil, err
+		fmt.Printf("Error marking message %d as failed: %v\n", userMessage.ID, err)
+	}
+}
+
+// failureDetails explain failed turns to clients by the class of the
+// provider error. Raw provider errors can carry upstream response bodies
+// and endpoints, so they are only logged.
+var failureDetails = map[llm.ErrorClass]string{
+	llm.ClassRateLimit:     "The LLM provider is rate limiting requests",
+	llm.ClassContextLength: "The conversation is too long for the model's context window",
+	llm.ClassServer:        "The LLM provider returned a server error",
+	llm.ClassUnavailable:   "The LLM provider is unavailable or did not respond in time",
+	llm.ClassClient:        "The LLM provider rejected the request",
+	llm.ClassCanceled:      "The request was canceled before the reply arrived",
+}
+
+// failureDetail returns the user-safe explanation stored on a failed turn
+func failureDetail(cause error) string {
+	var statusErr *llm.StatusError
+	switch {
+	case errors.Is(cause, repository.ErrVersionConflict):
+		return "The conversation was updated by another request"
+	case errors.As(cause, &statusErr), errors.Is(cause, llm.ErrCircuitOpen), errors.Is(cause, context.Canceled), llm.IsRetryable(cause):
+		return failureDetails[llm.Classify(cause)]
+	default:
+		return "The reply could not be generated"
+	}
+}
+
+// tokensUsed sums the prompt and completion tokens of the model replies
+func tokensUsed(messages []model.Message) int {
+	tokens := 0
+	for _, message := range messages {
+		tokens += message.PromptTokens + message.CompletionTokens
+	}
+	return tokens
+}
+
+// promptHistory returns the messages to send to the model, leaving out
+// failed turns that never got a reply. A failed message followed by a reply
+// got it on another attempt, such as a failed regeneration next to it.
+func promptHistory(messages []model.Message) []model.Message {
+	history := make([]model.Message, 0, len(messages))
+	for i, message := range messages {
+		answered := i+1 < len(messages) && messages[i+1].Role != "user"
+		if message.Status != model.MessageFailed || answered {
+			history = append(history, message)
+		}
+	}
+	return history
+}
+
+// providerErrorResponse maps a provider error to a status code and message
+func providerErrorResponse(err error) (int, string) {
+	switch {
+	case errors.Is(err, llm.ErrCircuitOpen):
+		return http.StatusServiceUnavailable, "LLM provider is temporarily unavailable, please retry later"
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "LLM provider did not respond in time"
+	case llm.Classify(err) == llm.ClassRateLimit, llm.Classify(err) == llm.ClassServer:
+		return http.StatusServiceUnavailable, "LLM provider is overloaded, please retry later"
+	default:
+		return http.StatusInternalServerError, "Failed to fetch response from LLM provider"
 	}
-	defer resp.Body.Close()
+}
+
+// answerFeatureCollection returns the answer's locations as GeoJSON with the
+// conversation and message text as foreign members
+func answerFeatureCollection(conversation *model.Conversation, message model.Message, answer *model.GeoAnswer) *geojson.FeatureCollection {
+	collection := geojson.FromMessages(conversation.ConversationID, []model.Message{message})
+	collection.ForeignMembers["message_id"] = message.ID
+	collection.ForeignMembers["message"] = message.Content
+	if answer != nil {
+		collection.ForeignMembers["message"] = answer.Message
+	}
+	return collection
+}
 
-	respBody, err := io.ReadAll(resp.Body)
+// toLLMRequest builds a provider request from the chat settings
+func toLLMRequest(settings model.ChatSettings, chatHistory []model.Message, toolDefinitions []llm.ToolDefinition) llm.Request {
+	return llm.Request{
+		Model:    settings.Model,
+		Messages: toLLMMessages(chatHistory),
+		Tools:    toolDefinitions,
+		Params: llm.Params{
+			Temperature: settings.Temperature,
+			MaxTokens:   settings.MaxTokens,
+			TopP:        settings.TopP,
+			Seed:        settings.Seed,
+			Stop:        settings.Stop,
+		},
+	}
+}
+
+// toLLMMessages converts the stored chat history into provider messages
+func toLLMMessages(chatHistory []model.Message) []llm.Message {
+	messages := make([]llm.Message, 0, len(chatHistory))
+	for _, message := range chatHistory {
+		llmMessage := llm.Message{Role: message.Role, Content: message.Content, ToolCallID: message.ToolCallID}
+		for _, call := range message.ToolCalls {
+			llmMessage.ToolCalls = append(llmMessage.ToolCalls, toLLMToolCall(call))
+		}
+		messages = append(messages, llmMessage)
+	}
+	return messages
+}
+
+func toLLMToolCall(call model.ToolCall) llm.ToolCall {
+	return llm.ToolCall{
+		ID:       call.ID,
+		Type:     "function",
+		Function: llm.FunctionCall{Name: call.Name, Arguments: call.Arguments},
+	}
+}
+
+// toModelMessage converts a provider response into a message to store
+func toModelMessage(resp *llm.Response, latency time.Duration) model.Message {
+	message := model.Message{
+		Role:             "assistant",
+		Content:          resp.Message.Content,
+		Model:            resp.Model,
+		Provider:         resp.Provider,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		LatencyMS:        int(latency.Milliseconds()),
+	}
+	for _, call := range resp.Message.ToolCalls {
+		message.ToolCalls = append(message.ToolCalls, model.ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return message
+}
+
+// requestGeoAnswer runs the tool loop and parses the structured answer of the
+// final reply. An invalid reply gets one corrective retry; if that also fails,
+// the original reply is kept without a structured answer. The tool call and
+// result messages are returned so they can be stored with the turn.
+func (cc *ChatController) requestGeoAnswer(ctx context.Context, settings model.ChatSettings, chatHistory []model.Message) ([]model.Message, *model.Message, *model.GeoAnswer, error) {
+	toolMessages, responseMessage, err := cc.runTools(ctx, settings, chatHistory)
 	if err != nil {
-		fmt.Printf("Error reading response body: %v\n", err)
-		return nil, err
+		return nil, nil, nil, err
 	}
-	fmt.Printf("API Response Status: %d, Body: %s\n", resp.StatusCode, string(respBody))
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Groq API returned status: %d, body: %s", resp.StatusCode, string(respBody))
+	answer, parseErr := geoanswer.Parse(responseMessage.Content)
+	if parseErr == nil {
+		return toolMessages, responseMessage, answer, nil
 	}
+	fmt.Printf("Invalid geo answer, asking for a correction: %v\n", parseErr)
 
-	var apiResponse struct {
-		Choices []struct {
-			Message map[string]string `json:"message"`
-		} `json:"choices"`
+	correction := append(chatHistory[:len(chatHistory):len(chatHistory)], toolMessages...)
+	correction = append(correction, *responseMessage, model.Message{
+		Role:    "user",
+		Content: geoanswer.CorrectionPrompt(parseErr),
+	})
+	corrected, err := sendToProvider(ctx, cc.Provider, settings, cc.fitPrompt(settings, correction), nil)
+	if err != nil {
+		return toolMessages, responseMessage, nil, nil
 	}
-	if err := json.Unmarshal(respBody, &apiResponse); err != nil {
-		fmt.Printf("Error unmarshaling response: %v\n", err)
-		return nil, err
+
+	// Account for both calls on the stored message, whichever is kept
+	answer, parseErr = geoanswer.Parse(corrected.Content)
+	if parseErr != nil {
+		fmt.Printf("Corrected reply is still invalid: %v\n", parseErr)
+		responseMessage.PromptTokens += corrected.PromptTokens
+		responseMessage.CompletionTokens += corrected.CompletionTokens
+		responseMessage.LatencyMS += corrected.LatencyMS
+		return toolMessages, responseMessage, nil, nil
 	}
 
-	if len(apiResponse.Choices) == 0 {
-		return nil, fmt.Errorf("No choices returned in response")
+	corrected.PromptTokens += responseMessage.PromptTokens
+	corrected.CompletionTokens += responseMessage.CompletionTokens
+	corrected.LatencyMS += responseMessage.LatencyMS
+	return toolMessages, corrected, answer, nil
+}
+
+// runTools lets the model call tools until it replies without tool calls or
+// MaxToolSteps is reached, after which a final reply is requested without
+// tools. It returns the tool call and result messages and the final reply.
+func (cc *ChatController) runTools(ctx context.Context, settings model.ChatSettings, chatHistory []model.Message) ([]model.Message, *model.Message, error) {
+	history := chatHistory[:len(chatHistory):len(chatHistory)]
+	var toolMessages []model.Message
+
+	if cc.Tools.Len() > 0 {
+		definitions := cc.Tools.Definitions()
+		for step := 0; step < cc.MaxToolSteps; step++ {
+			reply, err := sendToProvider(ctx, cc.Provider, settings, cc.fitPrompt(settings, history), definitions)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(reply.ToolCalls) == 0 {
+				return toolMessages, reply, nil
+			}
+
+			toolMessages = append(toolMessages, *reply)
+			for _, call := range reply.ToolCalls {
+				toolMessages = append(toolMessages, model.Message{
+					Role:       "tool",
+					Content:    cc.Tools.Call(ctx, toLLMToolCall(call)),
+					ToolCallID: call.ID,
+				})
+			}
+			history = append(chatHistory[:len(chatHistory):len(chatHistory)], toolMessages...)
+		}
+	}
+
+	reply, err := sendToProvider(ctx, cc.Provider, settings, cc.fitPrompt(settings, history), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return toolMessages, reply, nil
+}
+
+// fitPrompt keeps the messages of a provider call within MaxPromptTokens and
+// the context window of the model the settings resolve to
+func (cc *ChatController) fitPrompt(settings model.ChatSettings, messages []model.Message) []model.Message {
+	budget := promptBudget(cc.Models, settings)
+	if cc.MaxPromptTokens > 0 && (budget <= 0 || cc.MaxPromptTokens < budget) {
+		budget = cc.MaxPromptTokens
+	}
+	return history.Fit(messages, budget)
+}
+
+// Helper function to send the chat history to the LLM provider
+func sendToProvider(ctx context.Context, provider llm.Provider, settings model.ChatSettings, chatHistory []model.Message, toolDefinitions []llm.ToolDefinition) (*model.Message, error) {
+	start := time.Now()
+	resp, err := provider.ChatCompletion(ctx, toLLMRequest(settings, chatHistory, toolDefinitions))
+	if err != nil {
+		fmt.Printf("Error from %s provider: %v\n", provider.Name(), err)
+		return nil, err
 	}
 
-	return apiResponse.Choices[0].Message, nil
+	message := toModelMessage(resp, time.Since(start))
+	return &message, nil
 }
@@ -0,0 +1,601 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"geoai-app/cache"
+	"geoai-app/history"
+	"geoai-app/llm"
+	"geoai-app/model"
+	"geoai-app/repository"
+	"geoai-app/tools"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// newChatRouter serves the chat routes from a controller on the fake
+// repositories
+func newChatRouter(t *testing.T, conversations *fakeConversations, config ChatConfig) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	useFakeRepositories(t, conversations)
+
+	cc := NewChatController(nil, config)
+	router := gin.New()
+	router.POST("/chat", cc.HandleChatRequest)
+	router.POST("/conversations/:uuid/retry", cc.RetryChatRequest)
+	router.POST("/conversations/:uuid/regenerate", cc.RegenerateChatRequest)
+	router.POST("/conversations/:uuid/messages/:id/edit", cc.EditChatRequest)
+	return router
+}
+
+// postChat sends a chat turn and decodes the response
+func postChat(router *gin.Engine, userID uint, conversationID, content string) (int, model.ChatResponse) {
+	body, _ := json.Marshal(model.ChatRequest{Content: content})
+	target := fmt.Sprintf("/chat?user_id=%d", userID)
+	if conversationID != "" {
+		target += "&uuid=" + conversationID
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body)))
+
+	var resp model.ChatResponse
+	json.Unmarshal(recorder.Body.Bytes(), &resp)
+	return recorder.Code, resp
+}
+
+func TestParallelChatsKeepHistoriesApart(t *testing.T) {
+	conversations := newFakeConversations()
+	provider := &recordingProvider{Provider: llm.NewFakeProvider("")}
+	router := newChatRouter(t, conversations, ChatConfig{Provider: provider})
+
+	const users, perUser, turns = 4, 3, 4
+	var wg sync.WaitGroup
+	ids := make([][]string, users)
+	errs := make(chan error, users*perUser*turns)
+	for u := 0; u < users; u++ {
+		ids[u] = make([]string, perUser)
+		for c := 0; c < perUser; c++ {
+			wg.Add(1)
+			go func(u, c int) {
+				defer wg.Done()
+				for turn := 0; turn < turns; turn++ {
+					content := fmt.Sprintf("u%d-c%d turn %d", u, c, turn)
+					status, resp := postChat(router, uint(u+1), ids[u][c], content)
+					if status != http.StatusOK {
+						errs <- fmt.Errorf("%s: status %d", content, status)
+						return
+					}
+					if ids[u][c] == "" {
+						ids[u][c] = resp.ConversationID
+					} else if resp.ConversationID != ids[u][c] {
+						errs <- fmt.Errorf("%s: answered in conversation %s", content, resp.ConversationID)
+					}
+					if resp.Answer == nil || resp.Answer.Message != "You said: "+content {
+						errs <- fmt.Errorf("%s: got answer %+v", content, resp.Answer)
+					}
+				}
+			}(u, c)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if t.Failed() {
+		return
+	}
+
+	// Every stored conversation holds its own turns only, in order
+	for u := range ids {
+		for c, id := range ids[u] {
+			conversation := conversations.stored(id)
+			if conversation.UserID != uint(u+1) {
+				t.Errorf("conversation %s belongs to user %d, want %d", id, conversation.UserID, u+1)
+			}
+			history := conversation.ChatHistory
+			if len(history) != 1+2*turns {
+				t.Fatalf("conversation %s has %d messages, want %d", id, len(history), 1+2*turns)
+			}
+			for turn := 0; turn < turns; turn++ {
+				question := history[1+2*turn]
+				if want := fmt.Sprintf("u%d-c%d turn %d", u, c, turn); question.Content != want || question.Status != model.MessageAnswered {
+					t.Errorf("conversation %s turn %d: %q (%s), want %q answered", id, turn, question.Content, question.Status, want)
+				}
+			}
+		}
+	}
+
+	// Every prompt sent to the provider carries a single conversation
+	for _, req := range provider.requests {
+		owner := ""
+		for _, message := range req.Messages {
+			if message.Role != "user" {
+				continue
+			}
+			prefix, _, _ := strings.Cut(message.Content, " ")
+			if owner == "" {
+				owner = prefix
+			} else if prefix != owner {
+				t.Fatalf("prompt mixes %s and %s: %+v", owner, prefix, req.Messages)
+			}
+		}
+	}
+	if got, want := len(provider.requests), users*perUser*turns; got != want {
+		t.Errorf("provider got %d requests, want %d", got, want)
+	}
+}
+
+// gatedProvider holds the calls of prompts starting with prefix until
+// release is closed
+type gatedProvider struct {
+	*recordingProvider
+	prefix  string
+	held    chan struct{}
+	release chan struct{}
+}
+
+func (p gatedProvider) ChatCompletion(ctx context.Context, req llm.Request) (*llm.Response, error) {
+	if strings.HasPrefix(req.Messages[len(req.Messages)-1].Content, p.prefix) {
+		p.held <- struct{}{}
+		<-p.release
+	}
+	return p.recordingProvider.ChatCompletion(ctx, req)
+}
+
+func TestTurnKeepsItsHistoryWhileAnotherRuns(t *testing.T) {
+	conversations := newFakeConversations()
+	provider := gatedProvider{
+		recordingProvider: &recordingProvider{Provider: llm.NewFakeProvider("")},
+		prefix:            "alice asks",
+		held:              make(chan struct{}, 1),
+		release:           make(chan struct{}),
+	}
+	router := newChatRouter(t, conversations, ChatConfig{Provider: provider})
+
+	_, bob := postChat(router, 2, "", "bob: where is Oslo?")
+	_, alice := postChat(router, 1, "", "alice: where is Lima?")
+
+	// Alice's turn has loaded its history and waits for the provider while
+	// Bob's turn runs from start to finish
+	done := make(chan int)
+	go func() {
+		status, _ := postChat(router, 1, alice.ConversationID, "alice asks: how far is Cusco?")
+		done <- status
+	}()
+	<-provider.held
+	if status, _ := postChat(router, 2, bob.ConversationID, "bob: how far is Bergen?"); status != http.StatusOK {
+		t.Fatalf("bob's turn returned %d", status)
+	}
+	close(provider.release)
+	if status := <-done; status != http.StatusOK {
+		t.Fatalf("alice's turn returned %d", status)
+	}
+
+	var prompt []llm.Message
+	for _, req := range provider.requests {
+		if last := req.Messages[len(req.Messages)-1]; last.Content == "alice asks: how far is Cusco?" {
+			prompt = req.Messages
+		}
+	}
+	var questions []string
+	for _, message := range prompt {
+		if message.Role == "user" {
+			questions = append(questions, message.Content)
+		}
+	}
+	if want := []string{"alice: where is Lima?", "alice asks: how far is Cusco?"}; !reflect.DeepEqual(questions, want) {
+		t.Errorf("alice's prompt asked %q, want %q", questions, want)
+	}
+
+	for id, want := range map[string]string{alice.ConversationID: "alice", bob.ConversationID: "bob"} {
+		for _, message := range conversations.stored(id).ChatHistory {
+			if message.Role == "user" && !strings.HasPrefix(message.Content, want) {
+				t.Errorf("%s's conversation holds %q", want, message.Content)
+			}
+		}
+	}
+}
+
+func TestChatRejectsStaleVersion(t *testing.T) {
+	conversations := newFakeConversations()
+	router := newChatRouter(t, conversations, ChatConfig{Provider: llm.NewFakeProvider("")})
+
+	status, resp := postChat(router, 1, "", "first")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	id := resp.ConversationID
+
+	// Another request writes between loading and appending
+	conversations.afterLoad = func(uuid string) {
+		conversations.afterLoad = nil
+		conversations.mu.Lock()
+		conversations.conversations[uuid].Version++
+		conversations.mu.Unlock()
+	}
+	if status, _ := postChat(router, 1, id, "stale"); status != http.StatusConflict {
+		t.Fatalf("stale write: status = %d, want 409", status)
+	}
+	if history := conversations.stored(id).ChatHistory; len(history) != 3 {
+		t.Fatalf("stale write stored messages: %d, want 3", len(history))
+	}
+
+	if status, _ := postChat(router, 1, id, "fresh"); status != http.StatusOK {
+		t.Fatalf("reloaded write: status = %d, want 200", status)
+	}
+}
+
+func TestConcurrentTurnsOnOneConversation(t *testing.T) {
+	conversations := newFakeConversations()
+	router := newChatRouter(t, conversations, ChatConfig{Provider: llm.NewFakeProvider("")})
+
+	_, resp := postChat(router, 1, "", "first")
+	id := resp.ConversationID
+
+	// All requests load the same version before any of them writes
+	const requests = 5
+	var loaded sync.WaitGroup
+	loaded.Add(requests)
+	conversations.afterLoad = func(string) {
+		loaded.Done()
+		loaded.Wait()
+	}
+
+	statuses := make(chan int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			status, _ := postChat(router, 1, id, fmt.Sprintf("parallel %d", i))
+			statuses <- status
+		}(i)
+	}
+	wg.Wait()
+	close(statuses)
+
+	count := map[int]int{}
+	for status := range statuses {
+		count[status]++
+	}
+	if count[http.StatusOK] != 1 || count[http.StatusConflict] != requests-1 {
+		t.Fatalf("statuses = %v, want one 200 and %d 409", count, requests-1)
+	}
+	if history := conversations.stored(id).ChatHistory; len(history) != 5 {
+		t.Fatalf("conversation has %d messages, want 5", len(history))
+	}
+}
+
+func TestChatHidesOtherUsersConversations(t *testing.T) {
+	conversations := newFakeConversations()
+	router := newChatRouter(t, conversations, ChatConfig{Provider: llm.NewFakeProvider("")})
+
+	_, resp := postChat(router, 1, "", "mine")
+	if status, _ := postChat(router, 2, resp.ConversationID, "theirs"); status != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", status)
+	}
+	if history := conversations.stored(resp.ConversationID).ChatHistory; len(history) != 3 {
+		t.Fatalf("conversation has %d messages, want 3", len(history))
+	}
+}
+
+func TestProviderErrorResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"circuit open", llm.ErrCircuitOpen, http.StatusServiceUnavailable},
+		{"deadline", fmt.Errorf("making HTTP request: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{"rate limited", &llm.StatusError{StatusCode: http.StatusTooManyRequests}, http.StatusServiceUnavailable},
+		{"server error", &llm.StatusError{StatusCode: http.StatusServiceUnavailable}, http.StatusServiceUnavailable},
+		{"client error", &llm.StatusError{StatusCode: http.StatusBadRequest}, http.StatusInternalServerError},
+		{"other", errors.New("no choices returned in response"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got, _ := providerErrorResponse(tt.err); got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryAndRegenerateTurns(t *testing.T) {
+	conversations := newFakeConversations()
+	router := newChatRouter(t, conversations, ChatConfig{Provider: llm.NewFakeProvider(""), PendingTimeout: time.Minute})
+
+	// setup stores a conversation whose last turn has the given status and age
+	setup := func(messages []model.Message, status string, age time.Duration) string {
+		conversation := &model.Conversation{UserID: 1, ConversationID: uuid.New().String(), ChatHistory: messages}
+		conversations.CreateConversation(conversation)
+		stored := conversations.conversations[conversation.ConversationID]
+		if n := len(stored.Messages); n > 0 {
+			stored.Messages[n-1].Status = status
+			stored.Messages[n-1].ClaimedAt = time.Now().Add(-age)
+		}
+		return conversation.ConversationID
+	}
+	turn := []model.Message{{Role: "system", Content: "be brief"}, {Role: "user", Content: "where is Paris?"}}
+	post := func(action, id string) int {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/conversations/"+id+"/"+action+"?user_id=1", nil))
+		return recorder.Code
+	}
+
+	tests := []struct {
+		name   string
+		id     string
+		action string
+		want   int
+	}{
+		{"retry an empty branch", setup(nil, "", 0), "retry", http.StatusConflict},
+		{"regenerate an empty branch", setup(nil, "", 0), "regenerate", http.StatusConflict},
+		{"retry a failed turn", setup(turn, model.MessageFailed, 0), "retry", http.StatusOK},
+		{"retry a turn still in progress", setup(turn, model.MessagePending, time.Second), "retry", http.StatusConflict},
+		{"retry an abandoned turn", setup(turn, model.MessagePending, time.Hour), "retry", http.StatusOK},
+		{"retry an answered turn", setup(turn, model.MessageAnswered, 0), "retry", http.StatusConflict},
+		{"regenerate an unanswered turn", setup(turn, model.MessageFailed, 0), "regenerate", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		if got := post(tt.action, tt.id); got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+		if tt.want == http.StatusOK {
+			history := conversations.stored(tt.id).ChatHistory
+			if len(history) != 3 || history[1].Status != model.MessageAnswered {
+				t.Errorf("%s: turn not answered: %+v", tt.name, history)
+			}
+		}
+	}
+}
+
+func TestEditStartsBranch(t *testing.T) {
+	conversations := newFakeConversations()
+	router := newChatRouter(t, conversations, ChatConfig{Provider: llm.NewFakeProvider("")})
+
+	_, first := postChat(router, 1, "", "Where is Paris?")
+	postChat(router, 1, first.ConversationID, "And Lyon?")
+	question := conversations.stored(first.ConversationID).ChatHistory[1]
+
+	edit := func(messageID uint) int {
+		body, _ := json.Marshal(model.EditMessageRequest{Content: "Where is Rome?"})
+		target := fmt.Sprintf("/conversations/%s/messages/%d/edit?user_id=1", first.ConversationID, messageID)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body)))
+		return recorder.Code
+	}
+	if status := edit(question.ID + 1000); status != http.StatusNotFound {
+		t.Errorf("editing an unknown message returned %d, want 404", status)
+	}
+	if status := edit(question.ID + 1); status != http.StatusBadRequest {
+		t.Errorf("editing a reply returned %d, want 400", status)
+	}
+	if status := edit(question.ID); status != http.StatusOK {
+		t.Fatalf("edit returned %d", status)
+	}
+
+	history := conversations.stored(first.ConversationID).ChatHistory
+	if len(history) != 3 || history[1].Content != "Where is Rome?" || history[2].Content != `{"locations":"","messages":"You said: Where is Rome?"}` {
+		t.Fatalf("active branch after edit: %+v", history)
+	}
+	if want := []uint{question.ID, history[1].ID}; !reflect.DeepEqual(history[1].SiblingIDs, want) {
+		t.Errorf("edit has siblings %v, want %v", history[1].SiblingIDs, want)
+	}
+}
+
+// stalledProvider holds its first call until release and fails it, later
+// calls are answered by the fake provider
+type stalledProvider struct {
+	llm.Provider
+	once    sync.Once
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *stalledProvider) ChatCompletion(ctx context.Context, req llm.Request) (*llm.Response, error) {
+	first := false
+	p.once.Do(func() { first = true })
+	if !first {
+		return p.Provider.ChatCompletion(ctx, req)
+	}
+	close(p.started)
+	<-p.release
+	return nil, &llm.StatusError{Provider: "test", StatusCode: http.StatusBadGateway}
+}
+
+func TestRetriedTurnOutlivesAbandonedRequest(t *testing.T) {
+	conversations := newFakeConversations()
+	provider := &stalledProvider{Provider: llm.NewFakeProvider(""), started: make(chan struct{}), release: make(chan struct{})}
+	router := newChatRouter(t, conversations, ChatConfig{Provider: provider, PendingTimeout: time.Minute})
+
+	done := make(chan int)
+	go func() {
+		status, _ := postChat(router, 1, "", "where is Paris?")
+		done <- status
+	}()
+	<-provider.started
+
+	// The first request looks abandoned and its turn is retried
+	var id string
+	conversations.mu.Lock()
+	for uuid, stored := range conversations.conversations {
+		id = uuid
+		stored.Messages[len(stored.Messages)-1].ClaimedAt = time.Now().Add(-time.Hour)
+	}
+	conversations.mu.Unlock()
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/conversations/"+id+"/retry?user_id=1", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("retry status = %d, want 200: %s", recorder.Code, recorder.Body)
+	}
+
+	// The first request failing late leaves the retried turn answered
+	close(provider.release)
+	if status := <-done; status != http.StatusServiceUnavailable {
+		t.Errorf("first request status = %d, want 503", status)
+	}
+	history := conversations.stored(id).ChatHistory
+	if len(history) != 3 || history[1].Status != model.MessageAnswered {
+		t.Fatalf("retried turn overwritten by the abandoned request: %+v", history)
+	}
+}
+
+func TestCacheRequestCoversHistory(t *testing.T) {
+	cc := NewChatController(nil, ChatConfig{Cache: cache.New(cache.Config{})})
+	conversation := func(turns ...string) (*model.Conversation, *model.Message) {
+		c := &model.Conversation{ChatHistory: []model.Message{{ID: 1, Role: "system", Content: "be brief"}}}
+		for i, content := range turns {
+			role := "user"
+			if i%2 == 1 {
+				role = "assistant"
+			}
+			c.ChatHistory = append(c.ChatHistory, model.Message{ID: uint(i + 2), Role: role, Content: content})
+		}
+		return c, &c.ChatHistory[len(c.ChatHistory)-1]
+	}
+	key := func(c *model.Conversation, question *model.Message, settings model.ChatSettings) (cache.Request, bool) {
+		return cc.cacheRequest(c, settings, question, false)
+	}
+
+	paris, parisQuestion := conversation("Where is Paris?", "In France", "How far is it from here?")
+	rome, romeQuestion := conversation("Where is Rome?", "In Italy", "How far is it from here?")
+	parisKey, _ := key(paris, parisQuestion, model.ChatSettings{})
+	romeKey, _ := key(rome, romeQuestion, model.ChatSettings{})
+	if parisKey == romeKey {
+		t.Fatalf("follow-ups of different conversations share the key %+v", parisKey)
+	}
+
+	first, firstQuestion := conversation("Where is Paris?")
+	again, againQuestion := conversation("Where is Paris?")
+	firstKey, _ := key(first, firstQuestion, model.ChatSettings{})
+	againKey, _ := key(again, againQuestion, model.ChatSettings{})
+	if firstKey != againKey || firstKey.History != "" {
+		t.Fatalf("first turns differ: %+v and %+v", firstKey, againKey)
+	}
+
+	if _, ok := key(paris, parisQuestion, model.ChatSettings{Cache: new(bool)}); ok {
+		t.Fatal("opted-out conversation is cached")
+	}
+}
+
+// toolCallingProvider asks for the lookup tool until it has a result
+type toolCallingProvider struct {
+	*recordingProvider
+}
+
+func (p toolCallingProvider) ChatCompletion(ctx context.Context, req llm.Request) (*llm.Response, error) {
+	last := req.Messages[len(req.Messages)-1]
+	if len(req.Tools) == 0 || last.Role == "tool" {
+		return p.recordingProvider.ChatCompletion(ctx, req)
+	}
+	p.mu.Lock()
+	p.requests = append(p.requests, req)
+	p.mu.Unlock()
+	return &llm.Response{Message: llm.Message{Role: "assistant", ToolCalls: []llm.ToolCall{{
+		ID:       "call-1",
+		Type:     "function",
+		Function: llm.FunctionCall{Name: "lookup", Arguments: "{}"},
+	}}}}, nil
+}
+
+func TestToolResultsAreFittedToBudget(t *testing.T) {
+	provider := toolCallingProvider{&recordingProvider{Provider: llm.NewFakeProvider("")}}
+	lookup := tools.Tool{
+		Name:       "lookup",
+		Parameters: json.RawMessage(`{"type":"object"}`),
+		Call: func(context.Context, json.RawMessage) (interface{}, error) {
+			return strings.Repeat("x", 20000), nil
+		},
+	}
+	router := newChatRouter(t, newFakeConversations(), ChatConfig{
+		Provider:        provider,
+		Tools:           tools.NewRegistry(lookup),
+		MaxToolSteps:    2,
+		MaxPromptTokens: 1000,
+	})
+
+	status, resp := postChat(router, 1, "", "Where is Paris?")
+	if status != http.StatusOK {
+		t.Fatalf("chat returned %d", status)
+	}
+	if len(provider.requests) != 2 {
+		t.Fatalf("provider got %d requests, want 2", len(provider.requests))
+	}
+
+	// The tool result is stored whole but shortened in the next prompt
+	messages := provider.requests[1].Messages
+	result := messages[len(messages)-1]
+	if result.Role != "tool" || !strings.HasSuffix(result.Content, "[truncated]") {
+		t.Fatalf("last prompt message is %s %.40q, want a shortened tool result", result.Role, result.Content)
+	}
+	tokens := 0
+	for _, message := range messages {
+		tokens += history.EstimateTokens(model.Message{Role: message.Role, Content: message.Content})
+	}
+	if tokens > 1000 {
+		t.Errorf("prompt estimated at %d tokens, want at most 1000", tokens)
+	}
+	if messages[len(messages)-3].Content != "Where is Paris?" {
+		t.Errorf("prompt lost the question: %+v", messages[len(messages)-3])
+	}
+	if resp.Answer == nil {
+		t.Error("no answer after the tool call")
+	}
+}
+
+func TestMalformedConversationUUID(t *testing.T) {
+	router := newChatRouter(t, newFakeConversations(), ChatConfig{Provider: llm.NewFakeProvider("")})
+
+	if status, _ := postChat(router, 1, "not-a-uuid", "Where is Paris?"); status != http.StatusBadRequest {
+		t.Errorf("chat in a malformed conversation returned %d, want 400", status)
+	}
+	if status, _ := postChat(router, 1, uuid.New().String(), "Where is Paris?"); status != http.StatusNotFound {
+		t.Errorf("chat in an unknown conversation returned %d, want 404", status)
+	}
+
+	for _, target := range []string{"/conversations/not-a-uuid/retry?user_id=1", "/conversations/1'--/regenerate?user_id=1"} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, target, nil))
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s returned %d, want 400", target, recorder.Code)
+		}
+	}
+}
+
+func TestFailureDetail(t *testing.T) {
+	upstream := &llm.StatusError{Provider: "openai", StatusCode: http.StatusBadGateway, Body: `{"error":"upstream https://internal.example/v1 failed"}`}
+	tests := []struct {
+		cause error
+		want  string
+	}{
+		{upstream, "The LLM provider returned a server error"},
+		{fmt.Errorf("summarizing history: %w", upstream), "The LLM provider returned a server error"},
+		{&llm.StatusError{StatusCode: http.StatusTooManyRequests}, "The LLM provider is rate limiting requests"},
+		{&llm.StatusError{StatusCode: http.StatusBadRequest, Body: "maximum context length is 8192 tokens"}, "The conversation is too long for the model's context window"},
+		{&llm.StatusError{StatusCode: http.StatusUnauthorized, Body: "invalid key sk-123"}, "The LLM provider rejected the request"},
+		{llm.ErrCircuitOpen, "The LLM provider is unavailable or did not respond in time"},
+		{context.DeadlineExceeded, "The LLM provider is unavailable or did not respond in time"},
+		{context.Canceled, "The request was canceled before the reply arrived"},
+		{repository.ErrVersionConflict, "The conversation was updated by another request"},
+		{errors.New(`pq: relation "messages" does not exist`), "The reply could not be generated"},
+	}
+	for _, tt := range tests {
+		if got := failureDetail(tt.cause); got != tt.want {
+			t.Errorf("failureDetail(%v) = %q, want %q", tt.cause, got, tt.want)
+		}
+	}
+}
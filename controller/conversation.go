@@ -2,29 +2,47 @@ package controller
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"strconv"
 
-	// "geoai-app/model"
+	"geoai-app/export"
+	"geoai-app/geojson"
+	"geoai-app/model"
 	"geoai-app/repository"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type ConversationController struct {
 	DB *sql.DB
+	// Models conversation settings may select
+	Models []model.ModelInfo
 }
 
-func NewConversationController(db *sql.DB) *ConversationController {
-	return &ConversationController{DB: db}
+func NewConversationController(db *sql.DB, models []model.ModelInfo) *ConversationController {
+	return &ConversationController{DB: db, Models: models}
 }
 
 // @Summary List conversations
-// @Description List all conversations for a user
+// @Description List a page of the conversations of a user with their titles and summaries, without the chat history
 // @Tags conversations
 // @Produce json
 // @Param user_id query string true "User ID to fetch conversations"
-// @Success 200 {object} map[string]interface{} "List of conversations"
+// @Param limit query int false "Conversations per page, at most 100" default(20)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Sort by created_at or updated_at" default(updated_at)
+// @Param order query string false "asc or desc" default(desc)
+// @Param from query string false "Earliest sort time, YYYY-MM-DD or RFC 3339"
+// @Param to query string false "Latest sort time, YYYY-MM-DD (inclusive) or RFC 3339"
+// @Param archived query bool false "List the archived conversations instead"
+// @Param pinned query bool false "Only pinned or only unpinned conversations"
+// @Param tag query string false "Only conversations with this tag"
+// @Param has_locations query bool false "Only conversations with or without geocoded locations"
+// @Success 200 {object} model.ConversationList "Page of conversations, empty when there are none"
 // @Failure 400 {object} map[string]interface{} "Invalid request"
-// @Failure 404 {object} map[string]interface{} "No conversations found"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /conversations [get]
 func (cc *ConversationController) GetConversations(ctx *gin.Context) {
@@ -34,17 +52,422 @@ func (cc *ConversationController) GetConversations(ctx *gin.Context) {
 		return
 	}
 
-	repo := repository.NewConversationRepository(cc.DB)
-	conversations, err := repo.GetConversationsByUserID(userID)
+	page, err := parsePage(ctx, "updated_at", "desc")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter := repository.ConversationFilter{Archived: ctx.Query("archived") == "true", Tag: ctx.Query("tag")}
+	if filter.Pinned, err = parseOptionalBool(ctx, "pinned"); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.HasLocations, err = parseOptionalBool(ctx, "has_locations"); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	repo := newConversationRepository(cc.DB)
+	conversations, next, err := repo.GetConversationsByUserID(userID, filter, page)
+	if errors.Is(err, repository.ErrInvalidPage) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch conversations"})
 		return
 	}
 
-	if len(conversations) == 0 {
-		ctx.JSON(http.StatusNotFound, gin.H{"message": "No conversations found"})
+	ctx.JSON(http.StatusOK, model.ConversationList{Conversations: conversations, NextCursor: next})
+}
+
+// @Summary Get a conversation
+// @Description Get a conversation with the chat history of its active branch
+// @Tags conversations
+// @Produce json
+// @Param uuid path string true "UUID of the conversation"
+// @Param user_id query string true "User ID owning the conversation"
+// @Success 200 {object} model.Conversation "Conversation"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 404 {object} map[string]interface{} "Conversation not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /conversations/{uuid} [get]
+func (cc *ConversationController) GetConversation(ctx *gin.Context) {
+	repo := newConversationRepository(cc.DB)
+	conversation, ok := findUserConversation(ctx, repo)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, conversation)
+}
+
+// @Summary Delete a conversation
+// @Description Hide a conversation from every endpoint until it is restored
+// @Tags conversations
+// @Produce json
+// @Param uuid path string true "UUID of the conversation"
+// @Param user_id query string true "User ID owning the conversation"
+// @Success 200 {object} map[string]interface{} "Conversation deleted"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 404 {object} map[string]interface{} "Conversation not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /conversations/{uuid} [delete]
+func (cc *ConversationController) DeleteConversation(ctx *gin.Context) {
+	repo := newConversationRepository(cc.DB)
+	conversation, ok := findUserConversation(ctx, repo)
+	if !ok {
+		return
+	}
+
+	if err := repo.DeleteConversation(conversation); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete conversation"})
 		return
 	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Conversation deleted"})
+}
 
-	ctx.JSON(http.StatusOK, gin.H{"conversations": conversations})
+// @Summary Restore a conversation
+// @Description Bring back a deleted conversation
+// @Tags conversations
+// @Produce json
+// @Param uuid path string true "UUID of the conversation"
+// @Param user_id query string true "User ID owning the conversation"
+// @Success 200 {object} model.Conversation "Restored conversation"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 404 {object} map[string]interface{} "No deleted conversation found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /conversations/{uuid}/restore [post]
+func (cc *ConversationController) RestoreConversation(ctx *gin.Context) {
+	userID, err := strconv.ParseUint(ctx.Query("user_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "A valid user_id is required"})
+		return
+	}
+	if _, err := uuid.Parse(ctx.Param("uuid")); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation uuid"})
+		return
+	}
+
+	repo := newConversationRepository(cc.DB)
+	restored, err := repo.RestoreConversation(uint(userID), ctx.Param("uuid"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore conversation"})
+		return
+	}
+	if !restored {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "No deleted conversation found"})
+		return
+	}
+
+	conversation, ok := findUserConversation(ctx, repo)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, conversation)
+}
+
+// @Summary List conversation messages
+// @Description List a page of the messages of the active branch of a conversation, oldest first by default
+// @Tags conversations
+// @Produce json
+// @Param uuid path string true "UUID of the conversation"
+// @Param user_id query string true "User ID owning the conversation"
+// @Param limit query int false "Messages per page, at most 100" default(20)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param order query string false "asc or desc" default(asc)
+// @Param from query string false "Earliest creation time, YYYY-MM-DD or RFC 3339"
+// @Param to query string false "Latest creation time, YYYY-MM-DD (inclusive) or RFC 3339"
+// @Success 200 {object} model.MessagePage "Page of messages"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 404 {object} map[string]interface{} "Conversation not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /conversations/{uuid}/messages [get]
+func (cc *ConversationController) GetConversationMessages(ctx *gin.Context) {
+	page, err := parsePage(ctx, "created_at", "asc")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	repo := newConversationRepository(cc.DB)
+	conversation, ok := findUserConversation(ctx, repo)
+	if !ok {
+		return
+	}
+
+	messages, err := repo.GetMessages(conversation, page)
+	if errors.Is(err, repository.ErrInvalidPage) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+		return
+	}
+	ctx.JSON(http.StatusOK, messages)
+}
+
+// @Summary Get conversation locations
+// @Description Get the geocoded locations of a conversation as a GeoJSON FeatureCollection
+// @Tags conversations
+// @Produce application/geo+json
+// @Param uuid path string true "UUID of the conversation"
+// @Param user_id query string true "User ID owning the conversation"
+// @Success 200 {object} map[string]interface{} "GeoJSON FeatureCollection"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 404 {object} map[string]interface{} "Conversation not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /conversations/{uuid}/locations [get]
+func (cc *ConversationController) GetConversationLocations(ctx *gin.Context) {
+	repo := newConversationRepository(cc.DB)
+	conversation, ok := findUserConversation(ctx, repo)
+	if !ok {
+		return
+	}
+
+	collection, err := repo.GetConversationLocations(conversation)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch conversation locations"})
+		return
+	}
+
+	ctx.Header("Content-Type", geojson.ContentType)
+	ctx.JSON(http.StatusOK, collection)
+}
+
+// @Summary Export a conversation
+// @Description Download the active branch of a conversation as a Markdown or JSON transcript, or its geocoded locations as GeoJSON, KML or GPX with the message text as descriptions
+// @Tags conversations
+// @Produce text/markdown
+// @Produce json
+// @Produce application/geo+json
+// @Produce application/vnd.google-earth.kml+xml
+// @Produce application/gpx+xml
+// @Param uuid path string true "UUID of the conversation"
+// @Param user_id query string true "User ID owning the conversation"
+// @Param format query string false "md, json, geojson, kml or gpx" default(md)
+// @Success 200 {file} file "Exported conversation"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 404 {object} map[string]interface{} "Conversation not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /conversations/{uuid}/export [get]
+func (cc *ConversationController) ExportConversation(ctx *gin.Context) {
+	format, ok := export.Lookup(ctx.DefaultQuery("format", "md"))
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "format must be md, json, geojson, kml or gpx"})
+		return
+	}
+
+	repo := newConversationRepository(cc.DB)
+	conversation, ok := findUserConversation(ctx, repo)
+	if !ok {
+		return
+	}
+
+	ctx.Header("Content-Type", format.ContentType)
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": format.FileName(conversation)}))
+	ctx.Status(http.StatusOK)
+	if err := format.Write(ctx.Writer, conversation); err != nil {
+		// The headers are already sent, the client sees a truncated file
+		fmt.Printf("Error exporting conversation %s: %v\n", conversation.ConversationID, err)
+	}
+}
+
+// @Summary Update a conversation
+// @Description Change the title, summary, pin, archive flag, tags or default chat settings of a conversation
+// @Tags conversations
+// @Accept json
+// @Produce json
+// @Param uuid path string true "UUID of the conversation"
+// @Param user_id query string true "User ID owning the conversation"
+// @Param conversation body model.UpdateConversationRequest true "Fields to change"
+// @Success 200 {object} model.Conversation "Updated conversation"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 404 {object} map[string]interface{} "Conversation not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /conversations/{uuid} [patch]
+func (cc *ConversationController) UpdateConversation(ctx *gin.Context) {
+	var req model.UpdateConversationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	repo := newConversationRepository(cc.DB)
+	conversation, ok := findUserConversation(ctx, repo)
+	if !ok {
+		return
+	}
+
+	if req.Title != nil {
+		conversation.Title = *req.Title
+	}
+	if req.Summary != nil {
+		conversation.Summary = *req.Summary
+	}
+	if req.Pinned != nil {
+		conversation.Pinned = *req.Pinned
+	}
+	if req.Archived != nil {
+		conversation.Archived = *req.Archived
+	}
+	if req.Tags != nil {
+		conversation.Tags = *req.Tags
+	}
+	if req.Settings != nil {
+		settings := conversation.Settings.Merge(*req.Settings)
+		if err := validateSettings(cc.Models, settings); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		conversation.Settings = settings
+	}
+	if err := repo.UpdateConversationDetails(conversation); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update conversation"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, conversation)
+}
+
+// @Summary Switch branch
+// @Description Continue the conversation on the branch through a message, ending at its newest reply
+// @Tags conversations
+// @Accept json
+// @Produce json
+// @Param uuid path string true "UUID of the conversation"
+// @Param user_id query string true "User ID owning the conversation"
+// @Param branch body model.BranchRequest true "Message on the branch"
+// @Success 200 {object} model.Conversation "Conversation with the new active branch"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 404 {object} map[string]interface{} "Conversation or message not found"
+// @Failure 409 {object} map[string]interface{} "Conversation was updated concurrently"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /conversations/{uuid}/branch [put]
+func (cc *ConversationController) SwitchBranch(ctx *gin.Context) {
+	var req model.BranchRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	repo := newConversationRepository(cc.DB)
+	conversation, ok := findUserConversation(ctx, repo)
+	if !ok {
+		return
+	}
+	if err := repo.GetMessageTree(conversation); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+		return
+	}
+	if _, found := conversation.FindMessage(req.MessageID); !found {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	err := repo.SwitchBranch(conversation, req.MessageID)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Conversation was updated by another request, please retry"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to switch branch"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, conversation)
+}
+
+// @Summary Fork a conversation
+// @Description Copy the branch up to a message into a new conversation
+// @Tags conversations
+// @Accept json
+// @Produce json
+// @Param uuid path string true "UUID of the conversation"
+// @Param user_id query string true "User ID owning the conversation"
+// @Param branch body model.BranchRequest true "Last message to copy"
+// @Success 201 {object} model.Conversation "New conversation"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 404 {object} map[string]interface{} "Conversation or message not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /conversations/{uuid}/fork [post]
+func (cc *ConversationController) ForkConversation(ctx *gin.Context) {
+	var req model.BranchRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	repo := newConversationRepository(cc.DB)
+	conversation, ok := findUserConversation(ctx, repo)
+	if !ok {
+		return
+	}
+	branch, err := repo.GetBranch(conversation, req.MessageID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+		return
+	}
+	if len(branch) == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	// Copies point at the original message so usage is not counted twice
+	for i := range branch {
+		original := branch[i].ID
+		if branch[i].ForkedFromID != 0 {
+			original = branch[i].ForkedFromID
+		}
+		branch[i].ID, branch[i].ParentID, branch[i].SiblingIDs, branch[i].ForkedFromID = 0, 0, nil, original
+		// A reply still in progress stays with the original, retry it in the fork
+		if branch[i].Status == model.MessagePending {
+			branch[i].Status, branch[i].Error = model.MessageFailed, "Forked before the reply was saved"
+		}
+	}
+
+	fork := &model.Conversation{
+		UserID:         conversation.UserID,
+		ConversationID: uuid.New().String(),
+		Title:          conversation.Title,
+		ChatHistory:    branch,
+		Settings:       conversation.Settings,
+		PromptID:       conversation.PromptID,
+		Persona:        conversation.Persona,
+		PromptVersion:  conversation.PromptVersion,
+	}
+	if err := repo.CreateConversation(fork); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fork conversation"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, fork)
+}
+
+// findUserConversation loads the conversation in the uuid path parameter and
+// checks that it belongs to the user_id query parameter. Malformed uuids are
+// rejected before they reach the database. It writes the error
+// response and returns false when the conversation is not available.
+func findUserConversation(ctx *gin.Context, repo repository.ConversationRepositoryInterface) (*model.Conversation, bool) {
+	userID, err := strconv.ParseUint(ctx.Query("user_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "A valid user_id is required"})
+		return nil, false
+	}
+	if _, err := uuid.Parse(ctx.Param("uuid")); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation uuid"})
+		return nil, false
+	}
+
+	conversation, err := repo.GetConversationByUUID(ctx.Param("uuid"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch conversation"})
+		return nil, false
+	}
+	if conversation == nil || conversation.UserID != uint(userID) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return nil, false
+	}
+	return conversation, true
 }
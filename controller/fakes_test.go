@@ -0,0 +1,247 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"sync"
+	"time"
+
+	"geoai-app/llm"
+	"geoai-app/model"
+	"geoai-app/repository"
+)
+
+// fakeUsers knows every user ID
+type fakeUsers struct {
+	repository.UserRepositoryInterface
+}
+
+func (fakeUsers) GetUserByID(id string) (*model.User, error) {
+	userID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, nil
+	}
+	return &model.User{ID: uint(userID)}, nil
+}
+
+// fakePrompts has no stored personas, so the built-in default is used
+type fakePrompts struct {
+	repository.PromptRepositoryInterface
+}
+
+func (fakePrompts) GetActivePrompt(string) (*model.Prompt, error) {
+	return nil, nil
+}
+
+// fakeConversations keeps conversations in memory with the version and
+// status checks of ConversationRepository. Methods the tests do not use
+// panic through the nil embedded interface.
+type fakeConversations struct {
+	repository.ConversationRepositoryInterface
+
+	mu            sync.Mutex
+	nextID        uint
+	conversations map[string]*model.Conversation
+	// afterLoad runs after GetConversationByUUID has read a conversation
+	afterLoad func(uuid string)
+}
+
+func newFakeConversations() *fakeConversations {
+	return &fakeConversations{conversations: make(map[string]*model.Conversation)}
+}
+
+// useFakeRepositories routes the handlers to the fakes until the test ends
+func useFakeRepositories(t interface{ Cleanup(func()) }, conversations *fakeConversations) {
+	users, conversationRepo, prompts := newUserRepository, newConversationRepository, newPromptRepository
+	newUserRepository = func(*sql.DB) repository.UserRepositoryInterface { return fakeUsers{} }
+	newConversationRepository = func(*sql.DB) repository.ConversationRepositoryInterface { return conversations }
+	newPromptRepository = func(*sql.DB) repository.PromptRepositoryInterface { return fakePrompts{} }
+	t.Cleanup(func() {
+		newUserRepository, newConversationRepository, newPromptRepository = users, conversationRepo, prompts
+	})
+}
+
+// stored returns a copy of the stored conversation with its active branch
+func (f *fakeConversations) stored(uuid string) *model.Conversation {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.load(uuid)
+}
+
+func (f *fakeConversations) load(uuid string) *model.Conversation {
+	stored, ok := f.conversations[uuid]
+	if !ok {
+		return nil
+	}
+	conversation := *stored
+	conversation.Messages = nil
+	conversation.ChatHistory = stored.Branch(conversation.ActiveMessageID)
+	return &conversation
+}
+
+func (f *fakeConversations) GetBranch(conversation *model.Conversation, messageID uint) ([]model.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.conversations[conversation.ConversationID].Branch(messageID), nil
+}
+
+func (f *fakeConversations) GetMessageTree(conversation *model.Conversation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	conversation.Messages = append([]model.Message(nil), f.conversations[conversation.ConversationID].Messages...)
+	return nil
+}
+
+func (f *fakeConversations) GetConversationByUUID(uuid string) (*model.Conversation, error) {
+	f.mu.Lock()
+	conversation := f.load(uuid)
+	f.mu.Unlock()
+
+	if f.afterLoad != nil {
+		f.afterLoad(uuid)
+	}
+	return conversation, nil
+}
+
+func (f *fakeConversations) CreateConversation(conversation *model.Conversation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.nextID++
+	conversation.ID = f.nextID
+	conversation.Version = 1
+	var parentID uint
+	for i := range conversation.ChatHistory {
+		f.nextID++
+		conversation.ChatHistory[i].ID = f.nextID
+		conversation.ChatHistory[i].ConversationID = conversation.ID
+		conversation.ChatHistory[i].ParentID = parentID
+		conversation.ChatHistory[i].CreatedAt = time.Now()
+		conversation.ChatHistory[i].ClaimedAt = conversation.ChatHistory[i].CreatedAt
+		parentID = f.nextID
+	}
+	conversation.ActiveMessageID = parentID
+
+	stored := *conversation
+	stored.Messages = append([]model.Message(nil), conversation.ChatHistory...)
+	stored.ChatHistory = nil
+	f.conversations[conversation.ConversationID] = &stored
+	return nil
+}
+
+func (f *fakeConversations) AppendMessages(conversation *model.Conversation, messages []model.Message) error {
+	return f.append(conversation, conversation.ActiveMessageID, messages, nil)
+}
+
+func (f *fakeConversations) BranchMessages(conversation *model.Conversation, parentID uint, messages []model.Message) error {
+	return f.append(conversation, parentID, messages, nil)
+}
+
+func (f *fakeConversations) AnswerMessage(conversation *model.Conversation, question *model.Message, messages []model.Message) error {
+	return f.append(conversation, question.ID, messages, question)
+}
+
+func (f *fakeConversations) UpdateMessageStatus(conversation *model.Conversation, message *model.Message, status, detail string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stored := f.conversations[conversation.ConversationID]
+	if err := setStoredStatus(stored, message, status, detail); err != nil {
+		return err
+	}
+	for _, messages := range [][]model.Message{conversation.ChatHistory, conversation.Messages} {
+		for i := range messages {
+			if messages[i].ID == message.ID {
+				messages[i].Status, messages[i].Error, messages[i].ClaimedAt = status, detail, message.ClaimedAt
+			}
+		}
+	}
+	return nil
+}
+
+func (f *fakeConversations) ReopenMessage(conversation *model.Conversation, question *model.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stored := f.conversations[conversation.ConversationID]
+	if stored == nil || stored.Version != conversation.Version {
+		return repository.ErrVersionConflict
+	}
+	if err := setStoredStatus(stored, question, model.MessagePending, ""); err != nil {
+		return err
+	}
+	stored.ActiveMessageID = question.ID
+	stored.Version++
+
+	*conversation = *f.load(conversation.ConversationID)
+	return nil
+}
+
+// append claims the next version, answers the question when given and adds
+// the messages after parentID as the new active branch
+func (f *fakeConversations) append(conversation *model.Conversation, parentID uint, messages []model.Message, question *model.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stored := f.conversations[conversation.ConversationID]
+	if stored == nil || stored.Version != conversation.Version {
+		return repository.ErrVersionConflict
+	}
+	if question != nil {
+		if err := setStoredStatus(stored, question, model.MessageAnswered, ""); err != nil {
+			return err
+		}
+	}
+
+	for i := range messages {
+		f.nextID++
+		messages[i].ID = f.nextID
+		messages[i].ConversationID = stored.ID
+		messages[i].ParentID = parentID
+		messages[i].CreatedAt = time.Now()
+		messages[i].ClaimedAt = messages[i].CreatedAt
+		parentID = f.nextID
+		stored.Messages = append(stored.Messages, messages[i])
+	}
+	stored.ActiveMessageID = parentID
+	stored.Version++
+
+	*conversation = *f.load(conversation.ConversationID)
+	return nil
+}
+
+// setStoredStatus changes a message status if its status and claim still
+// match the caller's copy, reopening claims it anew, like
+// updateMessageStatus
+func setStoredStatus(stored *model.Conversation, message *model.Message, status, detail string) error {
+	for i := range stored.Messages {
+		if stored.Messages[i].ID == message.ID {
+			if stored.Messages[i].Status != message.Status || !stored.Messages[i].ClaimedAt.Equal(message.ClaimedAt) {
+				return repository.ErrVersionConflict
+			}
+			if status == model.MessagePending {
+				stored.Messages[i].ClaimedAt = time.Now()
+			}
+			stored.Messages[i].Status, stored.Messages[i].Error = status, detail
+			message.Status, message.Error, message.ClaimedAt = status, detail, stored.Messages[i].ClaimedAt
+			return nil
+		}
+	}
+	return repository.ErrVersionConflict
+}
+
+// recordingProvider remembers every request sent to the wrapped provider
+type recordingProvider struct {
+	llm.Provider
+
+	mu       sync.Mutex
+	requests []llm.Request
+}
+
+func (p *recordingProvider) ChatCompletion(ctx context.Context, req llm.Request) (*llm.Response, error) {
+	p.mu.Lock()
+	p.requests = append(p.requests, req)
+	p.mu.Unlock()
+	return p.Provider.ChatCompletion(ctx, req)
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	"geoai-app/model"
+	"github.com/gin-gonic/gin"
+)
+
+type ModelController struct {
+	Models []model.ModelInfo
+}
+
+func NewModelController(models []model.ModelInfo) *ModelController {
+	return &ModelController{Models: models}
+}
+
+// @Summary List models
+// @Description List the models clients may select in chat requests and their limits
+// @Tags models
+// @Produce json
+// @Success 200 {object} map[string][]model.ModelInfo "List of models"
+// @Router /models [get]
+func (mc *ModelController) GetModels(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"models": mc.Models})
+}
+
+// validateSettings checks chat settings against the limits of the model
+// they resolve to and the ranges accepted by OpenAI-compatible APIs
+func validateSettings(models []model.ModelInfo, settings model.ChatSettings) error {
+	info := resolveModel(models, settings.Model)
+	if info == nil && settings.Model != "" {
+		return fmt.Errorf("model %q is not available, see GET /models", settings.Model)
+	}
+	if info != nil && settings.MaxTokens != nil && info.MaxOutputTokens > 0 && *settings.MaxTokens > info.MaxOutputTokens {
+		return fmt.Errorf("max_tokens must be at most %d for model %s", info.MaxOutputTokens, info.Name)
+	}
+	if settings.Temperature != nil && (*settings.Temperature < 0 || *settings.Temperature > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2")
+	}
+	if settings.MaxTokens != nil && *settings.MaxTokens < 1 {
+		return fmt.Errorf("max_tokens must be positive")
+	}
+	if settings.TopP != nil && (*settings.TopP <= 0 || *settings.TopP > 1) {
+		return fmt.Errorf("top_p must be greater than 0 and at most 1")
+	}
+	if len(settings.Stop) > 4 {
+		return fmt.Errorf("stop accepts at most 4 sequences")
+	}
+	return nil
+}
+
+// resolveModel returns the named model, or the default model when name is
+// empty. It is nil for unknown models.
+func resolveModel(models []model.ModelInfo, name string) *model.ModelInfo {
+	for i := range models {
+		if models[i].Name == name || (name == "" && models[i].Default) {
+			return &models[i]
+		}
+	}
+	return nil
+}
+
+// promptBudget returns the tokens left for the prompt in the context window
+// of the model the settings resolve to, after reserving the reply. It is 0
+// when the window is unknown.
+func promptBudget(models []model.ModelInfo, settings model.ChatSettings) int {
+	info := resolveModel(models, settings.Model)
+	if info == nil || info.ContextWindow <= 0 {
+		return 0
+	}
+	reserve := info.MaxOutputTokens
+	if settings.MaxTokens != nil {
+		reserve = *settings.MaxTokens
+	}
+	return max(info.ContextWindow-reserve, 1)
+}
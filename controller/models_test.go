@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"testing"
+
+	"geoai-app/model"
+)
+
+func intPointer(v int) *int { return &v }
+
+func TestValidateSettings(t *testing.T) {
+	models := []model.ModelInfo{
+		{Name: "llama3", ContextWindow: 8192, MaxOutputTokens: 2048, Default: true},
+		{Name: "llama3.2:3b", ContextWindow: 131072, MaxOutputTokens: 4096},
+	}
+	tests := []struct {
+		name     string
+		settings model.ChatSettings
+		wantErr  bool
+	}{
+		{"defaults", model.ChatSettings{}, false},
+		{"default model within its limit", model.ChatSettings{MaxTokens: intPointer(2048)}, false},
+		{"default model over its limit", model.ChatSettings{MaxTokens: intPointer(4096)}, true},
+		{"selected model within its limit", model.ChatSettings{Model: "llama3.2:3b", MaxTokens: intPointer(4096)}, false},
+		{"unknown model", model.ChatSettings{Model: "gpt-4o"}, true},
+		{"zero max tokens", model.ChatSettings{MaxTokens: intPointer(0)}, true},
+	}
+	for _, tt := range tests {
+		if err := validateSettings(models, tt.settings); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPromptBudget(t *testing.T) {
+	models := []model.ModelInfo{
+		{Name: "llama3", ContextWindow: 8192, MaxOutputTokens: 2048, Default: true},
+		{Name: "unknown-window"},
+	}
+	tests := []struct {
+		settings model.ChatSettings
+		want     int
+	}{
+		{model.ChatSettings{}, 6144},
+		{model.ChatSettings{MaxTokens: intPointer(512)}, 7680},
+		{model.ChatSettings{Model: "unknown-window"}, 0},
+		{model.ChatSettings{Model: "gone"}, 0},
+	}
+	for _, tt := range tests {
+		if got := promptBudget(models, tt.settings); got != tt.want {
+			t.Errorf("promptBudget(%+v) = %d, want %d", tt.settings, got, tt.want)
+		}
+	}
+}
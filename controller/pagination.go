@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"geoai-app/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// parsePage reads the limit, cursor, sort, order, from and to query
+// parameters shared by the list endpoints. Sort keys are checked by the
+// repository.
+func parsePage(ctx *gin.Context, defaultSort, defaultOrder string) (repository.Page, error) {
+	page := repository.Page{
+		Limit:  repository.DefaultPageSize,
+		Cursor: ctx.Query("cursor"),
+		Sort:   ctx.DefaultQuery("sort", defaultSort),
+	}
+
+	if value := ctx.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > repository.MaxPageSize {
+			return page, fmt.Errorf("limit must be between 1 and %d", repository.MaxPageSize)
+		}
+		page.Limit = limit
+	}
+
+	switch order := ctx.DefaultQuery("order", defaultOrder); order {
+	case "asc":
+	case "desc":
+		page.Desc = true
+	default:
+		return page, fmt.Errorf("order must be asc or desc, got %q", order)
+	}
+
+	var err error
+	if value := ctx.Query("from"); value != "" {
+		if page.From, err = parseUsageTime(value, 0); err != nil {
+			return page, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if value := ctx.Query("to"); value != "" {
+		if page.To, err = parseUsageTime(value, 24*time.Hour); err != nil {
+			return page, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	return page, nil
+}
+
+// parseOptionalBool reads a true or false query parameter, nil when absent
+func parseOptionalBool(ctx *gin.Context, name string) (*bool, error) {
+	value := ctx.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be true or false", name)
+	}
+	return &parsed, nil
+}
@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"geoai-app/model"
+	"geoai-app/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type PromptController struct {
+	DB *sql.DB
+}
+
+func NewPromptController(db *sql.DB) *PromptController {
+	return &PromptController{DB: db}
+}
+
+// @Summary List personas
+// @Description List the personas available for new conversations with their active prompt version
+// @Tags prompts
+// @Produce json
+// @Success 200 {object} map[string][]model.Prompt "List of personas"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /prompts [get]
+func (pc *PromptController) GetPrompts(ctx *gin.Context) {
+	repo := newPromptRepository(pc.DB)
+	prompts, err := repo.GetActivePrompts()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch prompts"})
+		return
+	}
+	if prompts == nil {
+		prompts = []model.Prompt{}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"prompts": prompts})
+}
+
+// @Summary List persona versions
+// @Description List all prompt versions of a persona, newest first
+// @Tags prompts
+// @Produce json
+// @Param name path string true "Persona name"
+// @Success 200 {object} map[string][]model.Prompt "Prompt versions"
+// @Failure 404 {object} map[string]interface{} "Persona not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /prompts/{name} [get]
+func (pc *PromptController) GetPromptVersions(ctx *gin.Context) {
+	repo := newPromptRepository(pc.DB)
+	prompts, err := repo.GetPromptVersions(ctx.Param("name"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch prompts"})
+		return
+	}
+	if len(prompts) == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Persona not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"prompts": prompts})
+}
+
+// @Summary Create a persona
+// @Description Create a persona with its first prompt version. Requires the admin token.
+// @Tags prompts
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param prompt body model.CreatePromptRequest true "New persona"
+// @Success 201 {object} model.Prompt "Created prompt version"
+// @Failure 400 {object} map[string]interface{} "Invalid request data"
+// @Failure 401 {object} map[string]interface{} "Missing or invalid admin token"
+// @Failure 409 {object} map[string]interface{} "Persona already exists"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /prompts [post]
+func (pc *PromptController) CreatePrompt(ctx *gin.Context) {
+	var req model.CreatePromptRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	prompt := &model.Prompt{Name: req.Name, Version: 1, Description: req.Description, Content: req.Content}
+	err := newPromptRepository(pc.DB).CreatePromptVersion(prompt)
+	if errors.Is(err, repository.ErrPromptExists) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Persona already exists, use PUT /prompts/{name} to add a version"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create prompt"})
+		return
+	}
+	ctx.JSON(http.StatusCreated, prompt)
+}
+
+// @Summary Update a persona
+// @Description Add a new prompt version to a persona and make it active. Requires the admin token.
+// @Tags prompts
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param name path string true "Persona name"
+// @Param prompt body model.UpdatePromptRequest true "New prompt version"
+// @Success 200 {object} model.Prompt "Created prompt version"
+// @Failure 400 {object} map[string]interface{} "Invalid request data"
+// @Failure 401 {object} map[string]interface{} "Missing or invalid admin token"
+// @Failure 404 {object} map[string]interface{} "Persona not found"
+// @Failure 409 {object} map[string]interface{} "Persona was updated concurrently"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /prompts/{name} [put]
+func (pc *PromptController) UpdatePrompt(ctx *gin.Context) {
+	var req model.UpdatePromptRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	repo := newPromptRepository(pc.DB)
+	versions, err := repo.GetPromptVersions(ctx.Param("name"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch prompts"})
+		return
+	}
+	if len(versions) == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Persona not found"})
+		return
+	}
+
+	prompt := &model.Prompt{Name: ctx.Param("name"), Description: req.Description, Content: req.Content}
+	err = repo.CreatePromptVersion(prompt)
+	if errors.Is(err, repository.ErrPromptExists) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Persona was updated by another request, please retry"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update prompt"})
+		return
+	}
+	ctx.JSON(http.StatusOK, prompt)
+}
+
+// @Summary Roll back a persona
+// @Description Make an earlier prompt version of a persona active again. Requires the admin token.
+// @Tags prompts
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param name path string true "Persona name"
+// @Param rollback body model.RollbackPromptRequest true "Version to activate"
+// @Success 200 {object} model.Prompt "Active prompt version"
+// @Failure 400 {object} map[string]interface{} "Invalid request data"
+// @Failure 401 {object} map[string]interface{} "Missing or invalid admin token"
+// @Failure 404 {object} map[string]interface{} "Prompt version not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /prompts/{name}/rollback [post]
+func (pc *PromptController) RollbackPrompt(ctx *gin.Context) {
+	var req model.RollbackPromptRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	prompt, err := newPromptRepository(pc.DB).ActivatePromptVersion(ctx.Param("name"), req.Version)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to roll back prompt"})
+		return
+	}
+	if prompt == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Prompt version not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, prompt)
+}
+
+// @Summary Delete a persona
+// @Description Hide a persona from new conversations. Its versions are kept for existing conversations and can be restored with a rollback. Requires the admin token.
+// @Tags prompts
+// @Produce json
+// @Security AdminToken
+// @Param name path string true "Persona name"
+// @Success 200 {object} map[string]interface{} "Persona deleted"
+// @Failure 401 {object} map[string]interface{} "Missing or invalid admin token"
+// @Failure 404 {object} map[string]interface{} "Persona not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /prompts/{name} [delete]
+func (pc *PromptController) DeletePrompt(ctx *gin.Context) {
+	deleted, err := newPromptRepository(pc.DB).DeactivatePrompt(ctx.Param("name"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete prompt"})
+		return
+	}
+	if !deleted {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Persona not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Persona deleted"})
+}
@@ -0,0 +1,13 @@
+package controller
+
+import "geoai-app/repository"
+
+// Repository constructors used by the handlers, tests replace them with
+// in-memory fakes
+var (
+	newUserRepository         = repository.NewUserRepository
+	newConversationRepository = repository.NewConversationRepository
+	newPromptRepository       = repository.NewPromptRepository
+	newSearchRepository       = repository.NewSearchRepository
+	newUsageRepository        = repository.NewUsageRepository
+)
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"geoai-app/model"
+	"geoai-app/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// maxSearchQueryLength bounds search queries in characters
+const maxSearchQueryLength = 200
+
+type SearchController struct {
+	DB *sql.DB
+}
+
+func NewSearchController(db *sql.DB) *SearchController {
+	return &SearchController{DB: db}
+}
+
+// @Summary Search conversations
+// @Description Full-text search over the messages, titles and summaries of a user's conversations. Supports quoted phrases, OR and -word. Snippets are HTML escaped with the matched words wrapped in <mark> tags.
+// @Tags conversations
+// @Produce json
+// @Param user_id query string true "User ID whose conversations are searched"
+// @Param q query string true "Search query, e.g. flood zones Jakarta"
+// @Param limit query int false "Conversations to return, at most 100" default(20)
+// @Success 200 {object} model.SearchResponse "Matching conversations, best first"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /search [get]
+func (sc *SearchController) Search(ctx *gin.Context) {
+	userID, err := strconv.ParseUint(ctx.Query("user_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "A valid user_id is required"})
+		return
+	}
+
+	query := strings.TrimSpace(ctx.Query("q"))
+	if query == "" || len([]rune(query)) > maxSearchQueryLength {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("q is required and at most %d characters", maxSearchQueryLength)})
+		return
+	}
+
+	limit := repository.DefaultPageSize
+	if value := ctx.Query("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > repository.MaxPageSize {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", repository.MaxPageSize)})
+			return
+		}
+	}
+
+	repo := newSearchRepository(sc.DB)
+	results, err := repo.Search(uint(userID), query, limit)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search conversations"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, model.SearchResponse{Query: query, Results: results})
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"geoai-app/model"
+	"geoai-app/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// fakeSearch records the search it is asked for
+type fakeSearch struct {
+	repository.SearchRepositoryInterface
+	userID uint
+	query  string
+	limit  int
+	err    error
+}
+
+func (f *fakeSearch) Search(userID uint, query string, limit int) ([]model.SearchResult, error) {
+	f.userID, f.query, f.limit = userID, query, limit
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []model.SearchResult{{ConversationID: "c-1", Matches: []model.SearchMatch{}}}, nil
+}
+
+func TestSearchHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name      string
+		query     string
+		err       error
+		want      int
+		wantQuery string
+		wantLimit int
+	}{
+		{name: "searches", query: "user_id=7&q=+flood+zones+", want: http.StatusOK, wantQuery: "flood zones", wantLimit: repository.DefaultPageSize},
+		{name: "takes a limit", query: "user_id=7&q=flood&limit=5", want: http.StatusOK, wantQuery: "flood", wantLimit: 5},
+		{name: "needs a user", query: "q=flood", want: http.StatusBadRequest},
+		{name: "needs a query", query: "user_id=7&q=+", want: http.StatusBadRequest},
+		{name: "bounds the query", query: "user_id=7&q=" + strings.Repeat("a", maxSearchQueryLength+1), want: http.StatusBadRequest},
+		{name: "bounds the limit", query: "user_id=7&q=flood&limit=101", want: http.StatusBadRequest},
+		{name: "rejects a zero limit", query: "user_id=7&q=flood&limit=0", want: http.StatusBadRequest},
+		{name: "reports failures", query: "user_id=7&q=flood", err: errors.New("down"), want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := &fakeSearch{err: tt.err}
+			previous := newSearchRepository
+			newSearchRepository = func(*sql.DB) repository.SearchRepositoryInterface { return search }
+			t.Cleanup(func() { newSearchRepository = previous })
+
+			router := gin.New()
+			router.GET("/search", NewSearchController(nil).Search)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/search?"+tt.query, nil))
+
+			if recorder.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.want, recorder.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			if search.userID != 7 || search.query != tt.wantQuery || search.limit != tt.wantLimit {
+				t.Errorf("searched user %d for %q with limit %d, want 7, %q, %d", search.userID, search.query, search.limit, tt.wantQuery, tt.wantLimit)
+			}
+			var resp model.SearchResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil || resp.Query != tt.wantQuery || len(resp.Results) != 1 {
+				t.Errorf("response = %s, want the query and its results", recorder.Body)
+			}
+		})
+	}
+}
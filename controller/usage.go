@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"geoai-app/model"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultUsageRange is the report range when from is not given
+const defaultUsageRange = 30 * 24 * time.Hour
+
+type UsageController struct {
+	DB *sql.DB
+	// Prices per model name, models without a price count as free
+	Prices map[string]model.Price
+}
+
+func NewUsageController(db *sql.DB, prices map[string]model.Price) *UsageController {
+	return &UsageController{DB: db, Prices: prices}
+}
+
+// @Summary Get usage report
+// @Description Aggregate the LLM token usage, latency and estimated cost of a user per day or model
+// @Tags usage
+// @Produce json
+// @Param user_id query string true "User ID to report on"
+// @Param from query string false "Start of the range as YYYY-MM-DD or RFC 3339, defaults to 30 days before to"
+// @Param to query string false "End of the range as YYYY-MM-DD (inclusive) or RFC 3339, defaults to now"
+// @Param group_by query string false "Group by day or model" Enums(day, model) default(day)
+// @Success 200 {object} model.UsageReport "Usage report"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /usage [get]
+func (uc *UsageController) GetUsage(ctx *gin.Context) {
+	userID, err := strconv.ParseUint(ctx.Query("user_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "A valid user_id is required"})
+		return
+	}
+
+	groupBy := ctx.DefaultQuery("group_by", "day")
+	if groupBy != "day" && groupBy != "model" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be day or model"})
+		return
+	}
+
+	to := time.Now().UTC()
+	if value := ctx.Query("to"); value != "" {
+		// A date includes the whole day
+		to, err = parseUsageTime(value, 24*time.Hour)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to: " + err.Error()})
+			return
+		}
+	}
+	from := to.Add(-defaultUsageRange)
+	if value := ctx.Query("from"); value != "" {
+		from, err = parseUsageTime(value, 0)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from: " + err.Error()})
+			return
+		}
+	}
+	if !from.Before(to) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	repo := newUsageRepository(uc.DB)
+	rows, err := repo.GetUsage(uint(userID), from, to, groupBy)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch usage"})
+		return
+	}
+
+	report := uc.buildReport(rows)
+	report.UserID = uint(userID)
+	report.From = from
+	report.To = to
+	report.GroupBy = groupBy
+	ctx.JSON(http.StatusOK, report)
+}
+
+// buildReport sums the per-model rows into groups and prices them
+func (uc *UsageController) buildReport(rows []model.UsageRow) *model.UsageReport {
+	report := &model.UsageReport{Currency: "USD", Groups: []model.UsageGroup{}, Total: model.UsageGroup{Key: "total"}}
+	unpriced := make(map[string]bool)
+	totalLatency := 0
+	groupLatency := 0
+
+	for _, row := range rows {
+		if len(report.Groups) == 0 || report.Groups[len(report.Groups)-1].Key != row.Group {
+			finishGroup(report.Groups, groupLatency)
+			report.Groups = append(report.Groups, model.UsageGroup{Key: row.Group})
+			groupLatency = 0
+		}
+
+		cost := 0.0
+		if price, ok := uc.Prices[row.Model]; ok {
+			cost = (float64(row.PromptTokens)*price.Input + float64(row.CompletionTokens)*price.Output) / 1e6
+		} else {
+			unpriced[row.Model] = true
+		}
+
+		for _, group := range []*model.UsageGroup{&report.Groups[len(report.Groups)-1], &report.Total} {
+			group.Calls += row.Calls
+			group.PromptTokens += row.PromptTokens
+			group.CompletionTokens += row.CompletionTokens
+			group.TotalTokens += row.PromptTokens + row.CompletionTokens
+			group.EstimatedCost += cost
+		}
+		groupLatency += row.LatencyMS
+		totalLatency += row.LatencyMS
+	}
+	finishGroup(report.Groups, groupLatency)
+	if report.Total.Calls > 0 {
+		report.Total.AvgLatencyMS = totalLatency / report.Total.Calls
+	}
+
+	for name := range unpriced {
+		report.UnpricedModels = append(report.UnpricedModels, name)
+	}
+	sort.Strings(report.UnpricedModels)
+	return report
+}
+
+// finishGroup sets the average latency of the last group
+func finishGroup(groups []model.UsageGroup, latencyMS int) {
+	if len(groups) == 0 {
+		return
+	}
+	last := &groups[len(groups)-1]
+	if last.Calls > 0 {
+		last.AvgLatencyMS = latencyMS / last.Calls
+	}
+}
+
+// parseUsageTime accepts RFC 3339 timestamps and YYYY-MM-DD dates, adding
+// dateOffset to dates
+func parseUsageTime(value string, dateOffset time.Duration) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected YYYY-MM-DD or RFC 3339, got %q", value)
+	}
+	return t.Add(dateOffset), nil
+}
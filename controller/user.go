@@ -2,6 +2,7 @@ package controller
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 	"time"
 
@@ -19,17 +20,24 @@ func NewUserController(db *sql.DB) *UserController {
 }
 
 // @Summary Get all users
-// @Description Retrieve all users or a specific user by ID
+// @Description Retrieve a page of users or a specific user by ID
 // @Tags users
 // @Produce json
 // @Param id query string false "User ID to fetch a specific user"
-// @Success 200 {object} map[string]interface{} "Success response with users data"
+// @Param limit query int false "Users per page, at most 100" default(20)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Sort by created_at or updated_at" default(created_at)
+// @Param order query string false "asc or desc" default(asc)
+// @Param from query string false "Earliest sort time, YYYY-MM-DD or RFC 3339"
+// @Param to query string false "Latest sort time, YYYY-MM-DD (inclusive) or RFC 3339"
+// @Success 200 {object} model.UserList "Success response with users data"
+// @Failure 400 {object} map[string]interface{} "Invalid page request"
 // @Failure 404 {object} map[string]interface{} "User not found"
 // @Failure 500 {object} map[string]interface{} "Failed to fetch users"
 // @Router /users [get]
 func (u *UserController) GetUsers(ctx *gin.Context) {
 	userID := ctx.Query("id")
-	repo := repository.NewUserRepository(u.DB)
+	repo := newUserRepository(u.DB)
 
 	if userID != "" {
 		// Fetch specific user by ID
@@ -46,13 +54,22 @@ func (u *UserController) GetUsers(ctx *gin.Context) {
 		return
 	}
 
-	// Fetch all users
-	users, err := repo.GetAllUsers()
+	// Fetch a page of users
+	page, err := parsePage(ctx, "created_at", "asc")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": err.Error()})
+		return
+	}
+	users, next, err := repo.GetAllUsers(page)
+	if errors.Is(err, repository.ErrInvalidPage) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"status": "failed", "message": "Failed to fetch users"})
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": users})
+	ctx.JSON(http.StatusOK, model.UserList{Status: "success", Data: users, NextCursor: next})
 }
 
 // @Summary Create a new user
@@ -88,7 +105,7 @@ func (u *UserController) CreateUser(ctx *gin.Context) {
 	}
 
 	// Save to the database
-	repo := repository.NewUserRepository(u.DB)
+	repo := newUserRepository(u.DB)
 	if err := repo.CreateUser(&newUser); err != nil {
 		// Check for duplicate email error
 		if err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"` {
@@ -30,7 +30,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/event-stream",
+                    "application/geo+json"
                 ],
                 "tags": [
                     "chat"
@@ -50,6 +52,18 @@ const docTemplate = `{
                         "name": "uuid",
                         "in": "query"
                     },
+                    {
+                        "type": "boolean",
+                        "description": "Stream the reply as server-sent events",
+                        "name": "stream",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Set to geojson to get the answer as a GeoJSON FeatureCollection",
+                        "name": "format",
+                        "in": "query"
+                    },
                     {
                         "description": "Chat request body",
                         "name": "requestBody",
@@ -64,8 +78,7 @@ const docTemplate = `{
                     "200": {
                         "description": "Chat response",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/model.ChatResponse"
                         }
                     },
                     "400": {
@@ -82,19 +95,47 @@ const docTemplate = `{
                             "additionalProperties": true
                         }
                     },
+                    "409": {
+                        "description": "Conversation was updated concurrently",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit or token quota exceeded",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
+                    },
+                    "503": {
+                        "description": "LLM provider is temporarily unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "504": {
+                        "description": "LLM provider timed out",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     }
                 }
             }
         },
         "/conversations": {
             "get": {
-                "description": "List all conversations for a user",
+                "description": "List a page of the conversations of a user with their titles and summaries, without the chat history",
                 "produces": [
                     "application/json"
                 ],
@@ -109,15 +150,127 @@ const docTemplate = `{
                         "name": "user_id",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Conversations per page, at most 100",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "updated_at",
+                        "description": "Sort by created_at or updated_at",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "desc",
+                        "description": "asc or desc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest sort time, YYYY-MM-DD or RFC 3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Latest sort time, YYYY-MM-DD (inclusive) or RFC 3339",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "List the archived conversations instead",
+                        "name": "archived",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only pinned or only unpinned conversations",
+                        "name": "pinned",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only conversations with this tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only conversations with or without geocoded locations",
+                        "name": "has_locations",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "List of conversations",
+                        "description": "Page of conversations, empty when there are none",
+                        "schema": {
+                            "$ref": "#/definitions/model.ConversationList"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
+                    }
+                }
+            }
+        },
+        "/conversations/{uuid}": {
+            "get": {
+                "description": "Get a conversation with the chat history of its active branch",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "conversations"
+                ],
+                "summary": "Get a conversation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID of the conversation",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID owning the conversation",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Conversation",
+                        "schema": {
+                            "$ref": "#/definitions/model.Conversation"
+                        }
                     },
                     "400": {
                         "description": "Invalid request",
@@ -127,7 +280,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "No conversations found",
+                        "description": "Conversation not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -141,43 +294,56 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/users": {
-            "get": {
-                "description": "Retrieve all users or a specific user by ID",
+            },
+            "delete": {
+                "description": "Hide a conversation from every endpoint until it is restored",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "conversations"
                 ],
-                "summary": "Get all users",
+                "summary": "Delete a conversation",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User ID to fetch a specific user",
-                        "name": "id",
-                        "in": "query"
+                        "description": "UUID of the conversation",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID owning the conversation",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Success response with users data",
+                        "description": "Conversation deleted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
                     "404": {
-                        "description": "User not found",
+                        "description": "Conversation not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
                     "500": {
-                        "description": "Failed to fetch users",
+                        "description": "Internal server error",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -185,8 +351,8 @@ const docTemplate = `{
                     }
                 }
             },
-            "post": {
-                "description": "Add a new user to the database",
+            "patch": {
+                "description": "Change the title, summary, pin, archive flag, tags or default chat settings of a conversation",
                 "consumes": [
                     "application/json"
                 ],
@@ -194,36 +360,57 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "conversations"
                 ],
-                "summary": "Create a new user",
+                "summary": "Update a conversation",
                 "parameters": [
                     {
-                        "description": "New user data",
-                        "name": "user",
+                        "type": "string",
+                        "description": "UUID of the conversation",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID owning the conversation",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "conversation",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.CreateUserRequest"
+                            "$ref": "#/definitions/model.UpdateConversationRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "User successfully created",
+                    "200": {
+                        "description": "Updated conversation",
                         "schema": {
-                            "$ref": "#/definitions/model.User"
+                            "$ref": "#/definitions/model.Conversation"
                         }
                     },
                     "400": {
-                        "description": "Invalid request data",
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Conversation not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
                     "500": {
-                        "description": "Failed to create user",
+                        "description": "Internal server error",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -231,37 +418,2101 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "model.ChatRequest": {
-            "type": "object",
-            "required": [
-                "content"
-            ],
-            "properties": {
-                "content": {
-                    "description": "Content is the user's input to the chat",
-                    "type": "string"
+        },
+        "/conversations/{uuid}/branch": {
+            "put": {
+                "description": "Continue the conversation on the branch through a message, ending at its newest reply",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "conversations"
+                ],
+                "summary": "Switch branch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID of the conversation",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID owning the conversation",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "description": "Message on the branch",
+                        "name": "branch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.BranchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Conversation with the new active branch",
+                        "schema": {
+                            "$ref": "#/definitions/model.Conversation"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Conversation or message not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conversation was updated concurrently",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
                 }
             }
         },
-        "model.CreateUserRequest": {
-            "type": "object",
-            "required": [
-                "email",
-                "password",
-                "username"
-            ],
-            "properties": {
-                "email": {
-                    "type": "string"
-                },
-                "password": {
-                    "type": "string"
+        "/conversations/{uuid}/export": {
+            "get": {
+                "description": "Download the active branch of a conversation as a Markdown or JSON transcript, or its geocoded locations as GeoJSON, KML or GPX with the message text as descriptions",
+                "produces": [
+                    "text/markdown",
+                    "application/json",
+                    "application/geo+json",
+                    "application/vnd.google-earth.kml+xml",
+                    "application/gpx+xml"
+                ],
+                "tags": [
+                    "conversations"
+                ],
+                "summary": "Export a conversation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID of the conversation",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID owning the conversation",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "default": "md",
+                        "description": "md, json, geojson, kml or gpx",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Exported conversation",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Conversation not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/conversations/{uuid}/fork": {
+            "post": {
+                "description": "Copy the branch up to a message into a new conversation",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "conversations"
+                ],
+                "summary": "Fork a conversation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID of the conversation",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID owning the conversation",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "description": "Last message to copy",
+                        "name": "branch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.BranchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "New conversation",
+                        "schema": {
+                            "$ref": "#/definitions/model.Conversation"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Conversation or message not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/conversations/{uuid}/locations": {
+            "get": {
+                "description": "Get the geocoded locations of a conversation as a GeoJSON FeatureCollection",
+                "produces": [
+                    "application/geo+json"
+                ],
+                "tags": [
+                    "conversations"
+                ],
+                "summary": "Get conversation locations",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID of the conversation",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID owning the conversation",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "GeoJSON FeatureCollection",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Conversation not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/conversations/{uuid}/messages": {
+            "get": {
+                "description": "List a page of the messages of the active branch of a conversation, oldest first by default",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "conversations"
+                ],
+                "summary": "List conversation messages",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID of the conversation",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID owning the conversation",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Messages per page, at most 100",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "asc",
+                        "description": "asc or desc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest creation time, YYYY-MM-DD or RFC 3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Latest creation time, YYYY-MM-DD (inclusive) or RFC 3339",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Page of messages",
+                        "schema": {
+                            "$ref": "#/definitions/model.MessagePage"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Conversation not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/conversations/{uuid}/messages/{id}/edit": {
+            "post": {
+                "description": "Send a new version of an earlier user message. It starts a new branch next to the original, which stays available.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/event-stream",
+                    "application/geo+json"
+                ],
+                "tags": [
+                    "chat"
+                ],
+                "summary": "Edit a user message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID of the conversation",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID of the user message to edit",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID owning the conversation",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Stream the reply as server-sent events",
+                        "name": "stream",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Set to geojson to get the answer as a GeoJSON FeatureCollection",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "description": "New message content",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.EditMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Chat response",
+                        "schema": {
+                            "$ref": "#/definitions/model.ChatResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Conversation or message not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conversation was updated concurrently",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit or token quota exceeded",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "LLM provider is temporarily unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "504": {
+                        "description": "LLM provider timed out",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/conversations/{uuid}/regenerate": {
+            "post": {
+                "description": "Generate an alternative reply to the last user message of the active branch. The previous reply is kept on its own branch.",
+                "produces": [
+                    "application/json",
+                    "text/event-stream",
+                    "application/geo+json"
+                ],
+                "tags": [
+                    "chat"
+                ],
+                "summary": "Regenerate the last reply",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID of the conversation",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID owning the conversation",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Stream the reply as server-sent events",
+                        "name": "stream",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Set to geojson to get the answer as a GeoJSON FeatureCollection",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Chat response",
+                        "schema": {
+                            "$ref": "#/definitions/model.ChatResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Conversation not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "No reply to regenerate",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit or token quota exceeded",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "LLM provider is temporarily unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "504": {
+                        "description": "LLM provider timed out",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/conversations/{uuid}/restore": {
+            "post": {
+                "description": "Bring back a deleted conversation",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "conversations"
+                ],
+                "summary": "Restore a conversation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID of the conversation",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID owning the conversation",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Restored conversation",
+                        "schema": {
+                            "$ref": "#/definitions/model.Conversation"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "No deleted conversation found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/conversations/{uuid}/retry": {
+            "post": {
+                "description": "Re-run the last user message of a conversation after its reply failed, or after it was left pending for longer than LLM_TIMEOUT, without sending the content again",
+                "produces": [
+                    "application/json",
+                    "text/event-stream",
+                    "application/geo+json"
+                ],
+                "tags": [
+                    "chat"
+                ],
+                "summary": "Retry a failed chat turn",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID of the conversation",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID owning the conversation",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Stream the reply as server-sent events",
+                        "name": "stream",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Set to geojson to get the answer as a GeoJSON FeatureCollection",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Chat response",
+                        "schema": {
+                            "$ref": "#/definitions/model.ChatResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Conversation not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "No failed turn to retry",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit or token quota exceeded",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "LLM provider is temporarily unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "504": {
+                        "description": "LLM provider timed out",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/models": {
+            "get": {
+                "description": "List the models clients may select in chat requests and their limits",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "models"
+                ],
+                "summary": "List models",
+                "responses": {
+                    "200": {
+                        "description": "List of models",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/model.ModelInfo"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/prompts": {
+            "get": {
+                "description": "List the personas available for new conversations with their active prompt version",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "prompts"
+                ],
+                "summary": "List personas",
+                "responses": {
+                    "200": {
+                        "description": "List of personas",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/model.Prompt"
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Create a persona with its first prompt version. Requires the admin token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "prompts"
+                ],
+                "summary": "Create a persona",
+                "parameters": [
+                    {
+                        "description": "New persona",
+                        "name": "prompt",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.CreatePromptRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created prompt version",
+                        "schema": {
+                            "$ref": "#/definitions/model.Prompt"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request data",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Persona already exists",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/prompts/{name}": {
+            "get": {
+                "description": "List all prompt versions of a persona, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "prompts"
+                ],
+                "summary": "List persona versions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Persona name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Prompt versions",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/model.Prompt"
+                                }
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Persona not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Add a new prompt version to a persona and make it active. Requires the admin token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "prompts"
+                ],
+                "summary": "Update a persona",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Persona name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New prompt version",
+                        "name": "prompt",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.UpdatePromptRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Created prompt version",
+                        "schema": {
+                            "$ref": "#/definitions/model.Prompt"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request data",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Persona not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Persona was updated concurrently",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Hide a persona from new conversations. Its versions are kept for existing conversations and can be restored with a rollback. Requires the admin token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "prompts"
+                ],
+                "summary": "Delete a persona",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Persona name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Persona deleted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Persona not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/prompts/{name}/rollback": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Make an earlier prompt version of a persona active again. Requires the admin token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "prompts"
+                ],
+                "summary": "Roll back a persona",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Persona name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Version to activate",
+                        "name": "rollback",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.RollbackPromptRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Active prompt version",
+                        "schema": {
+                            "$ref": "#/definitions/model.Prompt"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request data",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Prompt version not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/search": {
+            "get": {
+                "description": "Full-text search over the messages, titles and summaries of a user's conversations. Supports quoted phrases, OR and -word. Snippets are HTML escaped with the matched words wrapped in \u003cmark\u003e tags.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "conversations"
+                ],
+                "summary": "Search conversations",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID whose conversations are searched",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Search query, e.g. flood zones Jakarta",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Conversations to return, at most 100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Matching conversations, best first",
+                        "schema": {
+                            "$ref": "#/definitions/model.SearchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/usage": {
+            "get": {
+                "description": "Aggregate the LLM token usage, latency and estimated cost of a user per day or model",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "usage"
+                ],
+                "summary": "Get usage report",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID to report on",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of the range as YYYY-MM-DD or RFC 3339, defaults to 30 days before to",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the range as YYYY-MM-DD (inclusive) or RFC 3339, defaults to now",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "day",
+                            "model"
+                        ],
+                        "type": "string",
+                        "default": "day",
+                        "description": "Group by day or model",
+                        "name": "group_by",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Usage report",
+                        "schema": {
+                            "$ref": "#/definitions/model.UsageReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/users": {
+            "get": {
+                "description": "Retrieve a page of users or a specific user by ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get all users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID to fetch a specific user",
+                        "name": "id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Users per page, at most 100",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "created_at",
+                        "description": "Sort by created_at or updated_at",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "asc",
+                        "description": "asc or desc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest sort time, YYYY-MM-DD or RFC 3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Latest sort time, YYYY-MM-DD (inclusive) or RFC 3339",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success response with users data",
+                        "schema": {
+                            "$ref": "#/definitions/model.UserList"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid page request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch users",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add a new user to the database",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Create a new user",
+                "parameters": [
+                    {
+                        "description": "New user data",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.CreateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "User successfully created",
+                        "schema": {
+                            "$ref": "#/definitions/model.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request data",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to create user",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "model.BranchRequest": {
+            "type": "object",
+            "required": [
+                "message_id"
+            ],
+            "properties": {
+                "message_id": {
+                    "type": "integer",
+                    "example": 42
+                }
+            }
+        },
+        "model.ChatRequest": {
+            "type": "object",
+            "required": [
+                "content"
+            ],
+            "properties": {
+                "cache": {
+                    "description": "Cache set to false keeps context-dependent turns out of the response cache",
+                    "type": "boolean"
+                },
+                "content": {
+                    "description": "Content is the user's input to the chat",
+                    "type": "string"
+                },
+                "max_tokens": {
+                    "type": "integer",
+                    "example": 1024
+                },
+                "model": {
+                    "description": "Model must be one of the models listed by GET /models",
+                    "type": "string",
+                    "example": "llama-3.3-70b-versatile"
+                },
+                "persona": {
+                    "description": "Persona selects the system prompt when a conversation starts, see\nGET /prompts. It is ignored for existing conversations.",
+                    "type": "string",
+                    "example": "geo-planner"
+                },
+                "seed": {
+                    "type": "integer"
+                },
+                "stop": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "temperature": {
+                    "type": "number",
+                    "example": 0.7
+                },
+                "top_p": {
+                    "type": "number",
+                    "example": 1
+                }
+            }
+        },
+        "model.ChatResponse": {
+            "type": "object",
+            "properties": {
+                "answer": {
+                    "description": "Answer is the structured form of the reply, omitted when it could not be parsed",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.GeoAnswer"
+                        }
+                    ]
+                },
+                "conversation_id": {
+                    "description": "ConversationID is the UUID to continue the conversation with",
+                    "type": "string"
+                },
+                "response": {
+                    "description": "Response is the assistant's reply",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Message"
+                        }
+                    ]
+                }
+            }
+        },
+        "model.ChatSettings": {
+            "type": "object",
+            "properties": {
+                "cache": {
+                    "description": "Cache set to false keeps context-dependent turns out of the response cache",
+                    "type": "boolean"
+                },
+                "max_tokens": {
+                    "type": "integer",
+                    "example": 1024
+                },
+                "model": {
+                    "description": "Model must be one of the models listed by GET /models",
+                    "type": "string",
+                    "example": "llama-3.3-70b-versatile"
+                },
+                "seed": {
+                    "type": "integer"
+                },
+                "stop": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "temperature": {
+                    "type": "number",
+                    "example": 0.7
+                },
+                "top_p": {
+                    "type": "number",
+                    "example": 1
+                }
+            }
+        },
+        "model.Conversation": {
+            "type": "object",
+            "properties": {
+                "active_message_id": {
+                    "description": "ActiveMessageID is the last message of the active branch",
+                    "type": "integer"
+                },
+                "archived": {
+                    "type": "boolean"
+                },
+                "chat_history": {
+                    "description": "ChatHistory is the active branch from the system prompt to\nActiveMessageID. It is not loaded for conversation lists.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Message"
+                    }
+                },
+                "conversation_id": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "persona": {
+                    "type": "string"
+                },
+                "pinned": {
+                    "description": "Archived conversations are only listed on request",
+                    "type": "boolean"
+                },
+                "prompt_version": {
+                    "type": "integer"
+                },
+                "settings": {
+                    "description": "Settings are the model and sampling parameters used for every turn",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.ChatSettings"
+                        }
+                    ]
+                },
+                "summary": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.ConversationList": {
+            "type": "object",
+            "properties": {
+                "conversations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ConversationListItem"
+                    }
+                },
+                "next_cursor": {
+                    "description": "NextCursor fetches the following page, omitted on the last page",
+                    "type": "string"
+                }
+            }
+        },
+        "model.ConversationListItem": {
+            "type": "object",
+            "properties": {
+                "archived": {
+                    "type": "boolean"
+                },
+                "conversation_id": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "persona": {
+                    "type": "string"
+                },
+                "pinned": {
+                    "type": "boolean"
+                },
+                "summary": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.CreatePromptRequest": {
+            "type": "object",
+            "required": [
+                "content",
+                "name"
+            ],
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "geo-planner"
+                }
+            }
+        },
+        "model.CreateUserRequest": {
+            "type": "object",
+            "required": [
+                "email",
+                "password",
+                "username"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.EditMessageRequest": {
+            "type": "object",
+            "required": [
+                "content"
+            ],
+            "properties": {
+                "cache": {
+                    "description": "Cache set to false keeps context-dependent turns out of the response cache",
+                    "type": "boolean"
                 },
-                "username": {
+                "content": {
+                    "description": "Content is the new user input",
+                    "type": "string"
+                },
+                "max_tokens": {
+                    "type": "integer",
+                    "example": 1024
+                },
+                "model": {
+                    "description": "Model must be one of the models listed by GET /models",
+                    "type": "string",
+                    "example": "llama-3.3-70b-versatile"
+                },
+                "seed": {
+                    "type": "integer"
+                },
+                "stop": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "temperature": {
+                    "type": "number",
+                    "example": 0.7
+                },
+                "top_p": {
+                    "type": "number",
+                    "example": 1
+                }
+            }
+        },
+        "model.GeoAnswer": {
+            "type": "object",
+            "properties": {
+                "locations": {
+                    "description": "Locations are the key places mentioned in the reply",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "message": {
+                    "description": "Message is the detailed response to the user's query",
+                    "type": "string"
+                }
+            }
+        },
+        "model.Location": {
+            "type": "object",
+            "properties": {
+                "confidence": {
+                    "description": "Confidence is 1 for a match on the place's main name, lower for\nalternate names and 0 when unresolved",
+                    "type": "number"
+                },
+                "name": {
+                    "description": "Name is the location as written by the model",
+                    "type": "string"
+                },
+                "place": {
+                    "description": "Place is the gazetteer match, nil when the name could not be resolved",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Place"
+                        }
+                    ]
+                }
+            }
+        },
+        "model.Message": {
+            "type": "object",
+            "properties": {
+                "completion_tokens": {
+                    "type": "integer"
+                },
+                "content": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "forked_from_id": {
+                    "description": "ForkedFromID is the original of a message copied by a fork",
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "latency_ms": {
+                    "type": "integer"
+                },
+                "locations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Location"
+                    }
+                },
+                "model": {
+                    "type": "string"
+                },
+                "parent_id": {
+                    "description": "ParentID is the message this one follows, 0 for the system prompt",
+                    "type": "integer"
+                },
+                "prompt_tokens": {
+                    "type": "integer"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "sibling_ids": {
+                    "description": "SiblingIDs lists the alternatives sharing the parent, including this\nmessage, when there is more than one",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "status": {
+                    "description": "Status tracks whether a user message got its reply, Error holds the\nfailure detail of failed turns",
+                    "type": "string",
+                    "example": "answered"
+                },
+                "tool_call_id": {
+                    "description": "ToolCallID links a tool result message to its call",
+                    "type": "string"
+                },
+                "tool_calls": {
+                    "description": "ToolCalls are the tools an assistant message asked to run",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ToolCall"
+                    }
+                }
+            }
+        },
+        "model.MessagePage": {
+            "type": "object",
+            "properties": {
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Message"
+                    }
+                },
+                "next_cursor": {
+                    "description": "NextCursor fetches the following page, omitted on the last page",
+                    "type": "string"
+                }
+            }
+        },
+        "model.ModelInfo": {
+            "type": "object",
+            "properties": {
+                "context_window": {
+                    "description": "ContextWindow and MaxOutputTokens are in tokens, 0 when unknown",
+                    "type": "integer"
+                },
+                "default": {
+                    "type": "boolean"
+                },
+                "max_output_tokens": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.Place": {
+            "type": "object",
+            "properties": {
+                "admin1": {
+                    "type": "string"
+                },
+                "admin1_code": {
+                    "type": "string"
+                },
+                "ascii_name": {
+                    "type": "string"
+                },
+                "country": {
+                    "type": "string"
+                },
+                "country_code": {
+                    "type": "string"
+                },
+                "feature_class": {
+                    "type": "string"
+                },
+                "feature_code": {
+                    "type": "string"
+                },
+                "geoname_id": {
+                    "type": "integer"
+                },
+                "latitude": {
+                    "type": "number"
+                },
+                "longitude": {
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "population": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.Prompt": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "Active marks the version used for new conversations",
+                    "type": "boolean"
+                },
+                "content": {
+                    "description": "Content is the persona's instructions, the answer format is added by the server",
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "geo-planner"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.RollbackPromptRequest": {
+            "type": "object",
+            "required": [
+                "version"
+            ],
+            "properties": {
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.SearchMatch": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "message_id": {
+                    "type": "integer"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "snippet": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.SearchResponse": {
+            "type": "object",
+            "properties": {
+                "query": {
+                    "type": "string"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.SearchResult"
+                    }
+                }
+            }
+        },
+        "model.SearchResult": {
+            "type": "object",
+            "properties": {
+                "conversation_id": {
+                    "type": "string"
+                },
+                "matches": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.SearchMatch"
+                    }
+                },
+                "rank": {
+                    "type": "number"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "title_highlight": {
+                    "description": "TitleHighlight marks the matched words of the title and summary,\nempty when only messages matched",
+                    "type": "string"
+                }
+            }
+        },
+        "model.ToolCall": {
+            "type": "object",
+            "properties": {
+                "arguments": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.UpdateConversationRequest": {
+            "type": "object",
+            "properties": {
+                "archived": {
+                    "type": "boolean"
+                },
+                "pinned": {
+                    "type": "boolean"
+                },
+                "settings": {
+                    "description": "Settings set here replace the conversation defaults, e.g.\n{\"cache\": false} keeps later turns out of the response cache",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.ChatSettings"
+                        }
+                    ]
+                },
+                "summary": {
+                    "type": "string"
+                },
+                "tags": {
+                    "description": "Tags replace the existing tags",
+                    "type": "array",
+                    "maxItems": 20,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "title": {
+                    "type": "string",
+                    "maxLength": 200
+                }
+            }
+        },
+        "model.UpdatePromptRequest": {
+            "type": "object",
+            "required": [
+                "content"
+            ],
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.UsageGroup": {
+            "type": "object",
+            "properties": {
+                "avg_latency_ms": {
+                    "type": "integer"
+                },
+                "calls": {
+                    "type": "integer"
+                },
+                "completion_tokens": {
+                    "type": "integer"
+                },
+                "estimated_cost": {
+                    "type": "number"
+                },
+                "key": {
+                    "description": "Key is the day as YYYY-MM-DD or the model name",
                     "type": "string"
+                },
+                "prompt_tokens": {
+                    "type": "integer"
+                },
+                "total_tokens": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.UsageReport": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "description": "Currency of the estimated costs",
+                    "type": "string",
+                    "example": "USD"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "group_by": {
+                    "type": "string",
+                    "example": "day"
+                },
+                "groups": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.UsageGroup"
+                    }
+                },
+                "to": {
+                    "type": "string"
+                },
+                "total": {
+                    "$ref": "#/definitions/model.UsageGroup"
+                },
+                "unpriced_models": {
+                    "description": "UnpricedModels have no price configured and count as free",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "user_id": {
+                    "type": "integer"
                 }
             }
         },
@@ -287,6 +2538,33 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "model.UserList": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.User"
+                    }
+                },
+                "next_cursor": {
+                    "description": "NextCursor fetches the following page, omitted on the last page",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "success"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "AdminToken": {
+            "description": "Admin token as \"Bearer \u003cADMIN_TOKEN\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -0,0 +1,129 @@
+// Package export writes conversations as transcripts and geo files. Every
+// format streams the active branch of a loaded conversation to a writer.
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"unicode"
+
+	"geoai-app/geoanswer"
+	"geoai-app/geojson"
+	"geoai-app/model"
+)
+
+// Format is a supported export format
+type Format struct {
+	Name        string
+	ContentType string
+	Extension   string
+	write       func(w io.Writer, conversation *model.Conversation) error
+}
+
+var formats = map[string]Format{
+	"md":      {Name: "md", ContentType: "text/markdown; charset=utf-8", Extension: "md", write: writeMarkdown},
+	"json":    {Name: "json", ContentType: "application/json; charset=utf-8", Extension: "json", write: writeJSON},
+	"geojson": {Name: "geojson", ContentType: geojson.ContentType, Extension: "geojson", write: writeGeoJSON},
+	"kml":     {Name: "kml", ContentType: "application/vnd.google-earth.kml+xml", Extension: "kml", write: writeKML},
+	"gpx":     {Name: "gpx", ContentType: "application/gpx+xml", Extension: "gpx", write: writeGPX},
+}
+
+// Lookup returns the format with the given name
+func Lookup(name string) (Format, bool) {
+	format, ok := formats[name]
+	return format, ok
+}
+
+// Write streams the conversation in the format
+func (f Format) Write(w io.Writer, conversation *model.Conversation) error {
+	return f.write(w, conversation)
+}
+
+// FileName names the export after the conversation title, or its UUID when
+// it has none
+func (f Format) FileName(conversation *model.Conversation) string {
+	name := slug(conversation.Title)
+	if name == "" {
+		name = "conversation-" + conversation.ConversationID
+	}
+	return name + "." + f.Extension
+}
+
+// title returns the conversation title or a placeholder
+func title(conversation *model.Conversation) string {
+	if conversation.Title != "" {
+		return conversation.Title
+	}
+	return "Conversation " + conversation.ConversationID
+}
+
+// messageText returns the readable text of a message, the reply of
+// structured answers rather than their JSON
+func messageText(message model.Message) string {
+	if message.Role == "assistant" {
+		if answer, err := geoanswer.Parse(message.Content); err == nil {
+			return answer.Message
+		}
+	}
+	return message.Content
+}
+
+// resolved returns the locations of a message that have coordinates
+func resolved(message model.Message) []model.Location {
+	var locations []model.Location
+	for _, location := range message.Locations {
+		if location.Place != nil {
+			locations = append(locations, location)
+		}
+	}
+	return locations
+}
+
+// slug turns a title into a lowercase ASCII file name
+func slug(text string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= 60 {
+			break
+		}
+	}
+	return b.String()
+}
+
+// writeJSON writes the full conversation with the messages of its active
+// branch
+func writeJSON(w io.Writer, conversation *model.Conversation) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(conversation)
+}
+
+// writeGeoJSON writes the resolved locations as point features described by
+// the text of their message
+func writeGeoJSON(w io.Writer, conversation *model.Conversation) error {
+	collection := geojson.NewFeatureCollection()
+	collection.ForeignMembers["conversation_id"] = conversation.ConversationID
+	collection.ForeignMembers["title"] = title(conversation)
+	for _, message := range conversation.ChatHistory {
+		added := len(collection.Features)
+		collection.AddMessage(message)
+		for i := added; i < len(collection.Features); i++ {
+			collection.Features[i].Properties["description"] = messageText(message)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(collection)
+}
@@ -0,0 +1,58 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"geoai-app/model"
+)
+
+// markdownTime formats message times in transcripts
+const markdownTime = "2006-01-02 15:04 UTC"
+
+// writeMarkdown writes a readable transcript of the user and assistant
+// messages. System prompts and tool calls are left out.
+func writeMarkdown(w io.Writer, conversation *model.Conversation) error {
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintf(out, "# %s\n\n", title(conversation))
+	fmt.Fprintf(out, "- Conversation: `%s`\n", conversation.ConversationID)
+	if conversation.Persona != "" {
+		fmt.Fprintf(out, "- Persona: %s (version %d)\n", conversation.Persona, conversation.PromptVersion)
+	}
+	fmt.Fprintf(out, "- Started: %s\n", conversation.CreatedAt.UTC().Format(markdownTime))
+	if len(conversation.Tags) > 0 {
+		fmt.Fprintf(out, "- Tags: %s\n", strings.Join(conversation.Tags, ", "))
+	}
+	if conversation.Summary != "" {
+		fmt.Fprintf(out, "\n%s\n", conversation.Summary)
+	}
+
+	for _, message := range conversation.ChatHistory {
+		text := messageText(message)
+		if (message.Role != "user" && message.Role != "assistant") || text == "" {
+			continue
+		}
+
+		heading := "User"
+		if message.Role == "assistant" {
+			heading = "Assistant"
+		}
+		fmt.Fprintf(out, "\n## %s · %s\n\n%s\n", heading, message.CreatedAt.UTC().Format(markdownTime), text)
+		if message.Status == model.MessageFailed {
+			fmt.Fprintf(out, "\n_No reply: %s_\n", message.Error)
+		}
+
+		if locations := resolved(message); len(locations) > 0 {
+			fmt.Fprint(out, "\n**Locations**\n\n")
+			for _, location := range locations {
+				place := location.Place
+				fmt.Fprintf(out, "- %s (%s, %s): %.5f, %.5f\n", location.Name, place.Name, place.Country, place.Latitude, place.Longitude)
+			}
+		}
+	}
+
+	return out.Flush()
+}
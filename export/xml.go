@@ -0,0 +1,127 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+
+	"geoai-app/model"
+)
+
+// kmlPlacemark is a KML point placemark
+type kmlPlacemark struct {
+	XMLName     xml.Name `xml:"Placemark"`
+	Name        string   `xml:"name"`
+	Description string   `xml:"description"`
+	Coordinates string   `xml:"Point>coordinates"`
+}
+
+// gpxWaypoint is a GPX 1.1 waypoint
+type gpxWaypoint struct {
+	XMLName     xml.Name `xml:"wpt"`
+	Latitude    float64  `xml:"lat,attr"`
+	Longitude   float64  `xml:"lon,attr"`
+	Time        string   `xml:"time,omitempty"`
+	Name        string   `xml:"name"`
+	Description string   `xml:"desc"`
+}
+
+// writeKML writes the resolved locations as KML placemarks for Google Earth
+// and QGIS
+func writeKML(w io.Writer, conversation *model.Conversation) error {
+	document := xml.StartElement{Name: xml.Name{Local: "Document"}}
+	return writeXML(w,
+		xml.StartElement{Name: xml.Name{Local: "kml"}, Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: "http://www.opengis.net/kml/2.2"}}},
+		func(encoder *xml.Encoder) error {
+			if err := encoder.EncodeToken(document); err != nil {
+				return err
+			}
+			if err := encoder.EncodeElement(title(conversation), xml.StartElement{Name: xml.Name{Local: "name"}}); err != nil {
+				return err
+			}
+			if conversation.Summary != "" {
+				if err := encoder.EncodeElement(conversation.Summary, xml.StartElement{Name: xml.Name{Local: "description"}}); err != nil {
+					return err
+				}
+			}
+			err := eachLocation(conversation, func(message model.Message, location model.Location) error {
+				return encoder.Encode(kmlPlacemark{
+					Name:        location.Name,
+					Description: messageText(message),
+					// KML orders coordinates as lon,lat
+					Coordinates: fmt.Sprintf("%f,%f", location.Place.Longitude, location.Place.Latitude),
+				})
+			})
+			if err != nil {
+				return err
+			}
+			return encoder.EncodeToken(document.End())
+		})
+}
+
+// writeGPX writes the resolved locations as GPX waypoints
+func writeGPX(w io.Writer, conversation *model.Conversation) error {
+	return writeXML(w,
+		xml.StartElement{Name: xml.Name{Local: "gpx"}, Attr: []xml.Attr{
+			{Name: xml.Name{Local: "version"}, Value: "1.1"},
+			{Name: xml.Name{Local: "creator"}, Value: "GeoAI App"},
+			{Name: xml.Name{Local: "xmlns"}, Value: "http://www.topografix.com/GPX/1/1"},
+		}},
+		func(encoder *xml.Encoder) error {
+			metadata := struct {
+				XMLName xml.Name `xml:"metadata"`
+				Name    string   `xml:"name"`
+				Desc    string   `xml:"desc,omitempty"`
+				Time    string   `xml:"time"`
+			}{Name: title(conversation), Desc: conversation.Summary, Time: conversation.CreatedAt.UTC().Format(time.RFC3339)}
+			if err := encoder.Encode(metadata); err != nil {
+				return err
+			}
+			return eachLocation(conversation, func(message model.Message, location model.Location) error {
+				return encoder.Encode(gpxWaypoint{
+					Latitude:    location.Place.Latitude,
+					Longitude:   location.Place.Longitude,
+					Time:        message.CreatedAt.UTC().Format(time.RFC3339),
+					Name:        location.Name,
+					Description: messageText(message),
+				})
+			})
+		})
+}
+
+// writeXML writes the XML declaration and the root element around the
+// content, flushing as it goes so large exports are streamed
+func writeXML(w io.Writer, root xml.StartElement, content func(encoder *xml.Encoder) error) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.EncodeToken(root); err != nil {
+		return err
+	}
+	if err := content(encoder); err != nil {
+		return err
+	}
+	if err := encoder.EncodeToken(root.End()); err != nil {
+		return err
+	}
+	if err := encoder.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// eachLocation calls fn for every resolved location on the active branch
+func eachLocation(conversation *model.Conversation, fn func(message model.Message, location model.Location) error) error {
+	for _, message := range conversation.ChatHistory {
+		for _, location := range resolved(message) {
+			if err := fn(message, location); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
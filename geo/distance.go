@@ -0,0 +1,130 @@
+package geo
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrNoConvergence is returned when Vincenty's formula does not converge,
+// which happens for nearly antipodal points
+var ErrNoConvergence = errors.New("vincenty formula failed to converge")
+
+// HaversineDistance returns the great-circle distance between two points on
+// a sphere of MeanRadius
+func HaversineDistance(a, b Point) float64 {
+	return MeanRadius * centralAngle(a, b)
+}
+
+// centralAngle returns the angle between two points seen from the earth's centre
+func centralAngle(a, b Point) float64 {
+	lat1, lat2 := toRadians(a.Latitude), toRadians(b.Latitude)
+	dLat := lat2 - lat1
+	dLon := toRadians(b.Longitude - a.Longitude)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// VincentyDistance returns the distance between two points on the WGS84
+// ellipsoid using Vincenty's inverse formula, accurate to within millimetres
+func VincentyDistance(a, b Point) (float64, error) {
+	if a == b {
+		return 0, nil
+	}
+
+	f := Flattening
+	L := toRadians(b.Longitude - a.Longitude)
+	U1 := math.Atan((1 - f) * math.Tan(toRadians(a.Latitude)))
+	U2 := math.Atan((1 - f) * math.Tan(toRadians(b.Latitude)))
+	sinU1, cosU1 := math.Sin(U1), math.Cos(U1)
+	sinU2, cosU2 := math.Sin(U2), math.Cos(U2)
+
+	lambda := L
+	var sinSigma, cosSigma, sigma, cosSqAlpha, cos2SigmaM float64
+	for i := 0; i < 200; i++ {
+		sinLambda, cosLambda := math.Sin(lambda), math.Cos(lambda)
+		sinSigma = math.Sqrt((cosU2*sinLambda)*(cosU2*sinLambda) +
+			(cosU1*sinU2-sinU1*cosU2*cosLambda)*(cosU1*sinU2-sinU1*cosU2*cosLambda))
+		if sinSigma == 0 {
+			return 0, nil // Coincident points
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cosSqAlpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0
+		if cosSqAlpha != 0 {
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cosSqAlpha // Zero on the equator
+		}
+		C := f / 16 * cosSqAlpha * (4 + f*(4-3*cosSqAlpha))
+		previous := lambda
+		lambda = L + (1-C)*f*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-previous) < 1e-12 {
+			uSq := cosSqAlpha * (SemiMajorAxis*SemiMajorAxis - SemiMinorAxis*SemiMinorAxis) / (SemiMinorAxis * SemiMinorAxis)
+			A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+			B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+			deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+				B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+			return SemiMinorAxis * A * (sigma - deltaSigma), nil
+		}
+	}
+	return 0, ErrNoConvergence
+}
+
+// Distance returns the ellipsoidal distance, falling back to the haversine
+// distance where Vincenty's formula does not converge
+func Distance(a, b Point) float64 {
+	if d, err := VincentyDistance(a, b); err == nil {
+		return d
+	}
+	return HaversineDistance(a, b)
+}
+
+// InitialBearing returns the great-circle bearing from a to b in degrees
+// clockwise from north
+func InitialBearing(a, b Point) float64 {
+	lat1, lat2 := toRadians(a.Latitude), toRadians(b.Latitude)
+	dLon := toRadians(b.Longitude - a.Longitude)
+
+	y := math.Sin(dLon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLon)
+	return normalizeBearing(toDegrees(math.Atan2(y, x)))
+}
+
+// Destination returns the point reached from start after travelling distance
+// metres along a great circle with the given initial bearing
+func Destination(start Point, bearing, distance float64) Point {
+	lat1, lon1 := toRadians(start.Latitude), toRadians(start.Longitude)
+	theta := toRadians(bearing)
+	delta := distance / MeanRadius
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(delta) + math.Cos(lat1)*math.Sin(delta)*math.Cos(theta))
+	lon2 := lon1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(lat1), math.Cos(delta)-math.Sin(lat1)*math.Sin(lat2))
+	return Point{Latitude: toDegrees(lat2), Longitude: normalizeLongitude(toDegrees(lon2))}
+}
+
+// CrossTrackDistance returns the distance of p from the great-circle segment
+// between start and end. Points beyond either end are measured to that end.
+func CrossTrackDistance(p, start, end Point) float64 {
+	if start == end {
+		return HaversineDistance(p, start)
+	}
+
+	delta13 := centralAngle(start, p)
+	theta13 := toRadians(InitialBearing(start, p))
+	theta12 := toRadians(InitialBearing(start, end))
+
+	// Beyond the start of the segment
+	if math.Cos(theta13-theta12) < 0 {
+		return HaversineDistance(p, start)
+	}
+
+	crossTrack := math.Asin(math.Sin(delta13) * math.Sin(theta13-theta12))
+	alongTrack := math.Acos(math.Max(-1, math.Min(1, math.Cos(delta13)/math.Cos(crossTrack))))
+
+	// Beyond the end of the segment
+	if alongTrack > centralAngle(start, end) {
+		return HaversineDistance(p, end)
+	}
+	return math.Abs(crossTrack) * MeanRadius
+}
@@ -0,0 +1,112 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+var (
+	flindersPeak = Point{Latitude: -37.95103341667, Longitude: 144.42486788889}
+	buninyong    = Point{Latitude: -37.65282113889, Longitude: 143.92649552778}
+)
+
+func TestVincentyDistance(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      Point
+		want      float64
+		tolerance float64
+		wantErr   error
+	}{
+		{"flinders peak to buninyong", flindersPeak, buninyong, 54972.271, 0.001, nil},
+		{"coincident points", flindersPeak, flindersPeak, 0, 0, nil},
+		{"one degree of equator", Point{0, 0}, Point{0, 1}, 111319.491, 0.001, nil},
+		{"one degree of meridian", Point{0, 0}, Point{1, 0}, 110574.389, 0.001, nil},
+		{"nearly antipodal", Point{0, 0}, Point{0.5, 179.7}, 0, 0, ErrNoConvergence},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VincentyDistance(tt.a, tt.b)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if math.Abs(got-tt.want) > tt.tolerance {
+				t.Errorf("distance = %.4f, want %.4f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistanceFallsBackToHaversine(t *testing.T) {
+	a, b := Point{0, 0}, Point{0.5, 179.7}
+	if got, want := Distance(a, b), HaversineDistance(a, b); got != want {
+		t.Errorf("Distance = %v, want haversine %v", got, want)
+	}
+	if got, _ := VincentyDistance(flindersPeak, buninyong); Distance(flindersPeak, buninyong) != got {
+		t.Errorf("Distance did not use Vincenty for converging points")
+	}
+}
+
+func TestHaversineDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Point
+		want float64
+	}{
+		{"coincident", Point{10, 20}, Point{10, 20}, 0},
+		{"one degree of equator", Point{0, 0}, Point{0, 1}, MeanRadius * math.Pi / 180},
+		{"equator to pole", Point{0, 0}, Point{90, 0}, MeanRadius * math.Pi / 2},
+		{"antipodes", Point{0, 0}, Point{0, 180}, MeanRadius * math.Pi},
+		{"across the antimeridian", Point{0, 179.5}, Point{0, -179.5}, MeanRadius * math.Pi / 180},
+	}
+	for _, tt := range tests {
+		if got := HaversineDistance(tt.a, tt.b); math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("%s: distance = %.6f, want %.6f", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInitialBearing(t *testing.T) {
+	tests := []struct {
+		name string
+		to   Point
+		want float64
+	}{
+		{"north", Point{1, 0}, 0},
+		{"east", Point{0, 1}, 90},
+		{"south", Point{-1, 0}, 180},
+		{"west", Point{0, -1}, 270},
+		{"north east", Point{1, 1}, 44.9956},
+	}
+	for _, tt := range tests {
+		if got := InitialBearing(Point{0, 0}, tt.to); math.Abs(got-tt.want) > 1e-4 {
+			t.Errorf("%s: bearing = %.4f, want %.4f", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDestinationRoundTrip(t *testing.T) {
+	tests := []struct {
+		start    Point
+		bearing  float64
+		distance float64
+	}{
+		{flindersPeak, 306.8682, 54972},
+		{Point{51.5, -0.12}, 45, 1000},
+		{Point{0, 179.9}, 90, 50000},
+		{Point{-60, 10}, 200, 2000000},
+	}
+	for _, tt := range tests {
+		end := Destination(tt.start, tt.bearing, tt.distance)
+		if err := end.Validate(); err != nil {
+			t.Fatalf("destination from %v: %v", tt.start, err)
+		}
+		if got := HaversineDistance(tt.start, end); math.Abs(got-tt.distance) > 1e-6*tt.distance {
+			t.Errorf("from %v: distance back = %.3f, want %.3f", tt.start, got, tt.distance)
+		}
+		if got := InitialBearing(tt.start, end); math.Abs(got-tt.bearing) > 1e-6 {
+			t.Errorf("from %v: bearing back = %.6f, want %.6f", tt.start, got, tt.bearing)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Package geo provides geodesic calculations on WGS84 coordinates. Distances
+// are in metres and angles in degrees.
+package geo
+
+import (
+	"fmt"
+	"math"
+)
+
+// WGS84 ellipsoid parameters
+const (
+	SemiMajorAxis = 6378137.0
+	Flattening    = 1 / 298.257223563
+	SemiMinorAxis = SemiMajorAxis * (1 - Flattening)
+
+	// MeanRadius is the IUGG mean earth radius used by spherical formulas
+	MeanRadius = 6371008.8
+	// AuthalicRadius is the radius of the sphere with the ellipsoid's surface area
+	AuthalicRadius = 6371007.2
+)
+
+// Point is a WGS84 coordinate
+type Point struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Validate checks that the coordinate is within range
+func (p Point) Validate() error {
+	if math.IsNaN(p.Latitude) || p.Latitude < -90 || p.Latitude > 90 {
+		return fmt.Errorf("latitude %v out of range", p.Latitude)
+	}
+	if math.IsNaN(p.Longitude) || p.Longitude < -180 || p.Longitude > 180 {
+		return fmt.Errorf("longitude %v out of range", p.Longitude)
+	}
+	return nil
+}
+
+// FromLonLat creates a point from a GeoJSON style [longitude, latitude] position
+func FromLonLat(position [2]float64) Point {
+	return Point{Latitude: position[1], Longitude: position[0]}
+}
+
+// LonLat returns the point as a GeoJSON style [longitude, latitude] position
+func (p Point) LonLat() [2]float64 {
+	return [2]float64{p.Longitude, p.Latitude}
+}
+
+// BoundingBox is an axis-aligned box of coordinates
+type BoundingBox struct {
+	MinLatitude  float64 `json:"min_latitude"`
+	MinLongitude float64 `json:"min_longitude"`
+	MaxLatitude  float64 `json:"max_latitude"`
+	MaxLongitude float64 `json:"max_longitude"`
+}
+
+// Contains reports whether the point lies inside the box
+func (b BoundingBox) Contains(p Point) bool {
+	return p.Latitude >= b.MinLatitude && p.Latitude <= b.MaxLatitude &&
+		p.Longitude >= b.MinLongitude && p.Longitude <= b.MaxLongitude
+}
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func toDegrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
+
+// normalizeLongitude wraps a longitude into [-180, 180)
+func normalizeLongitude(longitude float64) float64 {
+	return math.Mod(math.Mod(longitude+180, 360)+360, 360) - 180
+}
+
+// normalizeBearing wraps a bearing into [0, 360)
+func normalizeBearing(bearing float64) float64 {
+	return math.Mod(math.Mod(bearing, 360)+360, 360)
+}
@@ -0,0 +1,176 @@
+package geo
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrTooFewPoints is returned for polygons with fewer than three vertices
+var ErrTooFewPoints = errors.New("a polygon needs at least 3 points")
+
+// openRing drops the closing vertex of a closed ring
+func openRing(ring []Point) []Point {
+	if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+		return ring[:len(ring)-1]
+	}
+	return ring
+}
+
+// Area returns the area of a polygon ring in square metres. It uses the
+// spherical excess on a sphere of AuthalicRadius, which stays within about
+// 0.5% of the ellipsoidal area. The ring may be open or closed.
+func Area(ring []Point) (float64, error) {
+	ring = openRing(ring)
+	if len(ring) < 3 {
+		return 0, ErrTooFewPoints
+	}
+
+	total := 0.0
+	for i := range ring {
+		p1 := ring[i]
+		p2 := ring[(i+1)%len(ring)]
+		dLon := toRadians(normalizeLongitude(p2.Longitude - p1.Longitude))
+		total += dLon * (2 + math.Sin(toRadians(p1.Latitude)) + math.Sin(toRadians(p2.Latitude)))
+	}
+	return math.Abs(total * AuthalicRadius * AuthalicRadius / 2), nil
+}
+
+// Perimeter returns the ellipsoidal length of a polygon ring in metres,
+// including the closing edge
+func Perimeter(ring []Point) (float64, error) {
+	ring = openRing(ring)
+	if len(ring) < 3 {
+		return 0, ErrTooFewPoints
+	}
+	return Length(append(ring[:len(ring):len(ring)], ring[0])), nil
+}
+
+// Length returns the ellipsoidal length of a line in metres
+func Length(line []Point) float64 {
+	total := 0.0
+	for i := 1; i < len(line); i++ {
+		total += Distance(line[i-1], line[i])
+	}
+	return total
+}
+
+// Centroid returns the area-weighted centroid of a polygon ring, computed in
+// longitude/latitude space. Degenerate rings fall back to the vertex mean.
+func Centroid(ring []Point) (Point, error) {
+	ring = openRing(ring)
+	if len(ring) == 0 {
+		return Point{}, ErrTooFewPoints
+	}
+
+	var area, cx, cy float64
+	for i := range ring {
+		p1 := ring[i]
+		p2 := ring[(i+1)%len(ring)]
+		cross := p1.Longitude*p2.Latitude - p2.Longitude*p1.Latitude
+		area += cross
+		cx += (p1.Longitude + p2.Longitude) * cross
+		cy += (p1.Latitude + p2.Latitude) * cross
+	}
+
+	if math.Abs(area) < 1e-12 {
+		var sumLat, sumLon float64
+		for _, p := range ring {
+			sumLat += p.Latitude
+			sumLon += p.Longitude
+		}
+		n := float64(len(ring))
+		return Point{Latitude: sumLat / n, Longitude: sumLon / n}, nil
+	}
+
+	area /= 2
+	return Point{Latitude: cy / (6 * area), Longitude: cx / (6 * area)}, nil
+}
+
+// Bounds returns the bounding box of a set of points
+func Bounds(points []Point) (BoundingBox, error) {
+	if len(points) == 0 {
+		return BoundingBox{}, errors.New("no points")
+	}
+
+	box := BoundingBox{
+		MinLatitude: points[0].Latitude, MaxLatitude: points[0].Latitude,
+		MinLongitude: points[0].Longitude, MaxLongitude: points[0].Longitude,
+	}
+	for _, p := range points[1:] {
+		box.MinLatitude = math.Min(box.MinLatitude, p.Latitude)
+		box.MaxLatitude = math.Max(box.MaxLatitude, p.Latitude)
+		box.MinLongitude = math.Min(box.MinLongitude, p.Longitude)
+		box.MaxLongitude = math.Max(box.MaxLongitude, p.Longitude)
+	}
+	return box, nil
+}
+
+// PointInPolygon reports whether p lies inside a polygon ring using ray
+// casting in longitude/latitude space. Points on an edge or vertex count as
+// inside.
+func PointInPolygon(p Point, ring []Point) bool {
+	ring = openRing(ring)
+	if onBoundary(p, ring) {
+		return true
+	}
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i].Longitude, ring[i].Latitude
+		xj, yj := ring[j].Longitude, ring[j].Latitude
+		if (yi > p.Latitude) != (yj > p.Latitude) &&
+			p.Longitude < (xj-xi)*(p.Latitude-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// PointInPolygonWithHoles reports whether p lies inside the outer ring and
+// outside every hole. The boundaries of holes belong to the polygon.
+func PointInPolygonWithHoles(p Point, outer []Point, holes ...[]Point) bool {
+	if !PointInPolygon(p, outer) {
+		return false
+	}
+	for _, hole := range holes {
+		hole = openRing(hole)
+		if PointInPolygon(p, hole) && !onBoundary(p, hole) {
+			return false
+		}
+	}
+	return true
+}
+
+// onBoundary reports whether p lies on an edge of an open ring, within a
+// tolerance of about a millimetre
+func onBoundary(p Point, ring []Point) bool {
+	const epsilon = 1e-8
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[j], ring[i]
+		if p.Longitude < math.Min(a.Longitude, b.Longitude)-epsilon || p.Longitude > math.Max(a.Longitude, b.Longitude)+epsilon ||
+			p.Latitude < math.Min(a.Latitude, b.Latitude)-epsilon || p.Latitude > math.Max(a.Latitude, b.Latitude)+epsilon {
+			continue
+		}
+		cross := (b.Longitude-a.Longitude)*(p.Latitude-a.Latitude) - (b.Latitude-a.Latitude)*(p.Longitude-a.Longitude)
+		if math.Abs(cross) <= epsilon*math.Max(1, math.Hypot(b.Longitude-a.Longitude, b.Latitude-a.Latitude)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Buffer returns a closed ring approximating a circle of radius metres
+// around center with the given number of vertices
+func Buffer(center Point, radius float64, segments int) ([]Point, error) {
+	if radius <= 0 {
+		return nil, errors.New("radius must be positive")
+	}
+	if segments < 3 {
+		return nil, ErrTooFewPoints
+	}
+
+	ring := make([]Point, 0, segments+1)
+	for i := 0; i < segments; i++ {
+		ring = append(ring, Destination(center, float64(i)*360/float64(segments), radius))
+	}
+	return append(ring, ring[0]), nil
+}
@@ -0,0 +1,175 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// unitSquare is a closed one degree square on the equator
+var unitSquare = []Point{{0, 0}, {0, 1}, {1, 1}, {1, 0}, {0, 0}}
+
+func TestArea(t *testing.T) {
+	// Between meridians and parallels the spherical area is exact
+	cell := AuthalicRadius * AuthalicRadius * toRadians(1) * math.Sin(toRadians(1))
+	tests := []struct {
+		name    string
+		ring    []Point
+		want    float64
+		wantErr error
+	}{
+		{"closed square", unitSquare, cell, nil},
+		{"open square", unitSquare[:4], cell, nil},
+		{"clockwise square", []Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}}, cell, nil},
+		{"across the antimeridian", []Point{{0, 179.5}, {0, -179.5}, {1, -179.5}, {1, 179.5}}, cell, nil},
+		{"ten degree cell", []Point{{10, 0}, {10, 10}, {20, 10}, {20, 0}}, AuthalicRadius * AuthalicRadius * toRadians(10) * (math.Sin(toRadians(20)) - math.Sin(toRadians(10))), nil},
+		{"two points", []Point{{0, 0}, {1, 1}}, 0, ErrTooFewPoints},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Area(tt.ring)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if math.Abs(got-tt.want) > 1e-6*tt.want {
+				t.Errorf("area = %.1f, want %.1f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPerimeter(t *testing.T) {
+	tests := []struct {
+		name    string
+		ring    []Point
+		want    float64
+		wantErr error
+	}{
+		// Two meridian degrees, the equator degree and the geodesic at 1°N
+		{"closed square", unitSquare, 2*110574.389 + 111319.491 + 111302.649, nil},
+		{"open square", unitSquare[:4], 2*110574.389 + 111319.491 + 111302.649, nil},
+		{"line", []Point{{0, 0}, {0, 1}}, 0, ErrTooFewPoints},
+	}
+	for _, tt := range tests {
+		got, err := Perimeter(tt.ring)
+		if !errors.Is(err, tt.wantErr) {
+			t.Fatalf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if math.Abs(got-tt.want) > 1 {
+			t.Errorf("%s: perimeter = %.3f, want %.3f", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCentroid(t *testing.T) {
+	tests := []struct {
+		name string
+		ring []Point
+		want Point
+	}{
+		{"square", []Point{{0, 0}, {0, 2}, {2, 2}, {2, 0}, {0, 0}}, Point{1, 1}},
+		{"triangle", []Point{{0, 0}, {0, 3}, {3, 0}}, Point{1, 1}},
+		{"l shape", []Point{{0, 0}, {0, 2}, {1, 2}, {1, 1}, {2, 1}, {2, 0}}, Point{5.0 / 6, 5.0 / 6}},
+		{"collinear falls back to the mean", []Point{{0, 0}, {1, 1}, {2, 2}}, Point{1, 1}},
+	}
+	for _, tt := range tests {
+		got, err := Centroid(tt.ring)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if math.Abs(got.Latitude-tt.want.Latitude) > 1e-9 || math.Abs(got.Longitude-tt.want.Longitude) > 1e-9 {
+			t.Errorf("%s: centroid = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := Centroid(nil); !errors.Is(err, ErrTooFewPoints) {
+		t.Errorf("empty ring: err = %v, want ErrTooFewPoints", err)
+	}
+}
+
+func TestBounds(t *testing.T) {
+	box, err := Bounds([]Point{{10, -5}, {-3, 20}, {4, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := BoundingBox{MinLatitude: -3, MinLongitude: -5, MaxLatitude: 10, MaxLongitude: 20}
+	if box != want {
+		t.Errorf("bounds = %+v, want %+v", box, want)
+	}
+	if !box.Contains(Point{0, 0}) || box.Contains(Point{11, 0}) {
+		t.Errorf("Contains disagrees with the box %+v", box)
+	}
+
+	if _, err := Bounds(nil); err == nil {
+		t.Error("no error for an empty set of points")
+	}
+}
+
+func TestPointInPolygon(t *testing.T) {
+	outer := []Point{{0, 0}, {0, 10}, {10, 10}, {10, 0}, {0, 0}}
+	hole := []Point{{4, 4}, {4, 6}, {6, 6}, {6, 4}}
+	tests := []struct {
+		name  string
+		p     Point
+		holes [][]Point
+		want  bool
+	}{
+		{"inside", Point{2, 2}, nil, true},
+		{"outside", Point{11, 5}, nil, false},
+		{"on a vertex", Point{0, 0}, nil, true},
+		{"on the far vertex", Point{10, 10}, nil, true},
+		{"on a bottom edge", Point{0, 5}, nil, true},
+		{"on a top edge", Point{10, 5}, nil, true},
+		{"on a side edge", Point{5, 10}, nil, true},
+		{"beyond an edge", Point{5, 10.000001}, nil, false},
+		{"in the hole", Point{5, 5}, [][]Point{hole}, false},
+		{"on the hole edge", Point{4, 5}, [][]Point{hole}, true},
+		{"on a hole vertex", Point{6, 6}, [][]Point{hole}, true},
+		{"beside the hole", Point{2, 5}, [][]Point{hole}, true},
+		{"outside with a hole", Point{-1, 5}, [][]Point{hole}, false},
+	}
+	for _, tt := range tests {
+		if got := PointInPolygonWithHoles(tt.p, outer, tt.holes...); got != tt.want {
+			t.Errorf("%s: inside = %v, want %v", tt.name, got, tt.want)
+		}
+		if len(tt.holes) == 0 && PointInPolygon(tt.p, outer) != tt.want {
+			t.Errorf("%s: PointInPolygon disagrees", tt.name)
+		}
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	center := Point{Latitude: 48.8584, Longitude: 2.2945}
+	tests := []struct {
+		name     string
+		radius   float64
+		segments int
+		wantErr  bool
+	}{
+		{"square", 500, 4, false},
+		{"circle", 1000, 32, false},
+		{"wide", 250000, 64, false},
+		{"zero radius", 0, 32, true},
+		{"too few segments", 1000, 2, true},
+	}
+	for _, tt := range tests {
+		ring, err := Buffer(center, tt.radius, tt.segments)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: err = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if tt.wantErr {
+			continue
+		}
+		if len(ring) != tt.segments+1 || ring[0] != ring[len(ring)-1] {
+			t.Fatalf("%s: got %d vertices, want a closed ring of %d", tt.name, len(ring), tt.segments+1)
+		}
+		for _, p := range ring {
+			if d := HaversineDistance(center, p); math.Abs(d-tt.radius) > 1e-6*tt.radius {
+				t.Errorf("%s: vertex %v is %.3f m from the center, want %.3f", tt.name, p, d, tt.radius)
+			}
+		}
+		if !PointInPolygon(center, ring) {
+			t.Errorf("%s: center outside its buffer", tt.name)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package geo
+
+// Simplify reduces the vertices of a line with the Douglas-Peucker algorithm.
+// Vertices closer than tolerance metres to the simplified line are dropped.
+func Simplify(line []Point, tolerance float64) []Point {
+	if len(line) < 3 || tolerance <= 0 {
+		return append([]Point(nil), line...)
+	}
+
+	keep := make([]bool, len(line))
+	keep[0], keep[len(line)-1] = true, true
+
+	// Iterative to avoid deep recursion on long lines
+	type span struct{ first, last int }
+	stack := []span{{0, len(line) - 1}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		maxDistance, index := 0.0, -1
+		for i := s.first + 1; i < s.last; i++ {
+			if d := CrossTrackDistance(line[i], line[s.first], line[s.last]); d > maxDistance {
+				maxDistance, index = d, i
+			}
+		}
+		if index >= 0 && maxDistance > tolerance {
+			keep[index] = true
+			stack = append(stack, span{s.first, index}, span{index, s.last})
+		}
+	}
+
+	simplified := make([]Point, 0, len(line))
+	for i, p := range line {
+		if keep[i] {
+			simplified = append(simplified, p)
+		}
+	}
+	return simplified
+}
@@ -0,0 +1,42 @@
+package geo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSimplify(t *testing.T) {
+	// About 1.1 km steps along the equator with a 55 m detour in the middle
+	line := []Point{{0, 0}, {0, 0.01}, {0.0005, 0.02}, {0, 0.03}, {0, 0.04}}
+	tests := []struct {
+		name      string
+		line      []Point
+		tolerance float64
+		want      []Point
+	}{
+		{"keeps vertices 28 m off the detour", line, 10, line},
+		{"keeps the detour above the tolerance", line, 40, []Point{{0, 0}, {0.0005, 0.02}, {0, 0.04}}},
+		{"drops the detour below the tolerance", line, 100, []Point{{0, 0}, {0, 0.04}}},
+		{"zero tolerance keeps everything", line, 0, line},
+		{"two points", line[:2], 100, line[:2]},
+		{"empty", nil, 10, nil},
+	}
+	for _, tt := range tests {
+		got := Simplify(tt.line, tt.tolerance)
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: simplified = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSimplifyCopiesInput(t *testing.T) {
+	line := []Point{{0, 0}, {0, 1}}
+	simplified := Simplify(line, 10)
+	simplified[0].Latitude = 5
+	if line[0].Latitude != 0 {
+		t.Error("Simplify shares its result with the input")
+	}
+}
@@ -0,0 +1,185 @@
+package geoanswer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"geoai-app/model"
+)
+
+// ErrNoJSON is returned when a reply contains no JSON object
+var ErrNoJSON = errors.New("reply does not contain a JSON object")
+
+// ValidationError describes why a reply does not match the expected format
+type ValidationError struct {
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid geo answer: " + e.Reason
+}
+
+var (
+	fencePattern         = regexp.MustCompile("(?s)```(?:json|JSON)?\\s*(.*?)```")
+	trailingCommaPattern = regexp.MustCompile(`,\s*([}\]])`)
+)
+
+// rawAnswer accepts the shapes models commonly produce
+type rawAnswer struct {
+	Locations json.RawMessage `json:"locations"`
+	Messages  *string         `json:"messages"`
+	Message   *string         `json:"message"`
+}
+
+// Parse extracts, repairs and validates the JSON answer in a model reply
+func Parse(reply string) (*model.GeoAnswer, error) {
+	object := extractObject(stripFences(reply))
+	if object == "" {
+		return nil, ErrNoJSON
+	}
+
+	var raw rawAnswer
+	if err := json.Unmarshal([]byte(object), &raw); err != nil {
+		// Retry once with common model mistakes repaired
+		if err := json.Unmarshal([]byte(repair(object)), &raw); err != nil {
+			return nil, &ValidationError{Reason: fmt.Sprintf("malformed JSON: %v", err)}
+		}
+	}
+
+	answer := &model.GeoAnswer{}
+	switch {
+	case raw.Messages != nil:
+		answer.Message = strings.TrimSpace(*raw.Messages)
+	case raw.Message != nil:
+		answer.Message = strings.TrimSpace(*raw.Message)
+	}
+	if answer.Message == "" {
+		return nil, &ValidationError{Reason: `"messages" is missing or empty`}
+	}
+
+	locations, err := parseLocations(raw.Locations)
+	if err != nil {
+		return nil, err
+	}
+	answer.Locations = locations
+
+	return answer, nil
+}
+
+// parseLocations accepts either a comma-separated string or a list of names
+func parseLocations(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return []string{}, nil
+	}
+
+	var names []string
+	var joined string
+	if err := json.Unmarshal(raw, &joined); err == nil {
+		names = strings.Split(joined, ",")
+	} else if err := json.Unmarshal(raw, &names); err != nil {
+		return nil, &ValidationError{Reason: `"locations" must be a string or a list of strings`}
+	}
+
+	locations := []string{}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		locations = append(locations, name)
+	}
+	return locations, nil
+}
+
+// stripFences returns the content of the first markdown code fence, if any
+func stripFences(reply string) string {
+	if match := fencePattern.FindStringSubmatch(reply); match != nil {
+		return match[1]
+	}
+	return reply
+}
+
+// extractObject returns the first balanced JSON object in the text
+func extractObject(text string) string {
+	start := strings.Index(text, "{")
+	if start < 0 {
+		return ""
+	}
+
+	depth := 0
+	inString := false
+	escaped := false
+	for i := start; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case escaped:
+			escaped = false
+		case ch == '\\' && inString:
+			escaped = true
+		case ch == '"':
+			inString = !inString
+		case ch == '{' && !inString:
+			depth++
+		case ch == '}' && !inString:
+			depth--
+			if depth == 0 {
+				return text[start : i+1]
+			}
+		}
+	}
+
+	// Unterminated object, let repair close it
+	return text[start:]
+}
+
+// repair fixes raw newlines inside strings, trailing commas and missing
+// closing braces
+func repair(object string) string {
+	var b strings.Builder
+	inString := false
+	escaped := false
+	depth := 0
+	for i := 0; i < len(object); i++ {
+		ch := object[i]
+		switch {
+		case escaped:
+			escaped = false
+		case ch == '\\' && inString:
+			escaped = true
+		case ch == '"':
+			inString = !inString
+		case inString && ch == '\n':
+			b.WriteString(`\n`)
+			continue
+		case inString && (ch == '\r' || ch == '\t'):
+			b.WriteByte(' ')
+			continue
+		case ch == '{' && !inString:
+			depth++
+		case ch == '}' && !inString:
+			depth--
+		}
+		b.WriteByte(ch)
+	}
+
+	if inString {
+		b.WriteByte('"')
+	}
+	for ; depth > 0; depth-- {
+		b.WriteByte('}')
+	}
+
+	return trailingCommaPattern.ReplaceAllString(b.String(), "$1")
+}
+
+// CorrectionPrompt asks the model to resend a reply that failed validation
+func CorrectionPrompt(err error) string {
+	return fmt.Sprintf(`Your previous reply could not be used (%v). Reply again with only a JSON object in this format and nothing else:
+{"locations": "comma-separated list of key locations", "messages": "detailed response to the user's query"}`, err)
+}
@@ -0,0 +1,91 @@
+package geoanswer
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		reply     string
+		message   string
+		locations []string
+	}{
+		{"plain", `{"locations": "Paris, Lyon", "messages": "Both are in France."}`, "Both are in France.", []string{"Paris", "Lyon"}},
+		{"fenced", "```json\n{\"locations\": \"Paris\", \"messages\": \"The capital.\"}\n```", "The capital.", []string{"Paris"}},
+		{"fenced without language", "```\n{\"locations\": \"Paris\", \"messages\": \"The capital.\"}\n```", "The capital.", []string{"Paris"}},
+		{"leading and trailing prose", "Here you go:\n{\"locations\": \"Oslo\", \"messages\": \"Norway's capital.\"}\nLet me know if you need more.", "Norway's capital.", []string{"Oslo"}},
+		{"braces in strings", `{"messages": "Use {curly} quotes \"like this\"", "locations": "Rome"} and {more}`, `Use {curly} quotes "like this"`, []string{"Rome"}},
+		{"location list", `{"locations": ["Paris", " Lyon "], "messages": "Two cities."}`, "Two cities.", []string{"Paris", "Lyon"}},
+		{"duplicate locations", `{"locations": "Paris, paris, , Lyon", "messages": "Two cities."}`, "Two cities.", []string{"Paris", "Lyon"}},
+		{"empty locations", `{"locations": "", "messages": "No places here."}`, "No places here.", []string{}},
+		{"empty location list", `{"locations": [], "messages": "No places here."}`, "No places here.", []string{}},
+		{"null locations", `{"locations": null, "messages": "No places here."}`, "No places here.", []string{}},
+		{"missing locations", `{"messages": "No places here."}`, "No places here.", []string{}},
+		{"singular message", `{"locations": "Bern", "message": "Swiss capital."}`, "Swiss capital.", []string{"Bern"}},
+		{"extra fields", `{"locations": "Bern", "messages": "Swiss capital.", "confidence": 0.9, "sources": ["wiki"]}`, "Swiss capital.", []string{"Bern"}},
+		{"trimmed message", `{"locations": "Bern", "messages": "  Swiss capital.\n"}`, "Swiss capital.", []string{"Bern"}},
+		{"trailing comma", `{"locations": "Bern", "messages": "Swiss capital.",}`, "Swiss capital.", []string{"Bern"}},
+		{"raw newline", "{\"locations\": \"Bern\", \"messages\": \"Swiss\ncapital.\"}", "Swiss\ncapital.", []string{"Bern"}},
+		{"unterminated", `{"locations": "Bern", "messages": "Swiss capital.`, "Swiss capital.", []string{"Bern"}},
+	}
+	for _, tt := range tests {
+		answer, err := Parse(tt.reply)
+		if err != nil {
+			t.Errorf("%s: Parse() failed: %v", tt.name, err)
+			continue
+		}
+		if answer.Message != tt.message {
+			t.Errorf("%s: message = %q, want %q", tt.name, answer.Message, tt.message)
+		}
+		if !reflect.DeepEqual(answer.Locations, tt.locations) {
+			t.Errorf("%s: locations = %q, want %q", tt.name, answer.Locations, tt.locations)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		reply  string
+		noJSON bool
+		reason string
+	}{
+		{"empty", "", true, ""},
+		{"prose", "Paris is the capital of France.", true, ""},
+		{"array", `["Paris"]`, true, ""},
+		{"missing message", `{"locations": "Paris"}`, false, `"messages" is missing or empty`},
+		{"empty message", `{"locations": "Paris", "messages": "  "}`, false, `"messages" is missing or empty`},
+		{"message not a string", `{"locations": "Paris", "messages": 42}`, false, "malformed JSON"},
+		{"locations not strings", `{"locations": [1, 2], "messages": "Numbers."}`, false, `"locations" must be a string or a list of strings`},
+		{"locations object", `{"locations": {"city": "Paris"}, "messages": "An object."}`, false, `"locations" must be a string or a list of strings`},
+		{"broken JSON", `{"locations": "Paris" "messages": "No comma."}`, false, "malformed JSON"},
+	}
+	for _, tt := range tests {
+		answer, err := Parse(tt.reply)
+		if err == nil {
+			t.Errorf("%s: Parse() = %+v, want an error", tt.name, answer)
+			continue
+		}
+		if tt.noJSON {
+			if !errors.Is(err, ErrNoJSON) {
+				t.Errorf("%s: error = %v, want ErrNoJSON", tt.name, err)
+			}
+			continue
+		}
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || !strings.HasPrefix(validationErr.Reason, tt.reason) {
+			t.Errorf("%s: error = %v, want a validation error starting with %q", tt.name, err, tt.reason)
+		}
+	}
+}
+
+func TestCorrectionPrompt(t *testing.T) {
+	prompt := CorrectionPrompt(&ValidationError{Reason: `"messages" is missing or empty`})
+	if !strings.Contains(prompt, `"messages" is missing or empty`) || !strings.Contains(prompt, `{"locations":`) {
+		t.Errorf("CorrectionPrompt() = %q, want the reason and the expected format", prompt)
+	}
+}
@@ -0,0 +1,80 @@
+package geocode
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"geoai-app/model"
+)
+
+// Geocoder resolves place names to gazetteer entries
+type Geocoder interface {
+	// Geocode returns the best match for a name, or nil when there is none
+	Geocode(ctx context.Context, name string) (*model.Place, error)
+}
+
+// ResolveAll geocodes every name. Names that fail to resolve are kept
+// without a place so the client still sees them.
+func ResolveAll(ctx context.Context, geocoder Geocoder, names []string) []model.Location {
+	locations := make([]model.Location, 0, len(names))
+	for _, name := range names {
+		location := model.Location{Name: name}
+		if geocoder != nil {
+			place, err := geocoder.Geocode(ctx, name)
+			if err != nil {
+				fmt.Printf("Error geocoding %q: %v\n", name, err)
+			}
+			location.Place = place
+			location.Confidence = confidence(name, place)
+		}
+		locations = append(locations, location)
+	}
+	return locations
+}
+
+// confidence scores how directly a name matched a place
+func confidence(name string, place *model.Place) float64 {
+	switch {
+	case place == nil:
+		return 0
+	case Normalize(name) == Normalize(place.Name):
+		return 1
+	default:
+		// Matched through the ASCII or an alternate name
+		return 0.8
+	}
+}
+
+// Normalize lowercases a name and collapses its whitespace for lookups
+func Normalize(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// lookupNames returns the normalized names a place can be found by
+func lookupNames(place model.Place, asciiName string, alternateNames []string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, name := range append([]string{place.Name, asciiName}, alternateNames...) {
+		name = Normalize(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// better reports whether a ranks above b as a match for the same name
+func better(a, b *model.Place) bool {
+	aPrimary := a.FeatureClass == "P" || a.FeatureClass == "A"
+	bPrimary := b.FeatureClass == "P" || b.FeatureClass == "A"
+	if aPrimary != bPrimary {
+		return aPrimary
+	}
+	if a.Population != b.Population {
+		return a.Population > b.Population
+	}
+	return a.GeonameID < b.GeonameID
+}
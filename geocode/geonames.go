@@ -0,0 +1,131 @@
+package geocode
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"geoai-app/model"
+)
+
+// GeoNames dump columns, see https://download.geonames.org/export/dump/readme.txt
+const (
+	colGeonameID      = 0
+	colName           = 1
+	colASCIIName      = 2
+	colAlternateNames = 3
+	colLatitude       = 4
+	colLongitude      = 5
+	colFeatureClass   = 6
+	colFeatureCode    = 7
+	colCountryCode    = 8
+	colAdmin1Code     = 10
+	colPopulation     = 14
+	geonamesColumns   = 19
+)
+
+// readGeoNames parses a GeoNames dump such as cities15000.txt or
+// allCountries.txt. Country and admin1 names are filled in from
+// countryInfo.txt and admin1CodesASCII.txt when they sit next to the dump.
+func readGeoNames(path string, fn func(place model.Place, names []string) error) error {
+	dir := filepath.Dir(path)
+	countries, err := readCodeNames(filepath.Join(dir, "countryInfo.txt"), 0, 4)
+	if err != nil {
+		return err
+	}
+	admin1, err := readCodeNames(filepath.Join(dir, "admin1CodesASCII.txt"), 0, 1)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < geonamesColumns {
+			return fmt.Errorf("%s:%d: expected %d columns, got %d", path, line, geonamesColumns, len(fields))
+		}
+
+		place, err := parsePlace(fields)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		place.Country = countries[place.CountryCode]
+		place.Admin1 = admin1[place.CountryCode+"."+place.Admin1Code]
+
+		var alternateNames []string
+		if fields[colAlternateNames] != "" {
+			alternateNames = strings.Split(fields[colAlternateNames], ",")
+		}
+		if err := fn(place, lookupNames(place, fields[colASCIIName], alternateNames)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func parsePlace(fields []string) (model.Place, error) {
+	var place model.Place
+	var err error
+
+	if place.GeonameID, err = strconv.ParseInt(fields[colGeonameID], 10, 64); err != nil {
+		return place, fmt.Errorf("invalid geonameid: %w", err)
+	}
+	if place.Latitude, err = strconv.ParseFloat(fields[colLatitude], 64); err != nil {
+		return place, fmt.Errorf("invalid latitude: %w", err)
+	}
+	if place.Longitude, err = strconv.ParseFloat(fields[colLongitude], 64); err != nil {
+		return place, fmt.Errorf("invalid longitude: %w", err)
+	}
+	if fields[colPopulation] != "" {
+		if place.Population, err = strconv.ParseInt(fields[colPopulation], 10, 64); err != nil {
+			return place, fmt.Errorf("invalid population: %w", err)
+		}
+	}
+
+	place.Name = fields[colName]
+	place.ASCIIName = fields[colASCIIName]
+	place.FeatureClass = fields[colFeatureClass]
+	place.FeatureCode = fields[colFeatureCode]
+	place.CountryCode = fields[colCountryCode]
+	place.Admin1Code = fields[colAdmin1Code]
+	return place, nil
+}
+
+// readCodeNames maps codes to names from an optional GeoNames lookup file
+func readCodeNames(path string, codeColumn, nameColumn int) (map[string]string, error) {
+	names := make(map[string]string)
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return names, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) > codeColumn && len(fields) > nameColumn {
+			names[fields[codeColumn]] = fields[nameColumn]
+		}
+	}
+	return names, scanner.Err()
+}
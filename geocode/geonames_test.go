@@ -0,0 +1,91 @@
+package geocode
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"geoai-app/model"
+)
+
+func TestReadGeoNames(t *testing.T) {
+	var places []model.Place
+	var names [][]string
+	err := readGeoNames("testdata/cities.txt", func(place model.Place, placeNames []string) error {
+		places = append(places, place)
+		names = append(names, placeNames)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(places) != 7 {
+		t.Fatalf("read %d places, want 7", len(places))
+	}
+
+	want := model.Place{
+		GeonameID:    2867714,
+		Name:         "München",
+		ASCIIName:    "Muenchen",
+		Latitude:     48.13743,
+		Longitude:    11.57549,
+		CountryCode:  "DE",
+		Country:      "Germany",
+		Admin1Code:   "02",
+		Admin1:       "Bavaria",
+		FeatureClass: "P",
+		FeatureCode:  "PPLA",
+		Population:   1260391,
+	}
+	if places[4] != want {
+		t.Errorf("place = %+v, want %+v", places[4], want)
+	}
+	if want := []string{"münchen", "muenchen", "munich", "monaco di baviera"}; !reflect.DeepEqual(names[4], want) {
+		t.Errorf("lookup names = %q, want %q", names[4], want)
+	}
+
+	// Duplicate names are dropped, a missing population is 0
+	if want := []string{"paris", "lutetia", "parigi", "parís"}; !reflect.DeepEqual(names[0], want) {
+		t.Errorf("lookup names = %q, want %q", names[0], want)
+	}
+	if names := names[1]; !reflect.DeepEqual(names, []string{"paris"}) {
+		t.Errorf("lookup names without alternates = %q, want [paris]", names)
+	}
+	if places[5].Population != 0 || places[5].Admin1 != "" {
+		t.Errorf("place = %+v, want no population and an unknown admin1", places[5])
+	}
+}
+
+func TestReadGeoNamesErrors(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"testdata/short.txt", "short.txt:1: expected 19 columns, got 3"},
+		{"testdata/badlat.txt", "badlat.txt:1: invalid latitude"},
+		{"testdata/missing.txt", "no such file"},
+	}
+	for _, tt := range tests {
+		err := readGeoNames(tt.path, func(model.Place, []string) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("readGeoNames(%s) = %v, want an error containing %q", tt.path, err, tt.want)
+		}
+	}
+}
+
+func TestReadCodeNames(t *testing.T) {
+	countries, err := readCodeNames("testdata/countryInfo.txt", 0, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"FR": "France", "DE": "Germany", "US": "United States"}
+	if !reflect.DeepEqual(countries, want) {
+		t.Errorf("readCodeNames() = %v, want %v", countries, want)
+	}
+
+	// Lookup files are optional
+	missing, err := readCodeNames("testdata/missing.txt", 0, 1)
+	if err != nil || len(missing) != 0 {
+		t.Errorf("readCodeNames() of a missing file = %v, %v, want an empty map", missing, err)
+	}
+}
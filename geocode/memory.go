@@ -0,0 +1,42 @@
+package geocode
+
+import (
+	"context"
+
+	"geoai-app/model"
+)
+
+// MemoryGeocoder keeps a GeoNames gazetteer in memory. It suits the smaller
+// dumps such as cities15000.txt.
+type MemoryGeocoder struct {
+	places map[string]*model.Place
+}
+
+// NewMemoryGeocoder loads the GeoNames dump at path into memory
+func NewMemoryGeocoder(path string) (*MemoryGeocoder, error) {
+	g := &MemoryGeocoder{places: make(map[string]*model.Place)}
+
+	err := readGeoNames(path, func(place model.Place, names []string) error {
+		entry := place
+		for _, name := range names {
+			if current, ok := g.places[name]; !ok || better(&entry, current) {
+				g.places[name] = &entry
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Geocode looks the name up in memory
+func (g *MemoryGeocoder) Geocode(_ context.Context, name string) (*model.Place, error) {
+	place, ok := g.places[Normalize(name)]
+	if !ok {
+		return nil, nil
+	}
+	result := *place
+	return &result, nil
+}
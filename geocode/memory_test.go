@@ -0,0 +1,89 @@
+package geocode
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryGeocoder(t *testing.T) {
+	g, err := NewMemoryGeocoder("testdata/cities.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want int64
+	}{
+		// The capital outranks the smaller Paris in Texas
+		{"Paris", 2988507},
+		{"  PARIS ", 2988507},
+		{"Parigi", 2988507},
+		// A populated place outranks a larger mountain of the same name
+		{"Lyon", 2996944},
+		{"Lugdunum", 2996944},
+		{"Munich", 2867714},
+		{"muenchen", 2867714},
+		{"Monaco  di Baviera", 2867714},
+		// Equal rank falls back to the lower GeoNames ID
+		{"Springfield", 4250542},
+		{"Atlantis", 0},
+	}
+	for _, tt := range tests {
+		place, err := g.Geocode(context.Background(), tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got int64
+		if place != nil {
+			got = place.GeonameID
+		}
+		if got != tt.want {
+			t.Errorf("Geocode(%q) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	// Callers get copies of the stored places
+	place, _ := g.Geocode(context.Background(), "Paris")
+	place.Name = "changed"
+	if again, _ := g.Geocode(context.Background(), "Paris"); again.Name != "Paris" {
+		t.Errorf("Geocode() returned the stored place, got %q", again.Name)
+	}
+}
+
+func TestNewMemoryGeocoderFails(t *testing.T) {
+	if _, err := NewMemoryGeocoder("testdata/short.txt"); err == nil {
+		t.Error("NewMemoryGeocoder loaded a malformed dump")
+	}
+}
+
+func TestResolveAll(t *testing.T) {
+	g, err := NewMemoryGeocoder("testdata/cities.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locations := ResolveAll(context.Background(), g, []string{"Paris", "Munich", "Atlantis"})
+	if len(locations) != 3 {
+		t.Fatalf("resolved %d locations, want 3", len(locations))
+	}
+	tests := []struct {
+		name       string
+		resolved   bool
+		confidence float64
+	}{
+		{"Paris", true, 1},
+		{"Munich", true, 0.8},
+		{"Atlantis", false, 0},
+	}
+	for i, tt := range tests {
+		location := locations[i]
+		if location.Name != tt.name || (location.Place != nil) != tt.resolved || location.Confidence != tt.confidence {
+			t.Errorf("location %d = %+v, want %s resolved %v with confidence %.1f", i, location, tt.name, tt.resolved, tt.confidence)
+		}
+	}
+
+	if unresolved := ResolveAll(context.Background(), nil, []string{"Paris"}); unresolved[0].Place != nil {
+		t.Errorf("resolved %+v without a geocoder", unresolved[0])
+	}
+}
@@ -0,0 +1,73 @@
+package geocode
+
+import (
+	"context"
+
+	"geoai-app/model"
+	"geoai-app/repository"
+)
+
+// importBatchSize bounds the number of places held in memory during import
+const importBatchSize = 10000
+
+// PostgresGeocoder looks names up in the gazetteer tables. It suits the
+// full allCountries.txt dump.
+type PostgresGeocoder struct {
+	Repo repository.GazetteerRepositoryInterface
+}
+
+// NewPostgresGeocoder creates a new instance of PostgresGeocoder
+func NewPostgresGeocoder(repo repository.GazetteerRepositoryInterface) *PostgresGeocoder {
+	return &PostgresGeocoder{Repo: repo}
+}
+
+// Geocode looks the name up in Postgres
+func (g *PostgresGeocoder) Geocode(_ context.Context, name string) (*model.Place, error) {
+	return g.Repo.FindPlaceByName(Normalize(name))
+}
+
+// ImportGeoNames replaces the gazetteer tables with a GeoNames dump. Places
+// are copied in batches within a single transaction, so a failed import
+// leaves no partial gazetteer behind and is tried again on the next start.
+func ImportGeoNames(repo repository.GazetteerRepositoryInterface, path string) (int, error) {
+	gazetteer, err := repo.BeginImport()
+	if err != nil {
+		return 0, err
+	}
+	defer gazetteer.Rollback()
+
+	var places []model.Place
+	var names [][]string
+	imported := 0
+
+	flush := func() error {
+		if len(places) == 0 {
+			return nil
+		}
+		if err := gazetteer.ImportPlaces(places, names); err != nil {
+			return err
+		}
+		imported += len(places)
+		places, names = places[:0], names[:0]
+		return nil
+	}
+
+	err = readGeoNames(path, func(place model.Place, placeNames []string) error {
+		places = append(places, place)
+		names = append(names, placeNames)
+		if len(places) >= importBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+	if err := gazetteer.Commit(); err != nil {
+		return 0, err
+	}
+	return imported, nil
+}
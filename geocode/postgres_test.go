@@ -0,0 +1,98 @@
+package geocode
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"geoai-app/model"
+	"geoai-app/repository"
+)
+
+// fakeGazetteer keeps imported places in memory, committed or not
+type fakeGazetteer struct {
+	repository.GazetteerRepositoryInterface
+
+	places     []model.Place
+	names      [][]string
+	failImport error
+	committed  bool
+	rolledBack bool
+}
+
+func (g *fakeGazetteer) BeginImport() (repository.GazetteerImport, error) {
+	return g, nil
+}
+
+func (g *fakeGazetteer) ImportPlaces(places []model.Place, names [][]string) error {
+	if g.failImport != nil {
+		return g.failImport
+	}
+	g.places = append(g.places, places...)
+	for _, placeNames := range names {
+		g.names = append(g.names, append([]string(nil), placeNames...))
+	}
+	return nil
+}
+
+func (g *fakeGazetteer) Commit() error {
+	g.committed = true
+	return nil
+}
+
+func (g *fakeGazetteer) Rollback() error {
+	if !g.committed {
+		g.rolledBack = true
+	}
+	return nil
+}
+
+func (g *fakeGazetteer) FindPlaceByName(name string) (*model.Place, error) {
+	for i, place := range g.places {
+		for _, placeName := range g.names[i] {
+			if placeName == name {
+				return &place, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func TestImportGeoNames(t *testing.T) {
+	gazetteer := &fakeGazetteer{}
+	imported, err := ImportGeoNames(gazetteer, "testdata/cities.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != 7 || len(gazetteer.places) != 7 || !gazetteer.committed {
+		t.Fatalf("imported %d places, stored %d, committed %v, want 7 committed", imported, len(gazetteer.places), gazetteer.committed)
+	}
+	if place := gazetteer.places[0]; place.ASCIIName != "Paris" || place.Country != "France" || place.Admin1 != "Île-de-France" {
+		t.Errorf("imported %+v, want the ASCII, country and admin1 names filled in", place)
+	}
+
+	// Lookups go through the normalized names
+	place, err := NewPostgresGeocoder(gazetteer).Geocode(context.Background(), " MUNICH")
+	if err != nil || place == nil || place.GeonameID != 2867714 {
+		t.Errorf("Geocode() = %+v, %v, want München", place, err)
+	}
+}
+
+func TestImportGeoNamesRollsBack(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		gazetteer *fakeGazetteer
+	}{
+		{"malformed dump", "testdata/badlat.txt", &fakeGazetteer{}},
+		{"failed copy", "testdata/cities.txt", &fakeGazetteer{failImport: errors.New("copy failed")}},
+	}
+	for _, tt := range tests {
+		if _, err := ImportGeoNames(tt.gazetteer, tt.path); err == nil {
+			t.Errorf("%s: import succeeded", tt.name)
+		}
+		if tt.gazetteer.committed || !tt.gazetteer.rolledBack {
+			t.Errorf("%s: committed %v, rolled back %v, want a rollback", tt.name, tt.gazetteer.committed, tt.gazetteer.rolledBack)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package geojson
+
+import (
+	"encoding/json"
+
+	"geoai-app/model"
+)
+
+// ContentType is the media type of GeoJSON documents
+const ContentType = "application/geo+json"
+
+// Geometry is a GeoJSON geometry object
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// NewPoint creates a Point geometry, GeoJSON orders coordinates as lon, lat
+func NewPoint(longitude, latitude float64) *Geometry {
+	return &Geometry{Type: "Point", Coordinates: []float64{longitude, latitude}}
+}
+
+// Feature is a GeoJSON feature
+type Feature struct {
+	Geometry   *Geometry              `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// MarshalJSON adds the GeoJSON type member
+func (f Feature) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type       string                 `json:"type"`
+		Geometry   *Geometry              `json:"geometry"`
+		Properties map[string]interface{} `json:"properties"`
+	}{"Feature", f.Geometry, f.Properties})
+}
+
+// FeatureCollection is a GeoJSON feature collection. ForeignMembers are
+// written as extra top-level members next to "type" and "features".
+type FeatureCollection struct {
+	Features       []Feature
+	ForeignMembers map[string]interface{}
+}
+
+// NewFeatureCollection creates an empty feature collection
+func NewFeatureCollection() *FeatureCollection {
+	return &FeatureCollection{
+		Features:       []Feature{},
+		ForeignMembers: map[string]interface{}{},
+	}
+}
+
+// MarshalJSON writes the collection with its foreign members
+func (fc FeatureCollection) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(fc.ForeignMembers)+2)
+	for key, value := range fc.ForeignMembers {
+		members[key] = value
+	}
+	features := fc.Features
+	if features == nil {
+		features = []Feature{}
+	}
+	members["type"] = "FeatureCollection"
+	members["features"] = features
+	return json.Marshal(members)
+}
+
+// AddMessage adds a point feature for every resolved location of a message
+func (fc *FeatureCollection) AddMessage(message model.Message) {
+	for _, location := range message.Locations {
+		if location.Place == nil {
+			continue
+		}
+		place := location.Place
+		fc.Features = append(fc.Features, Feature{
+			Geometry: NewPoint(place.Longitude, place.Latitude),
+			Properties: map[string]interface{}{
+				"name":          location.Name,
+				"matched_name":  place.Name,
+				"geoname_id":    place.GeonameID,
+				"country_code":  place.CountryCode,
+				"country":       place.Country,
+				"admin1":        place.Admin1,
+				"feature_class": place.FeatureClass,
+				"feature_code":  place.FeatureCode,
+				"confidence":    location.Confidence,
+				"message_id":    message.ID,
+			},
+		})
+	}
+}
+
+// FromMessages builds a collection of the resolved locations of messages
+func FromMessages(conversationID string, messages []model.Message) *FeatureCollection {
+	fc := NewFeatureCollection()
+	fc.ForeignMembers["conversation_id"] = conversationID
+	for _, message := range messages {
+		fc.AddMessage(message)
+	}
+	return fc
+}
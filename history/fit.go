@@ -0,0 +1,44 @@
+package history
+
+import (
+	"geoai-app/model"
+)
+
+// truncatedMarker ends tool results shortened by Fit
+const truncatedMarker = "\n[truncated]"
+
+// minToolResultChars is the part of a tool result Fit always keeps
+const minToolResultChars = 200
+
+// Fit keeps the prompt of a single provider call within budget tokens. A
+// policy trims the history once per turn, but tool results and correction
+// prompts are appended after it, so every call is fitted again. The oldest
+// turns are dropped first, then tool results are shortened, oldest first.
+// The system prompt and the latest user message are always kept whole. A
+// budget of 0 or less keeps everything.
+func Fit(messages []model.Message, budget int) []model.Message {
+	if budget <= 0 || EstimateAll(messages) <= budget {
+		return messages
+	}
+
+	system, turns := splitTurns(messages)
+	fitted := join(system, fitTurns(turns, budget-EstimateAll(system)))
+	excess := EstimateAll(fitted) - budget
+	for i := range fitted {
+		if excess <= 0 {
+			break
+		}
+		if fitted[i].Role != "tool" {
+			continue
+		}
+		content := []rune(fitted[i].Content)
+		keep := max(len(content)-excess*charsPerToken-len(truncatedMarker), minToolResultChars)
+		if keep >= len(content) {
+			continue
+		}
+		before := EstimateTokens(fitted[i])
+		fitted[i].Content = string(content[:keep]) + truncatedMarker
+		excess -= before - EstimateTokens(fitted[i])
+	}
+	return fitted
+}
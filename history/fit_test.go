@@ -0,0 +1,69 @@
+package history
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"geoai-app/model"
+)
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		name   string
+		budget int
+		want   []string
+	}{
+		{"no budget", 0, []string{"system: prompt", "user: a", "assistant: answer to a", "user: b", "assistant: answer to b"}},
+		{"fits", 1000, []string{"system: prompt", "user: a", "assistant: answer to a", "user: b", "assistant: answer to b"}},
+		{"drops oldest turns", 29 * 3, []string{"system: prompt", "user: b", "assistant: answer to b"}},
+		{"keeps system prompt and latest turn", 10, []string{"system: prompt", "user: b", "assistant: answer to b"}},
+	}
+	for _, tt := range tests {
+		got := Fit(conversationOf("a", "b"), tt.budget)
+		if !reflect.DeepEqual(contents(got), tt.want) {
+			t.Errorf("%s: Fit() = %v, want %v", tt.name, contents(got), tt.want)
+		}
+	}
+}
+
+func TestFitShortensToolResults(t *testing.T) {
+	messages := append(conversationOf("a"),
+		model.Message{Role: "user", Content: pad("b")},
+		model.Message{Role: "assistant", ToolCalls: []model.ToolCall{{ID: "1", Name: "geocode", Arguments: "{}"}}},
+		model.Message{Role: "tool", Content: strings.Repeat("x", 4000), ToolCallID: "1"},
+		model.Message{Role: "assistant", ToolCalls: []model.ToolCall{{ID: "2", Name: "geocode", Arguments: "{}"}}},
+		model.Message{Role: "tool", Content: strings.Repeat("y", 4000), ToolCallID: "2"},
+	)
+	original := messages[5].Content
+
+	got := Fit(messages, 600)
+	if tokens := EstimateAll(got); tokens > 600 {
+		t.Errorf("Fit() kept %d tokens, want at most 600", tokens)
+	}
+	if got[0].Role != "system" || got[1].Content != pad("b") {
+		t.Fatalf("Fit() = %v, want the system prompt and the latest turn", contents(got))
+	}
+	first, second := got[3].Content, got[5].Content
+	if first != strings.Repeat("x", minToolResultChars)+truncatedMarker {
+		t.Errorf("first tool result kept %d characters, want it shortened first", len(first))
+	}
+	if !strings.HasSuffix(second, truncatedMarker) || len(second) < 1500 {
+		t.Errorf("second tool result kept %d characters, want only the excess removed", len(second))
+	}
+	if messages[5].Content != original {
+		t.Error("Fit() modified the messages it was given")
+	}
+}
+
+func TestFitKeepsToolResultMinimum(t *testing.T) {
+	messages := []model.Message{
+		{Role: "system", Content: "prompt"},
+		{Role: "user", Content: "b"},
+		{Role: "tool", Content: strings.Repeat("x", 4000)},
+	}
+	got := Fit(messages, 10)
+	if len(got) != 3 || got[2].Content != strings.Repeat("x", minToolResultChars)+truncatedMarker {
+		t.Errorf("Fit() = %v, want the tool result cut to %d characters", contents(got), minToolResultChars)
+	}
+}
@@ -0,0 +1,148 @@
+package history
+
+import (
+	"context"
+	"fmt"
+
+	"geoai-app/llm"
+	"geoai-app/model"
+)
+
+// Policy selects the part of a conversation's history sent to the provider.
+// Policies never modify the stored history.
+type Policy interface {
+	Apply(ctx context.Context, conversation *model.Conversation, messages []model.Message) ([]model.Message, error)
+}
+
+// Config selects and configures a policy
+type Config struct {
+	// Policy is one of full, window, last_n or summary
+	Policy string
+	// MaxTokens is the prompt budget of the window and summary policies
+	MaxTokens int
+	// LastTurns is the number of user turns kept by the last_n policy
+	LastTurns int
+}
+
+// NewPolicy creates the policy named in the config. The summary policy needs
+// a provider to write summaries and a store to keep them.
+func NewPolicy(cfg Config, provider llm.Provider, store SummaryStore) (Policy, error) {
+	switch cfg.Policy {
+	case "full":
+		return FullPolicy{}, nil
+	case "", "window":
+		return WindowPolicy{MaxTokens: cfg.MaxTokens}, nil
+	case "last_n":
+		return LastTurnsPolicy{Turns: cfg.LastTurns}, nil
+	case "summary":
+		return &SummaryPolicy{MaxTokens: cfg.MaxTokens, Provider: provider, Store: store}, nil
+	default:
+		return nil, fmt.Errorf("unknown history policy %q", cfg.Policy)
+	}
+}
+
+// budgetKey carries the prompt budget of a request
+type budgetKey struct{}
+
+// WithBudget returns a context whose policies keep the prompt within tokens,
+// such as what the context window of the request's model leaves after the
+// reply. The smaller of it and a policy's own budget applies, 0 sets none.
+func WithBudget(ctx context.Context, tokens int) context.Context {
+	return context.WithValue(ctx, budgetKey{}, tokens)
+}
+
+// maxPromptTokens returns the smaller of maxTokens and the budget in ctx,
+// ignoring unset budgets
+func maxPromptTokens(ctx context.Context, maxTokens int) int {
+	tokens, _ := ctx.Value(budgetKey{}).(int)
+	switch {
+	case tokens <= 0:
+		return maxTokens
+	case maxTokens <= 0:
+		return tokens
+	default:
+		return min(tokens, maxTokens)
+	}
+}
+
+// FullPolicy sends the complete history
+type FullPolicy struct{}
+
+// Apply returns the messages unchanged
+func (FullPolicy) Apply(_ context.Context, _ *model.Conversation, messages []model.Message) ([]model.Message, error) {
+	return messages, nil
+}
+
+// WindowPolicy keeps the system prompt and the most recent turns that fit in
+// MaxTokens
+type WindowPolicy struct {
+	MaxTokens int
+}
+
+// Apply trims the oldest turns until the history fits
+func (p WindowPolicy) Apply(ctx context.Context, _ *model.Conversation, messages []model.Message) ([]model.Message, error) {
+	system, turns := splitTurns(messages)
+	return join(system, fitTurns(turns, maxPromptTokens(ctx, p.MaxTokens)-EstimateAll(system))), nil
+}
+
+// LastTurnsPolicy keeps the system prompt and the last Turns user turns
+type LastTurnsPolicy struct {
+	Turns int
+}
+
+// Apply drops all but the last turns
+func (p LastTurnsPolicy) Apply(_ context.Context, _ *model.Conversation, messages []model.Message) ([]model.Message, error) {
+	system, turns := splitTurns(messages)
+	if p.Turns > 0 && len(turns) > p.Turns {
+		turns = turns[len(turns)-p.Turns:]
+	}
+	return join(system, turns), nil
+}
+
+// splitTurns separates the leading system messages from the rest, which is
+// grouped into turns starting at each user message. Cutting only at turn
+// boundaries keeps tool calls next to their results.
+func splitTurns(messages []model.Message) ([]model.Message, [][]model.Message) {
+	i := 0
+	for i < len(messages) && messages[i].Role == "system" {
+		i++
+	}
+	system := messages[:i]
+
+	var turns [][]model.Message
+	for _, message := range messages[i:] {
+		if message.Role == "user" || len(turns) == 0 {
+			turns = append(turns, nil)
+		}
+		turns[len(turns)-1] = append(turns[len(turns)-1], message)
+	}
+	return system, turns
+}
+
+// fitTurns keeps the newest turns that fit in budget tokens. The last turn,
+// which holds the new user message, is always kept.
+func fitTurns(turns [][]model.Message, budget int) [][]model.Message {
+	if len(turns) == 0 {
+		return turns
+	}
+
+	used := 0
+	start := len(turns)
+	for start > 0 {
+		cost := EstimateAll(turns[start-1])
+		if start < len(turns) && used+cost > budget {
+			break
+		}
+		used += cost
+		start--
+	}
+	return turns[start:]
+}
+
+func join(system []model.Message, turns [][]model.Message) []model.Message {
+	messages := append([]model.Message(nil), system...)
+	for _, turn := range turns {
+		messages = append(messages, turn...)
+	}
+	return messages
+}
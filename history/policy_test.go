@@ -0,0 +1,160 @@
+package history
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"geoai-app/model"
+)
+
+// conversationOf builds a history of a system prompt and one turn per
+// question, each answered by the assistant. Every message costs 29 tokens.
+func conversationOf(questions ...string) []model.Message {
+	messages := []model.Message{{ID: 1, Role: "system", Content: strings.Repeat("s", 100)}}
+	for _, question := range questions {
+		id := uint(len(messages) + 1)
+		messages = append(messages,
+			model.Message{ID: id, Role: "user", Content: pad(question)},
+			model.Message{ID: id + 1, Role: "assistant", Content: pad("answer to " + question)},
+		)
+	}
+	return messages
+}
+
+func pad(content string) string {
+	return content + strings.Repeat(".", 100-len(content))
+}
+
+// contents lists the messages by role and the unpadded content
+func contents(messages []model.Message) []string {
+	var got []string
+	for _, message := range messages {
+		content := strings.TrimRight(message.Content, ".")
+		if message.Role == "system" && strings.Trim(content, "s") == "" {
+			content = "prompt"
+		}
+		got = append(got, message.Role+": "+content)
+	}
+	return got
+}
+
+func TestNewPolicy(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   Policy
+	}{
+		{"", WindowPolicy{MaxTokens: 100}},
+		{"window", WindowPolicy{MaxTokens: 100}},
+		{"full", FullPolicy{}},
+		{"last_n", LastTurnsPolicy{Turns: 3}},
+		{"summary", &SummaryPolicy{MaxTokens: 100}},
+	}
+	for _, tt := range tests {
+		got, err := NewPolicy(Config{Policy: tt.policy, MaxTokens: 100, LastTurns: 3}, nil, nil)
+		if err != nil {
+			t.Errorf("NewPolicy(%q) failed: %v", tt.policy, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NewPolicy(%q) = %#v, want %#v", tt.policy, got, tt.want)
+		}
+	}
+
+	if _, err := NewPolicy(Config{Policy: "newest"}, nil, nil); err == nil {
+		t.Error("NewPolicy accepted an unknown policy")
+	}
+}
+
+func TestFullPolicy(t *testing.T) {
+	messages := conversationOf("a", "b", "c")
+	got, err := FullPolicy{}.Apply(context.Background(), &model.Conversation{}, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, messages) {
+		t.Errorf("Apply() = %v, want the full history", contents(got))
+	}
+}
+
+func TestWindowPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxTokens int
+		budget    int
+		want      []string
+	}{
+		{"fits", 1000, 0, []string{"system: prompt", "user: a", "assistant: answer to a", "user: b", "assistant: answer to b", "user: c", "assistant: answer to c"}},
+		{"drops oldest turns", 29 * 5, 0, []string{"system: prompt", "user: b", "assistant: answer to b", "user: c", "assistant: answer to c"}},
+		{"keeps system prompt and latest turn", 10, 0, []string{"system: prompt", "user: c", "assistant: answer to c"}},
+		{"context budget is smaller", 1000, 29 * 3, []string{"system: prompt", "user: c", "assistant: answer to c"}},
+		{"policy budget is smaller", 29 * 3, 1000, []string{"system: prompt", "user: c", "assistant: answer to c"}},
+		{"budget without policy limit", 0, 29 * 5, []string{"system: prompt", "user: b", "assistant: answer to b", "user: c", "assistant: answer to c"}},
+	}
+	for _, tt := range tests {
+		ctx := WithBudget(context.Background(), tt.budget)
+		got, err := WindowPolicy{MaxTokens: tt.maxTokens}.Apply(ctx, &model.Conversation{}, conversationOf("a", "b", "c"))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(contents(got), tt.want) {
+			t.Errorf("%s: Apply() = %v, want %v", tt.name, contents(got), tt.want)
+		}
+	}
+}
+
+func TestWindowPolicyKeepsToolResultsWithTheirCall(t *testing.T) {
+	messages := append(conversationOf("a"),
+		model.Message{Role: "user", Content: pad("b")},
+		model.Message{Role: "assistant", ToolCalls: []model.ToolCall{{ID: "1", Name: "geocode", Arguments: "{}"}}},
+		model.Message{Role: "tool", Content: pad("Paris"), ToolCallID: "1"},
+		model.Message{Role: "assistant", Content: pad("answer to b")},
+		model.Message{Role: "user", Content: pad("c")},
+	)
+
+	// The second turn does not fit whole, so none of it is kept
+	got, err := WindowPolicy{MaxTokens: 29*4 + 5}.Apply(context.Background(), &model.Conversation{}, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"system: prompt", "user: c"}
+	if !reflect.DeepEqual(contents(got), want) {
+		t.Errorf("Apply() = %v, want %v", contents(got), want)
+	}
+}
+
+func TestLastTurnsPolicy(t *testing.T) {
+	tests := []struct {
+		turns int
+		want  []string
+	}{
+		{0, []string{"system: prompt", "user: a", "assistant: answer to a", "user: b", "assistant: answer to b", "user: c", "assistant: answer to c"}},
+		{1, []string{"system: prompt", "user: c", "assistant: answer to c"}},
+		{2, []string{"system: prompt", "user: b", "assistant: answer to b", "user: c", "assistant: answer to c"}},
+		{5, []string{"system: prompt", "user: a", "assistant: answer to a", "user: b", "assistant: answer to b", "user: c", "assistant: answer to c"}},
+	}
+	for _, tt := range tests {
+		got, err := LastTurnsPolicy{Turns: tt.turns}.Apply(context.Background(), &model.Conversation{}, conversationOf("a", "b", "c"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(contents(got), tt.want) {
+			t.Errorf("Turns %d: Apply() = %v, want %v", tt.turns, contents(got), tt.want)
+		}
+	}
+}
+
+func TestPoliciesDoNotModifyHistory(t *testing.T) {
+	policies := []Policy{FullPolicy{}, WindowPolicy{MaxTokens: 10}, LastTurnsPolicy{Turns: 1}}
+	for _, policy := range policies {
+		messages := conversationOf("a", "b")
+		before := append([]model.Message(nil), messages...)
+		if _, err := policy.Apply(context.Background(), &model.Conversation{}, messages); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(messages, before) {
+			t.Errorf("%T modified the history", policy)
+		}
+	}
+}
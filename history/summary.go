@@ -0,0 +1,136 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"geoai-app/llm"
+	"geoai-app/model"
+)
+
+// SummaryStore keeps the rolling summary of a conversation and the usage of
+// the calls writing it
+type SummaryStore interface {
+	UsageStore
+	UpdateContextSummary(conversation *model.Conversation, summary string, upToMessageID uint) error
+}
+
+const summaryPrompt = `Summarize the conversation below for your own later reference. Keep every place name, coordinate, number, decision and open question. Reply with the summary only.`
+
+// SummaryPolicy replaces older turns with a rolling LLM-generated summary
+// stored on the conversation. Once the history exceeds MaxTokens, the older
+// half of the budget is folded into the summary.
+type SummaryPolicy struct {
+	MaxTokens int
+	Provider  llm.Provider
+	Store     SummaryStore
+}
+
+// Apply returns the system prompt, the summary and the unsummarized turns
+func (p *SummaryPolicy) Apply(ctx context.Context, conversation *model.Conversation, messages []model.Message) ([]model.Message, error) {
+	system, turns := splitTurns(messages)
+
+	// Skip the turns already covered by the summary. A summary written on
+	// another branch describes messages this branch does not have.
+	summary := conversation.ContextSummary
+	if covered, ok := coveredTurns(turns, conversation.ContextSummaryMessageID); !ok {
+		summary = ""
+	} else if covered > 0 {
+		turns = turns[min(covered, len(turns)-1):]
+	}
+
+	budget := maxPromptTokens(ctx, p.MaxTokens) - EstimateAll(system)
+	if EstimateAll(join(nil, turns))+summaryTokens(summary) <= budget {
+		return withSummary(system, summary, turns), nil
+	}
+
+	// Keep the newest turns within half the budget and summarize the rest
+	kept := fitTurns(turns, budget/2)
+	older := turns[:len(turns)-len(kept)]
+	if len(older) > 0 {
+		var err error
+		summary, err = p.summarize(ctx, conversation.ID, summary, join(nil, older))
+		if err != nil {
+			return nil, err
+		}
+		if err := p.Store.UpdateContextSummary(conversation, summary, lastID(older[len(older)-1])); err != nil {
+			return nil, err
+		}
+	}
+
+	// Trim further if the summary and the kept turns still do not fit
+	kept = fitTurns(kept, budget-summaryTokens(summary))
+	return withSummary(system, summary, kept), nil
+}
+
+// coveredTurns returns how many leading turns of the branch end at or
+// before the summarized message. ok is false when that message is not on
+// the branch, an ID of 0 covers no turns.
+func coveredTurns(turns [][]model.Message, summaryMessageID uint) (covered int, ok bool) {
+	if summaryMessageID == 0 {
+		return 0, true
+	}
+	for i, turn := range turns {
+		for _, message := range turn {
+			if message.ID == summaryMessageID {
+				return i + 1, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (p *SummaryPolicy) summarize(ctx context.Context, conversationID uint, previous string, messages []model.Message) (string, error) {
+	var transcript strings.Builder
+	if previous != "" {
+		fmt.Fprintf(&transcript, "Earlier summary:\n%s\n\n", previous)
+	}
+	for _, message := range messages {
+		if message.Content == "" {
+			continue
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n", message.Role, message.Content)
+	}
+
+	start := time.Now()
+	resp, err := p.Provider.ChatCompletion(ctx, llm.Request{Messages: []llm.Message{
+		{Role: "system", Content: summaryPrompt},
+		{Role: "user", Content: transcript.String()},
+	}})
+	if err != nil {
+		return "", fmt.Errorf("summarizing history: %w", err)
+	}
+	recordCall(ctx, p.Store, conversationID, PurposeSummary, resp, time.Since(start))
+	return strings.TrimSpace(resp.Message.Content), nil
+}
+
+func withSummary(system []model.Message, summary string, turns [][]model.Message) []model.Message {
+	if summary == "" {
+		return join(system, turns)
+	}
+	prefix := append(system[:len(system):len(system)], summaryMessage(summary))
+	return join(prefix, turns)
+}
+
+func summaryMessage(summary string) model.Message {
+	return model.Message{Role: "system", Content: "Summary of the earlier conversation:\n" + summary}
+}
+
+func summaryTokens(summary string) int {
+	if summary == "" {
+		return 0
+	}
+	return EstimateTokens(summaryMessage(summary))
+}
+
+// lastID returns the ID of the last stored message of a turn
+func lastID(turn []model.Message) uint {
+	for i := len(turn) - 1; i >= 0; i-- {
+		if turn[i].ID != 0 {
+			return turn[i].ID
+		}
+	}
+	return 0
+}
@@ -0,0 +1,183 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"geoai-app/llm"
+	"geoai-app/model"
+)
+
+// summaryProvider answers every request with a fixed summary
+type summaryProvider struct {
+	summary  string
+	err      error
+	requests []llm.Request
+}
+
+func (p *summaryProvider) Name() string {
+	return "summary"
+}
+
+func (p *summaryProvider) ChatCompletion(_ context.Context, req llm.Request) (*llm.Response, error) {
+	p.requests = append(p.requests, req)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &llm.Response{
+		Message:  llm.Message{Role: "assistant", Content: p.summary},
+		Usage:    llm.Usage{PromptTokens: 50, CompletionTokens: 5},
+		Model:    "summary-model",
+		Provider: p.Name(),
+	}, nil
+}
+
+func (p *summaryProvider) StreamChatCompletion(ctx context.Context, req llm.Request, _ llm.DeltaFunc) (*llm.Response, error) {
+	return p.ChatCompletion(ctx, req)
+}
+
+// summaryStore keeps the summary on the conversation like the repository
+type summaryStore struct {
+	calls []model.ModelCall
+}
+
+func (s *summaryStore) RecordModelCall(call model.ModelCall) error {
+	s.calls = append(s.calls, call)
+	return nil
+}
+
+func (s *summaryStore) UpdateContextSummary(conversation *model.Conversation, summary string, upToMessageID uint) error {
+	conversation.ContextSummary = summary
+	conversation.ContextSummaryMessageID = upToMessageID
+	return nil
+}
+
+// summaryBudget fits the system prompt, a summary and two turns
+const summaryBudget = 29 + 18 + 29*4 + 6
+
+func TestSummaryPolicyFitsWithoutSummary(t *testing.T) {
+	provider := &summaryProvider{summary: "unused"}
+	policy := &SummaryPolicy{MaxTokens: 1000, Provider: provider, Store: &summaryStore{}}
+
+	messages := conversationOf("a", "b")
+	got, err := policy.Apply(context.Background(), &model.Conversation{}, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, messages) {
+		t.Errorf("Apply() = %v, want the full history", contents(got))
+	}
+	if len(provider.requests) != 0 {
+		t.Errorf("summarized a history that fits")
+	}
+}
+
+func TestSummaryPolicySummarizesAndReuses(t *testing.T) {
+	provider := &summaryProvider{summary: "Asked about a and b"}
+	store := &summaryStore{}
+	policy := &SummaryPolicy{MaxTokens: summaryBudget, Provider: provider, Store: store}
+	conversation := &model.Conversation{ID: 7}
+
+	// Two of three turns do not fit in half the budget
+	got, err := policy.Apply(context.Background(), conversation, conversationOf("a", "b", "c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"system: prompt", "system: Summary of the earlier conversation:\nAsked about a and b", "user: c", "assistant: answer to c"}
+	if !reflect.DeepEqual(contents(got), want) {
+		t.Fatalf("Apply() = %v, want %v", contents(got), want)
+	}
+	if len(provider.requests) != 1 {
+		t.Fatalf("got %d summary requests, want 1", len(provider.requests))
+	}
+	transcript := provider.requests[0].Messages[1].Content
+	if !strings.Contains(transcript, "answer to b") || strings.Contains(transcript, "answer to c") {
+		t.Errorf("summarized the wrong turns: %q", transcript)
+	}
+	if conversation.ContextSummaryMessageID != 5 {
+		t.Errorf("summary covers up to message %d, want 5", conversation.ContextSummaryMessageID)
+	}
+	if len(store.calls) != 1 || store.calls[0].Purpose != PurposeSummary || store.calls[0].ConversationID != 7 {
+		t.Errorf("recorded calls %+v, want one summary call", store.calls)
+	}
+
+	// The next turn reuses the stored summary without calling the model
+	got, err = policy.Apply(context.Background(), conversation, conversationOf("a", "b", "c", "d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"system: prompt", "system: Summary of the earlier conversation:\nAsked about a and b", "user: c", "assistant: answer to c", "user: d", "assistant: answer to d"}
+	if !reflect.DeepEqual(contents(got), want) {
+		t.Errorf("Apply() = %v, want %v", contents(got), want)
+	}
+	if len(provider.requests) != 1 {
+		t.Errorf("summarized again although the summary was reused")
+	}
+}
+
+func TestSummaryPolicyExtendsSummary(t *testing.T) {
+	provider := &summaryProvider{summary: "Asked about a to c"}
+	policy := &SummaryPolicy{MaxTokens: summaryBudget, Provider: provider, Store: &summaryStore{}}
+	conversation := &model.Conversation{ContextSummary: "Asked about a and b", ContextSummaryMessageID: 5}
+
+	got, err := policy.Apply(context.Background(), conversation, conversationOf("a", "b", "c", "d", "e"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"system: prompt", "system: Summary of the earlier conversation:\nAsked about a to c", "user: e", "assistant: answer to e"}
+	if !reflect.DeepEqual(contents(got), want) {
+		t.Errorf("Apply() = %v, want %v", contents(got), want)
+	}
+	transcript := provider.requests[0].Messages[1].Content
+	if !strings.HasPrefix(transcript, "Earlier summary:\nAsked about a and b") {
+		t.Errorf("transcript does not start with the earlier summary: %q", transcript)
+	}
+	if strings.Contains(transcript, "answer to a") {
+		t.Errorf("summarized turns the summary already covers: %q", transcript)
+	}
+}
+
+func TestSummaryPolicyIgnoresSummaryOfOtherBranch(t *testing.T) {
+	provider := &summaryProvider{summary: "unused"}
+	policy := &SummaryPolicy{MaxTokens: 1000, Provider: provider, Store: &summaryStore{}}
+	conversation := &model.Conversation{ContextSummary: "Another branch", ContextSummaryMessageID: 99}
+
+	messages := conversationOf("a", "b")
+	got, err := policy.Apply(context.Background(), conversation, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, messages) {
+		t.Errorf("Apply() = %v, want the branch without the summary", contents(got))
+	}
+}
+
+func TestSummaryPolicyChargesTokens(t *testing.T) {
+	provider := &summaryProvider{summary: "Asked about a and b"}
+	policy := &SummaryPolicy{MaxTokens: 29 * 5, Provider: provider, Store: &summaryStore{}}
+
+	charged := 0
+	ctx := WithTokenCharge(context.Background(), func(tokens int) { charged += tokens })
+	if _, err := policy.Apply(ctx, &model.Conversation{}, conversationOf("a", "b", "c")); err != nil {
+		t.Fatal(err)
+	}
+	if charged != 55 {
+		t.Errorf("charged %d tokens, want 55", charged)
+	}
+}
+
+func TestSummaryPolicyFails(t *testing.T) {
+	provider := &summaryProvider{err: errors.New("provider down")}
+	conversation := &model.Conversation{}
+	policy := &SummaryPolicy{MaxTokens: 29 * 5, Provider: provider, Store: &summaryStore{}}
+
+	if _, err := policy.Apply(context.Background(), conversation, conversationOf("a", "b", "c")); err == nil {
+		t.Fatal("Apply succeeded without a summary")
+	}
+	if conversation.ContextSummary != "" {
+		t.Errorf("stored summary %q after a failed call", conversation.ContextSummary)
+	}
+}
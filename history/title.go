@@ -0,0 +1,105 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"geoai-app/llm"
+)
+
+// TitleStore keeps generated conversation titles and the usage of the
+// calls generating them
+type TitleStore interface {
+	UsageStore
+	// SetGeneratedTitle stores the title and summary unless the
+	// conversation got a title in the meantime
+	SetGeneratedTitle(conversationID uint, title, summary string) error
+}
+
+const titlePrompt = `Write a title of at most six words for the conversation below%s. Reply with JSON only: {"title": "...", "summary": "..."}`
+
+// maxTitleLength bounds titles in characters
+const maxTitleLength = 80
+
+// Titler names conversations after their first exchange using the LLM
+// provider. When the model fails, the title falls back to the start of the
+// question.
+type Titler struct {
+	Provider llm.Provider
+	Store    TitleStore
+	// Summarize also asks for a one-paragraph summary
+	Summarize bool
+}
+
+// Generate writes the title and summary of a conversation from its first
+// question and answer
+func (t *Titler) Generate(ctx context.Context, conversationID uint, question, answer string) error {
+	title, summary, err := t.ask(ctx, conversationID, question, answer)
+	if err != nil {
+		fmt.Printf("Error generating conversation title, using the question: %v\n", err)
+		title, summary = FallbackTitle(question), ""
+	}
+	return t.Store.SetGeneratedTitle(conversationID, title, summary)
+}
+
+func (t *Titler) ask(ctx context.Context, conversationID uint, question, answer string) (string, string, error) {
+	instructions := fmt.Sprintf(titlePrompt, "")
+	if t.Summarize {
+		instructions = fmt.Sprintf(titlePrompt, " and a one-paragraph summary of it")
+	}
+
+	requested := time.Now()
+	resp, err := t.Provider.ChatCompletion(ctx, llm.Request{Messages: []llm.Message{
+		{Role: "system", Content: instructions},
+		{Role: "user", Content: fmt.Sprintf("user: %s\nassistant: %s", question, answer)},
+	}})
+	if err != nil {
+		return "", "", err
+	}
+	recordCall(ctx, t.Store, conversationID, PurposeTitle, resp, time.Since(requested))
+
+	// Models like to wrap JSON in prose or code fences
+	content := resp.Message.Content
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return "", "", fmt.Errorf("no JSON in title reply %q", content)
+	}
+	var reply struct {
+		Title   string `json:"title"`
+		Summary string `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(content[start:end+1]), &reply); err != nil {
+		return "", "", fmt.Errorf("parsing title reply: %w", err)
+	}
+
+	title := truncate(strings.Trim(strings.TrimSpace(reply.Title), `"'.`), maxTitleLength)
+	if title == "" {
+		return "", "", fmt.Errorf("empty title in reply %q", content)
+	}
+	summary := ""
+	if t.Summarize {
+		summary = strings.TrimSpace(reply.Summary)
+	}
+	return title, summary, nil
+}
+
+// FallbackTitle shortens the question to a title
+func FallbackTitle(question string) string {
+	return truncate(strings.Join(strings.Fields(question), " "), maxTitleLength)
+}
+
+// truncate cuts text to at most limit characters at a word boundary
+func truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	runes := []rune(text)[:limit-1]
+	if cut := strings.LastIndex(string(runes), " "); cut > 0 {
+		return strings.TrimSpace(string(runes)[:cut]) + "…"
+	}
+	return string(runes) + "…"
+}
@@ -0,0 +1,32 @@
+package history
+
+import (
+	"unicode/utf8"
+
+	"geoai-app/model"
+)
+
+// Rough token accounting for OpenAI-style chat formats. Real tokenizers vary
+// by model, so budgets should leave some headroom.
+const (
+	charsPerToken    = 4
+	tokensPerMessage = 4
+)
+
+// EstimateTokens estimates the prompt tokens a message costs
+func EstimateTokens(message model.Message) int {
+	chars := utf8.RuneCountInString(message.Content)
+	for _, call := range message.ToolCalls {
+		chars += utf8.RuneCountInString(call.Name) + utf8.RuneCountInString(call.Arguments)
+	}
+	return tokensPerMessage + (chars+charsPerToken-1)/charsPerToken
+}
+
+// EstimateAll estimates the prompt tokens of a list of messages
+func EstimateAll(messages []model.Message) int {
+	total := 0
+	for _, message := range messages {
+		total += EstimateTokens(message)
+	}
+	return total
+}
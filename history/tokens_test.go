@@ -0,0 +1,31 @@
+package history
+
+import (
+	"testing"
+
+	"geoai-app/model"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		name    string
+		message model.Message
+		want    int
+	}{
+		{"empty", model.Message{Role: "user"}, 4},
+		{"rounds up", model.Message{Role: "user", Content: "Paris"}, 6},
+		{"whole tokens", model.Message{Role: "user", Content: "Rome"}, 5},
+		{"counts runes", model.Message{Role: "user", Content: "Zürich"}, 6},
+		{"tool calls", model.Message{Role: "assistant", ToolCalls: []model.ToolCall{{Name: "geocode", Arguments: `{"q":"Oslo"}`}}}, 9},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.message); got != tt.want {
+			t.Errorf("%s: EstimateTokens() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	all := []model.Message{tests[1].message, tests[2].message}
+	if got := EstimateAll(all); got != 11 {
+		t.Errorf("EstimateAll() = %d, want 11", got)
+	}
+}
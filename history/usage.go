@@ -0,0 +1,52 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"geoai-app/llm"
+	"geoai-app/model"
+)
+
+// Purposes of the model calls recorded by this package
+const (
+	PurposeTitle   = "title"
+	PurposeSummary = "summary"
+)
+
+// UsageStore records model calls made for a conversation outside its turns
+type UsageStore interface {
+	RecordModelCall(call model.ModelCall) error
+}
+
+// chargeKey carries the function charging tokens to the requesting user
+type chargeKey struct{}
+
+// WithTokenCharge returns a context whose policies charge the tokens of
+// their model calls through charge
+func WithTokenCharge(ctx context.Context, charge func(tokens int)) context.Context {
+	return context.WithValue(ctx, chargeKey{}, charge)
+}
+
+// recordCall stores the usage of a model call and charges its tokens when
+// the context carries a charge function. Failing to store usage does not
+// fail the call.
+func recordCall(ctx context.Context, store UsageStore, conversationID uint, purpose string, resp *llm.Response, latency time.Duration) {
+	if charge, ok := ctx.Value(chargeKey{}).(func(int)); ok {
+		charge(resp.Usage.PromptTokens + resp.Usage.CompletionTokens)
+	}
+
+	err := store.RecordModelCall(model.ModelCall{
+		ConversationID:   conversationID,
+		Purpose:          purpose,
+		Provider:         resp.Provider,
+		Model:            resp.Model,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		LatencyMS:        int(latency.Milliseconds()),
+	})
+	if err != nil {
+		fmt.Printf("Error recording %s usage: %v\n", purpose, err)
+	}
+}
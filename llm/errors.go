@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the provider while the circuit
+// breaker considers it unhealthy
+var ErrCircuitOpen = errors.New("LLM provider circuit breaker is open")
+
+// StatusError is returned when a provider answers with a non-200 status
+type StatusError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+	// RetryAfter is the delay requested by the provider, zero when absent
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s API returned status: %d, body: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// newStatusError reads the status and Retry-After header of a failed response
+func newStatusError(provider string, resp *http.Response, body []byte) *StatusError {
+	return &StatusError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// parseRetryAfter accepts both the delay-seconds and the HTTP-date forms
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// IsRetryable reports whether a failed call may succeed when repeated:
+// rate limits, server errors, timeouts and network failures
+func IsRetryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// ErrorClass groups provider failures by how a fallback should react
+type ErrorClass string
+
+const (
+	ClassRateLimit     ErrorClass = "rate_limit"
+	ClassContextLength ErrorClass = "context_length"
+	ClassServer        ErrorClass = "server_error"
+	ClassUnavailable   ErrorClass = "unavailable"
+	ClassClient        ErrorClass = "client_error"
+	ClassCanceled      ErrorClass = "canceled"
+)
+
+// Classify sorts a provider error into an ErrorClass
+func Classify(err error) ErrorClass {
+	var statusErr *StatusError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ClassCanceled
+	case errors.Is(err, ErrCircuitOpen), errors.Is(err, context.DeadlineExceeded):
+		return ClassUnavailable
+	case errors.As(err, &statusErr):
+		switch {
+		case statusErr.StatusCode == http.StatusTooManyRequests:
+			return ClassRateLimit
+		case statusErr.StatusCode >= 500:
+			return ClassServer
+		case isContextLengthError(statusErr):
+			return ClassContextLength
+		default:
+			return ClassClient
+		}
+	case IsRetryable(err):
+		return ClassUnavailable
+	default:
+		return ClassClient
+	}
+}
+
+// isContextLengthError recognises the context window errors of
+// OpenAI-compatible APIs, which have no dedicated status code
+func isContextLengthError(err *StatusError) bool {
+	if err.StatusCode != http.StatusBadRequest && err.StatusCode != http.StatusRequestEntityTooLarge {
+		return false
+	}
+	body := strings.ToLower(err.Body)
+	for _, marker := range []string{"context_length_exceeded", "context length", "context window", "maximum context", "too many tokens"} {
+		if strings.Contains(body, marker) {
+			return true
+		}
+	}
+	return false
+}
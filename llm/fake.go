@@ -0,0 +1,77 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// FakeProvider is a deterministic provider for local development and CI.
+// It answers in the GeoAI response format by echoing the last user message.
+type FakeProvider struct {
+	Model string
+}
+
+// NewFakeProvider creates a new instance of FakeProvider
+func NewFakeProvider(model string) *FakeProvider {
+	return &FakeProvider{Model: withDefault(model, "fake")}
+}
+
+// Name returns the provider name
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+// ChatCompletion answers with the last user message echoed back
+func (p *FakeProvider) ChatCompletion(_ context.Context, req Request) (*Response, error) {
+	var prompt string
+	promptTokens := 0
+	for _, message := range req.Messages {
+		promptTokens += len(strings.Fields(message.Content))
+		if message.Role == "user" {
+			prompt = message.Content
+		}
+	}
+
+	content, err := json.Marshal(map[string]string{
+		"locations": "",
+		"messages":  "You said: " + prompt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	completionTokens := len(strings.Fields(string(content)))
+	return &Response{
+		Message: Message{Role: "assistant", Content: string(content)},
+		Usage: Usage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
+		},
+		Model:    withDefault(req.Model, p.Model),
+		Provider: p.Name(),
+	}, nil
+}
+
+// StreamChatCompletion streams the echoed answer word by word
+func (p *FakeProvider) StreamChatCompletion(ctx context.Context, req Request, onDelta DeltaFunc) (*Response, error) {
+	resp, err := p.ChatCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var streamed strings.Builder
+	for _, word := range strings.SplitAfter(resp.Message.Content, " ") {
+		if err := ctx.Err(); err != nil {
+			resp.Message.Content = streamed.String()
+			return resp, err
+		}
+		if err := onDelta(word); err != nil {
+			resp.Message.Content = streamed.String()
+			return resp, err
+		}
+		streamed.WriteString(word)
+	}
+	return resp, nil
+}
@@ -0,0 +1,87 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// FallbackTarget is a provider and the model to request from it. An empty
+// model keeps the model of the request.
+type FallbackTarget struct {
+	Provider Provider
+	Model    string
+}
+
+// FallbackProvider tries an ordered chain of providers and models, falling
+// through to the next target on rate limits, context length errors and
+// unavailable upstreams. Other client errors are returned as they would
+// fail on every target.
+type FallbackProvider struct {
+	Targets []FallbackTarget
+}
+
+// NewFallbackProvider creates a new instance of FallbackProvider
+func NewFallbackProvider(targets ...FallbackTarget) *FallbackProvider {
+	return &FallbackProvider{Targets: targets}
+}
+
+// Name returns the name of the primary provider
+func (p *FallbackProvider) Name() string {
+	return p.Targets[0].Provider.Name()
+}
+
+// ChatCompletion asks each target in turn until one answers
+func (p *FallbackProvider) ChatCompletion(ctx context.Context, req Request) (*Response, error) {
+	var err error
+	for i, target := range p.Targets {
+		var resp *Response
+		resp, err = target.Provider.ChatCompletion(ctx, target.request(req))
+		if err == nil || !p.fallThrough(i, err) {
+			return resp, err
+		}
+	}
+	return nil, err
+}
+
+// StreamChatCompletion streams from each target in turn until one answers.
+// Once a delta has been forwarded the stream stays with that target.
+func (p *FallbackProvider) StreamChatCompletion(ctx context.Context, req Request, onDelta DeltaFunc) (*Response, error) {
+	var err error
+	for i, target := range p.Targets {
+		streamed := false
+		var resp *Response
+		resp, err = target.Provider.StreamChatCompletion(ctx, target.request(req), func(delta string) error {
+			streamed = true
+			return onDelta(delta)
+		})
+		if err == nil || streamed || !p.fallThrough(i, err) {
+			return resp, err
+		}
+	}
+	return nil, err
+}
+
+// fallThrough reports whether the failure of target i should be followed by
+// the next target
+func (p *FallbackProvider) fallThrough(i int, err error) bool {
+	if i == len(p.Targets)-1 {
+		return false
+	}
+
+	class := Classify(err)
+	switch class {
+	case ClassRateLimit, ClassContextLength, ClassServer, ClassUnavailable:
+		next := p.Targets[i+1]
+		fmt.Printf("Falling back from %s to %s %s after %s: %v\n", p.Targets[i].Provider.Name(), next.Provider.Name(), next.Model, class, err)
+		return true
+	default:
+		return false
+	}
+}
+
+func (t FallbackTarget) request(req Request) Request {
+	if t.Model != "" {
+		req.Model = t.Model
+	}
+	return req
+}
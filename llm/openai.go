@@ -0,0 +1,214 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OpenAIProvider talks to any OpenAI-compatible chat completions API,
+// which covers Groq, OpenAI, Ollama and llama.cpp
+type OpenAIProvider struct {
+	name       string
+	BaseURL    string
+	APIKey     string
+	Model      string
+	HTTPClient *http.Client
+}
+
+// NewOpenAIProvider creates a new instance of OpenAIProvider
+func NewOpenAIProvider(name, baseURL, apiKey, model string) *OpenAIProvider {
+	return &OpenAIProvider{
+		name:       name,
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		APIKey:     apiKey,
+		Model:      model,
+		HTTPClient: &http.Client{},
+	}
+}
+
+// Name returns the provider name
+func (p *OpenAIProvider) Name() string {
+	return p.name
+}
+
+type openAIRequest struct {
+	Model         string               `json:"model"`
+	Messages      []Message            `json:"messages"`
+	Tools         []ToolDefinition     `json:"tools,omitempty"`
+	Temperature   *float64             `json:"temperature,omitempty"`
+	MaxTokens     *int                 `json:"max_tokens,omitempty"`
+	TopP          *float64             `json:"top_p,omitempty"`
+	Seed          *int                 `json:"seed,omitempty"`
+	Stop          []string             `json:"stop,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message Message `json:"message"`
+	} `json:"choices"`
+	Usage Usage `json:"usage"`
+}
+
+type openAIStreamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+	// Groq reports streaming usage under x_groq
+	XGroq *struct {
+		Usage *Usage `json:"usage"`
+	} `json:"x_groq"`
+}
+
+// ChatCompletion sends the messages to the chat completions endpoint
+func (p *OpenAIProvider) ChatCompletion(ctx context.Context, req Request) (*Response, error) {
+	model := withDefault(req.Model, p.Model)
+
+	payload := newOpenAIRequest(model, req)
+	payload.Tools = req.Tools
+	resp, err := p.post(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+
+	var apiResponse openAIResponse
+	if err := json.Unmarshal(respBody, &apiResponse); err != nil {
+		return nil, fmt.Errorf("unmarshaling response: %w", err)
+	}
+
+	if len(apiResponse.Choices) == 0 {
+		return nil, fmt.Errorf("no choices returned in response")
+	}
+
+	return &Response{
+		Message:  apiResponse.Choices[0].Message,
+		Usage:    apiResponse.Usage,
+		Model:    withDefault(apiResponse.Model, model),
+		Provider: p.name,
+	}, nil
+}
+
+// StreamChatCompletion streams the completion as server-sent events
+func (p *OpenAIProvider) StreamChatCompletion(ctx context.Context, req Request, onDelta DeltaFunc) (*Response, error) {
+	model := withDefault(req.Model, p.Model)
+
+	payload := newOpenAIRequest(model, req)
+	payload.Stream = true
+	payload.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	resp, err := p.post(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &Response{Message: Message{Role: "assistant"}, Model: model, Provider: p.name}
+	var content strings.Builder
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			result.Message.Content = content.String()
+			return result, fmt.Errorf("unmarshaling stream chunk: %w", err)
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = *chunk.Usage
+		} else if chunk.XGroq != nil && chunk.XGroq.Usage != nil {
+			result.Usage = *chunk.XGroq.Usage
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		content.WriteString(delta)
+		if err := onDelta(delta); err != nil {
+			result.Message.Content = content.String()
+			return result, err
+		}
+	}
+
+	result.Message.Content = content.String()
+	if err := scanner.Err(); err != nil {
+		return result, fmt.Errorf("reading stream: %w", err)
+	}
+	return result, nil
+}
+
+// newOpenAIRequest builds the request payload with the sampling parameters
+func newOpenAIRequest(model string, req Request) openAIRequest {
+	return openAIRequest{
+		Model:       model,
+		Messages:    req.Messages,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		TopP:        req.TopP,
+		Seed:        req.Seed,
+		Stop:        req.Stop,
+	}
+}
+
+// post sends a chat completions request and checks the response status
+func (p *OpenAIProvider) post(ctx context.Context, payload openAIRequest) (*http.Response, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling payload: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/chat/completions", bytes.NewReader(payloadBytes))
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	resp, err := p.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("making HTTP request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, newStatusError(p.name, resp, respBody)
+	}
+	return resp, nil
+}
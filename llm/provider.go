@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Message is a single chat message exchanged with a provider
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// ToolCalls are the tools an assistant message asks to run
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID links a tool message to the call it answers
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+// ToolCall is a function call requested by the model
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall holds the function name and its JSON encoded arguments
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolDefinition describes a tool the model may call
+type ToolDefinition struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+// FunctionDefinition describes a function with a JSON schema for its parameters
+type FunctionDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// Usage reports the token counts of a completion
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Request is a chat completion request
+type Request struct {
+	Model    string
+	Messages []Message
+	// Tools the model may call, not supported when streaming
+	Tools []ToolDefinition
+	Params
+}
+
+// Params are optional sampling parameters, unset fields use the provider
+// defaults
+type Params struct {
+	Temperature *float64
+	MaxTokens   *int
+	TopP        *float64
+	Seed        *int
+	Stop        []string
+}
+
+// Response is the assistant message returned by a provider
+type Response struct {
+	Message Message
+	Usage   Usage
+	Model   string
+	// Provider names the provider that answered
+	Provider string
+}
+
+// DeltaFunc receives each content fragment of a streamed completion.
+// Returning an error stops the stream.
+type DeltaFunc func(delta string) error
+
+// Provider generates chat completions from a language model
+type Provider interface {
+	Name() string
+	ChatCompletion(ctx context.Context, req Request) (*Response, error)
+	// StreamChatCompletion calls onDelta for every content fragment and
+	// returns the assembled message. When the stream breaks off, the
+	// partial message is returned together with the error.
+	StreamChatCompletion(ctx context.Context, req Request, onDelta DeltaFunc) (*Response, error)
+}
+
+// Config selects and configures a provider
+type Config struct {
+	Provider string
+	Model    string
+	BaseURL  string
+	APIKey   string
+}
+
+const (
+	groqBaseURL     = "https://api.groq.com/openai/v1"
+	openAIBaseURL   = "https://api.openai.com/v1"
+	ollamaBaseURL   = "http://localhost:11434/v1"
+	llamaCPPBaseURL = "http://localhost:8080/v1"
+)
+
+// NewProvider creates the provider named in the config
+func NewProvider(cfg Config) (Provider, error) {
+	switch cfg.Provider {
+	case "", "groq":
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("groq provider requires an API key")
+		}
+		return NewOpenAIProvider("groq", withDefault(cfg.BaseURL, groqBaseURL), cfg.APIKey, cfg.Model), nil
+	case "openai":
+		if cfg.APIKey == "" && cfg.BaseURL == "" {
+			return nil, fmt.Errorf("openai provider requires an API key or a base URL")
+		}
+		return NewOpenAIProvider("openai", withDefault(cfg.BaseURL, openAIBaseURL), cfg.APIKey, cfg.Model), nil
+	case "ollama":
+		return NewOpenAIProvider("ollama", withDefault(cfg.BaseURL, ollamaBaseURL), cfg.APIKey, cfg.Model), nil
+	case "llamacpp":
+		return NewOpenAIProvider("llamacpp", withDefault(cfg.BaseURL, llamaCPPBaseURL), cfg.APIKey, cfg.Model), nil
+	case "fake":
+		return NewFakeProvider(cfg.Model), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q", cfg.Provider)
+	}
+}
+
+func withDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,235 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ResilienceConfig configures timeouts, retries and the circuit breaker
+type ResilienceConfig struct {
+	// Timeout bounds each provider call, including retries
+	Timeout time.Duration
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries int
+	// BaseDelay and MaxDelay bound the jittered exponential backoff
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// FailureThreshold consecutive failed calls open the circuit for Cooldown
+	FailureThreshold int
+	Cooldown         time.Duration
+}
+
+// ResilientProvider wraps a provider with per-call deadlines, retries on
+// retryable failures and a circuit breaker that fails fast while the
+// upstream is unhealthy
+type ResilientProvider struct {
+	Provider
+	config  ResilienceConfig
+	breaker *CircuitBreaker
+	sleep   func(ctx context.Context, delay time.Duration) error
+}
+
+// NewResilientProvider creates a new instance of ResilientProvider
+func NewResilientProvider(provider Provider, config ResilienceConfig) *ResilientProvider {
+	return &ResilientProvider{
+		Provider: provider,
+		config:   config,
+		breaker:  NewCircuitBreaker(config.FailureThreshold, config.Cooldown),
+		sleep:    sleepContext,
+	}
+}
+
+// ChatCompletion calls the wrapped provider with retries
+func (p *ResilientProvider) ChatCompletion(ctx context.Context, req Request) (*Response, error) {
+	var resp *Response
+	err := p.do(ctx, func(ctx context.Context) error {
+		var err error
+		resp, err = p.Provider.ChatCompletion(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// StreamChatCompletion calls the wrapped provider with retries. Once a
+// delta has been forwarded the stream is not retried, so the client never
+// sees content twice.
+func (p *ResilientProvider) StreamChatCompletion(ctx context.Context, req Request, onDelta DeltaFunc) (*Response, error) {
+	var resp *Response
+	streamed := false
+	err := p.do(ctx, func(ctx context.Context) error {
+		var err error
+		resp, err = p.Provider.StreamChatCompletion(ctx, req, func(delta string) error {
+			streamed = true
+			return onDelta(delta)
+		})
+		if err != nil && streamed {
+			return permanent{err}
+		}
+		return err
+	})
+	var perm permanent
+	if errors.As(err, &perm) {
+		err = perm.err
+	}
+	return resp, err
+}
+
+// permanent marks an error that must not be retried
+type permanent struct {
+	err error
+}
+
+func (p permanent) Error() string {
+	return p.err.Error()
+}
+
+// Unwrap lets the breaker classify the failure that ended the stream
+func (p permanent) Unwrap() error {
+	return p.err
+}
+
+func (p *ResilientProvider) do(ctx context.Context, call func(ctx context.Context) error) error {
+	if !p.breaker.Allow() {
+		return ErrCircuitOpen
+	}
+
+	if p.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.config.Timeout)
+		defer cancel()
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = call(ctx)
+		var perm permanent
+		if err == nil || errors.As(err, &perm) || !IsRetryable(err) || attempt >= p.config.MaxRetries || ctx.Err() != nil {
+			break
+		}
+
+		delay, ok := p.backoff(ctx, attempt, err)
+		if !ok {
+			// Waiting would outlast the deadline, fail now so a fallback
+			// provider can answer instead
+			break
+		}
+		fmt.Printf("Retrying %s provider in %v after: %v\n", p.Name(), delay, err)
+		if sleepErr := p.sleep(ctx, delay); sleepErr != nil {
+			break
+		}
+	}
+
+	// Only upstream health problems count against the breaker. Client
+	// errors and callers going away do not.
+	switch {
+	case err == nil:
+		p.breaker.Success()
+	case IsRetryable(err) && ctx.Err() == nil || errors.Is(err, context.DeadlineExceeded):
+		p.breaker.Failure()
+	default:
+		p.breaker.Release()
+	}
+	return err
+}
+
+// backoff returns the delay before the next attempt: the provider's
+// Retry-After when given, otherwise full jitter exponential backoff. It
+// reports false when the provider asks for a longer wait than MaxDelay or
+// the delay would not leave time for another attempt before the deadline.
+func (p *ResilientProvider) backoff(ctx context.Context, attempt int, err error) (time.Duration, bool) {
+	var delay time.Duration
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		delay = statusErr.RetryAfter
+		if p.config.MaxDelay > 0 && delay > p.config.MaxDelay {
+			return 0, false
+		}
+	} else {
+		ceiling := p.config.BaseDelay << attempt
+		if ceiling <= 0 || ceiling > p.config.MaxDelay {
+			ceiling = p.config.MaxDelay
+		}
+		if ceiling > 0 {
+			delay = time.Duration(rand.Int63n(int64(ceiling) + 1))
+		}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && delay >= time.Until(deadline) {
+		return 0, false
+	}
+	return delay, true
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// CircuitBreaker opens after a run of consecutive failures and lets a single
+// trial call through once the cooldown has passed
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	trial     bool
+	now       func() time.Time
+}
+
+// NewCircuitBreaker creates a breaker, a threshold of zero disables it
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// Allow reports whether a call may go ahead
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true
+	}
+	// Half-open: after the cooldown one trial call decides the state
+	if b.now().Before(b.openUntil) || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// Success closes the circuit
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.trial = false
+}
+
+// Failure records a failed call and opens the circuit at the threshold
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trial = false
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// Release ends a call whose outcome says nothing about upstream health
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
@@ -0,0 +1,295 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const okBody = `{"model":"test","choices":[{"message":{"role":"assistant","content":"ok"}}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`
+
+// upstream answers with the scripted statuses in turn, repeating the last
+type upstream struct {
+	mu         sync.Mutex
+	statuses   []int
+	retryAfter string
+	calls      int32
+}
+
+func (u *upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	call := int(atomic.AddInt32(&u.calls, 1)) - 1
+	u.mu.Lock()
+	status := u.statuses[min(call, len(u.statuses)-1)]
+	u.mu.Unlock()
+
+	if status != http.StatusOK {
+		if u.retryAfter != "" {
+			w.Header().Set("Retry-After", u.retryAfter)
+		}
+		http.Error(w, `{"error":"busy"}`, status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(okBody))
+}
+
+func (u *upstream) script(statuses ...int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.statuses = statuses
+}
+
+// newTestProvider serves the upstream and records the backoff delays
+// instead of sleeping
+func newTestProvider(t *testing.T, u *upstream, config ResilienceConfig) (*ResilientProvider, *[]time.Duration) {
+	t.Helper()
+	server := httptest.NewServer(u)
+	t.Cleanup(server.Close)
+
+	provider := NewResilientProvider(NewOpenAIProvider("test", server.URL, "", "test"), config)
+	var slept []time.Duration
+	provider.sleep = func(ctx context.Context, delay time.Duration) error {
+		slept = append(slept, delay)
+		return ctx.Err()
+	}
+	return provider, &slept
+}
+
+func testRequest() Request {
+	return Request{Messages: []Message{{Role: "user", Content: "hi"}}}
+}
+
+func TestResilientProviderRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		retryAfter string
+		config     ResilienceConfig
+		wantCalls  int32
+		wantStatus int
+		wantSleeps []time.Duration
+	}{
+		{
+			name:       "honours retry-after",
+			statuses:   []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter: "2",
+			config:     ResilienceConfig{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Second},
+			wantCalls:  2,
+			wantSleeps: []time.Duration{2 * time.Second},
+		},
+		{
+			name:       "gives up after max retries",
+			statuses:   []int{http.StatusServiceUnavailable},
+			config:     ResilienceConfig{MaxRetries: 2},
+			wantCalls:  3,
+			wantStatus: http.StatusServiceUnavailable,
+			wantSleeps: []time.Duration{0, 0},
+		},
+		{
+			name:       "does not retry client errors",
+			statuses:   []int{http.StatusBadRequest},
+			config:     ResilienceConfig{MaxRetries: 3},
+			wantCalls:  1,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "fails fast when retry-after exceeds max delay",
+			statuses:   []int{http.StatusTooManyRequests},
+			retryAfter: "60",
+			config:     ResilienceConfig{MaxRetries: 3, MaxDelay: 10 * time.Second},
+			wantCalls:  1,
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "fails fast when retry-after outlasts the deadline",
+			statuses:   []int{http.StatusServiceUnavailable},
+			retryAfter: "5",
+			config:     ResilienceConfig{Timeout: 2 * time.Second, MaxRetries: 3, MaxDelay: 10 * time.Second},
+			wantCalls:  1,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &upstream{statuses: tt.statuses, retryAfter: tt.retryAfter}
+			provider, slept := newTestProvider(t, u, tt.config)
+
+			resp, err := provider.ChatCompletion(context.Background(), testRequest())
+			if got := atomic.LoadInt32(&u.calls); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+			if len(*slept) != len(tt.wantSleeps) {
+				t.Fatalf("sleeps = %v, want %v", *slept, tt.wantSleeps)
+			}
+			for i, delay := range tt.wantSleeps {
+				if (*slept)[i] != delay {
+					t.Errorf("sleep %d = %v, want %v", i, (*slept)[i], delay)
+				}
+			}
+
+			if tt.wantStatus == 0 {
+				if err != nil || resp.Message.Content != "ok" {
+					t.Fatalf("got %v, %v, want ok", resp, err)
+				}
+				return
+			}
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.wantStatus {
+				t.Fatalf("err = %v, want status %d", err, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestBackoffJitterBounds(t *testing.T) {
+	provider := NewResilientProvider(NewFakeProvider(""), ResilienceConfig{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second})
+	busy := &StatusError{StatusCode: http.StatusServiceUnavailable}
+
+	for attempt, ceiling := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		ceiling *= time.Millisecond
+		var largest time.Duration
+		for i := 0; i < 1000; i++ {
+			delay, ok := provider.backoff(context.Background(), attempt, busy)
+			if !ok || delay < 0 || delay > ceiling {
+				t.Fatalf("attempt %d: delay %v (ok %v) outside [0, %v]", attempt, delay, ok, ceiling)
+			}
+			largest = max(largest, delay)
+		}
+		if largest < ceiling/2 {
+			t.Errorf("attempt %d: largest delay %v, jitter does not spread up to %v", attempt, largest, ceiling)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"-1", 0},
+		{"soon", 0},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	date := time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got <= 20*time.Second || got > 30*time.Second {
+		t.Errorf("parseRetryAfter(%q) = %v, want about 30s", date, got)
+	}
+}
+
+func TestCircuitBreakerOpensAndHalfOpens(t *testing.T) {
+	u := &upstream{statuses: []int{http.StatusServiceUnavailable}, retryAfter: strconv.Itoa(1)}
+	provider, _ := newTestProvider(t, u, ResilienceConfig{FailureThreshold: 2, Cooldown: time.Minute, MaxDelay: 10 * time.Second})
+	now := time.Now()
+	provider.breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if _, err := provider.ChatCompletion(context.Background(), testRequest()); Classify(err) != ClassServer {
+			t.Fatalf("call %d: err = %v, want server error", i, err)
+		}
+	}
+
+	// Open: fails fast without reaching the upstream
+	calls := atomic.LoadInt32(&u.calls)
+	if _, err := provider.ChatCompletion(context.Background(), testRequest()); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if got := atomic.LoadInt32(&u.calls); got != calls {
+		t.Fatalf("open circuit reached the upstream: %d calls, want %d", got, calls)
+	}
+
+	// Half-open: a single trial goes through once the cooldown has passed
+	now = now.Add(time.Minute)
+	if !provider.breaker.Allow() {
+		t.Fatal("trial call not allowed after the cooldown")
+	}
+	if provider.breaker.Allow() {
+		t.Fatal("second call allowed while the trial is running")
+	}
+	provider.breaker.Release()
+
+	// A failed trial opens the circuit again
+	if _, err := provider.ChatCompletion(context.Background(), testRequest()); Classify(err) != ClassServer {
+		t.Fatalf("trial err = %v, want server error", err)
+	}
+	if _, err := provider.ChatCompletion(context.Background(), testRequest()); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v after failed trial, want ErrCircuitOpen", err)
+	}
+
+	// A successful trial closes it
+	now = now.Add(time.Minute)
+	u.script(http.StatusOK)
+	for i := 0; i < 3; i++ {
+		if _, err := provider.ChatCompletion(context.Background(), testRequest()); err != nil {
+			t.Fatalf("call %d after recovery: %v", i, err)
+		}
+	}
+}
+
+func TestResilientProviderDeadline(t *testing.T) {
+	hang := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hang
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(hang) })
+
+	provider := NewResilientProvider(NewOpenAIProvider("test", server.URL, "", "test"), ResilienceConfig{Timeout: 50 * time.Millisecond, MaxRetries: 3, FailureThreshold: 1, Cooldown: time.Minute})
+	_, err := provider.ChatCompletion(context.Background(), testRequest())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+	if provider.breaker.Allow() {
+		t.Fatal("deadline expiry did not count against the breaker")
+	}
+}
+
+// brokenStream forwards a delta and then fails
+type brokenStream struct {
+	FakeProvider
+	calls int
+}
+
+func (p *brokenStream) StreamChatCompletion(_ context.Context, _ Request, onDelta DeltaFunc) (*Response, error) {
+	p.calls++
+	if err := onDelta("partial"); err != nil {
+		return nil, err
+	}
+	return nil, &StatusError{Provider: "test", StatusCode: http.StatusBadGateway}
+}
+
+func TestResilientProviderStreamFailure(t *testing.T) {
+	upstream := &brokenStream{}
+	provider := NewResilientProvider(upstream, ResilienceConfig{MaxRetries: 3, FailureThreshold: 1, Cooldown: time.Minute})
+
+	var deltas []string
+	_, err := provider.StreamChatCompletion(context.Background(), testRequest(), func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("err = %v, want the upstream status error", err)
+	}
+	if upstream.calls != 1 || len(deltas) != 1 {
+		t.Fatalf("stream retried after a delta: %d calls, deltas %v", upstream.calls, deltas)
+	}
+	if provider.breaker.Allow() {
+		t.Fatal("mid-stream failure did not count against the breaker")
+	}
+}
@@ -0,0 +1,2 @@
+ALTER TABLE messages DROP COLUMN IF EXISTS error;
+ALTER TABLE messages DROP COLUMN IF EXISTS status;
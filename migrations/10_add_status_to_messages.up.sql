@@ -0,0 +1,2 @@
+ALTER TABLE messages ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'answered';
+ALTER TABLE messages ADD COLUMN error TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS token_usage;
+DROP TABLE IF EXISTS rate_limit_buckets;
+DROP TABLE IF EXISTS user_quotas;
@@ -0,0 +1,23 @@
+-- Per-user overrides of the default limits, NULL keeps the default
+CREATE TABLE user_quotas (
+    user_id INT PRIMARY KEY,
+    requests_per_minute INT,
+    tokens_per_day INT,
+    tokens_per_month INT,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE rate_limit_buckets (
+    key VARCHAR(255) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- LLM tokens spent per user and period, e.g. day:2024-05-01 or month:2024-05
+CREATE TABLE token_usage (
+    user_id INT NOT NULL,
+    period VARCHAR(16) NOT NULL,
+    tokens BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, period),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
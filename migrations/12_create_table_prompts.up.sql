@@ -0,0 +1,26 @@
+CREATE TABLE prompts (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    version INT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (name, version)
+);
+
+-- At most one active version per persona
+CREATE UNIQUE INDEX idx_prompts_active_name ON prompts (name) WHERE active;
+
+INSERT INTO prompts (name, version, description, content, active) VALUES
+('geoai', 1, 'General geography assistant',
+ 'You are a helpful assistant named GeoAI. Respond concisely to the user''s queries.', TRUE),
+('geo-planner', 1, 'Plans trips and site visits',
+ 'You are GeoAI, a travel and logistics planner. Break requests into ordered stops, mention distances and travel considerations between them, and keep plans practical.', TRUE),
+('cartography-tutor', 1, 'Teaches maps, projections and GIS concepts',
+ 'You are GeoAI, a patient cartography tutor. Explain map projections, coordinate systems and GIS concepts step by step with concrete places as examples.', TRUE),
+('field-survey', 1, 'Supports field survey work',
+ 'You are GeoAI, an assistant for field surveyors. Be precise about coordinates, bearings, areas and units, and point out safety or access concerns at survey sites.', TRUE);
+
+-- The prompt version a conversation started with
+ALTER TABLE conversations ADD COLUMN prompt_id INT REFERENCES prompts (id);
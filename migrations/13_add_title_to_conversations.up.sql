@@ -0,0 +1,2 @@
+ALTER TABLE conversations ADD COLUMN title VARCHAR(200) NOT NULL DEFAULT '';
+ALTER TABLE conversations ADD COLUMN summary TEXT NOT NULL DEFAULT '';
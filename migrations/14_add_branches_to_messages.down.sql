@@ -0,0 +1,4 @@
+ALTER TABLE conversations DROP COLUMN IF EXISTS active_message_id;
+DROP INDEX IF EXISTS idx_messages_parent_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS forked_from_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,15 @@
+ALTER TABLE messages ADD COLUMN parent_id INT REFERENCES messages (id) ON DELETE CASCADE;
+-- Copies made by a fork point at their original, they are not counted as usage
+ALTER TABLE messages ADD COLUMN forked_from_id INT;
+ALTER TABLE conversations ADD COLUMN active_message_id INT REFERENCES messages (id) ON DELETE SET NULL;
+
+CREATE INDEX idx_messages_parent_id ON messages (parent_id);
+
+-- Existing conversations become a single branch
+UPDATE messages m
+SET parent_id = previous.parent_id
+FROM (SELECT id, LAG(id) OVER (PARTITION BY conversation_id ORDER BY id) AS parent_id FROM messages) previous
+WHERE m.id = previous.id;
+
+UPDATE conversations c
+SET active_message_id = (SELECT MAX(id) FROM messages WHERE conversation_id = c.id);
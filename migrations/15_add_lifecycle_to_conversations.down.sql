@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_conversations_user_id;
+ALTER TABLE conversations DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE conversations DROP COLUMN IF EXISTS tags;
+ALTER TABLE conversations DROP COLUMN IF EXISTS archived;
+ALTER TABLE conversations DROP COLUMN IF EXISTS pinned;
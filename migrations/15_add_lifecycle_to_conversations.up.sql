@@ -0,0 +1,7 @@
+ALTER TABLE conversations ADD COLUMN pinned BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE conversations ADD COLUMN archived BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE conversations ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+-- Deleted conversations are hidden until restored
+ALTER TABLE conversations ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_conversations_user_id ON conversations (user_id) WHERE deleted_at IS NULL;
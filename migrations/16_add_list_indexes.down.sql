@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_users_created;
+DROP INDEX IF EXISTS idx_conversations_tags;
+DROP INDEX IF EXISTS idx_conversations_user_updated;
+DROP INDEX IF EXISTS idx_conversations_user_created;
+CREATE INDEX idx_conversations_user_id ON conversations (user_id) WHERE deleted_at IS NULL;
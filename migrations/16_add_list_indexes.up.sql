@@ -0,0 +1,6 @@
+-- Keyset pagination walks these in (sort column, id) order
+DROP INDEX IF EXISTS idx_conversations_user_id;
+CREATE INDEX idx_conversations_user_created ON conversations (user_id, created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_conversations_user_updated ON conversations (user_id, updated_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_conversations_tags ON conversations USING GIN (tags);
+CREATE INDEX idx_users_created ON users (created_at, id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_conversations_search;
+DROP INDEX IF EXISTS idx_messages_search;
+ALTER TABLE conversations DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE messages DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE messages DROP COLUMN IF EXISTS search_text;
@@ -0,0 +1,15 @@
+-- The repository writes the plain text of user and assistant messages, the
+-- reply text without its JSON envelope for structured answers
+ALTER TABLE messages ADD COLUMN search_text TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN search_vector TSVECTOR
+    GENERATED ALWAYS AS (to_tsvector('english'::regconfig, search_text)) STORED;
+ALTER TABLE conversations ADD COLUMN search_vector TSVECTOR
+    GENERATED ALWAYS AS (to_tsvector('english'::regconfig, title || ' ' || summary)) STORED;
+
+CREATE INDEX idx_messages_search ON messages USING GIN (search_vector);
+CREATE INDEX idx_conversations_search ON conversations USING GIN (search_vector);
+
+-- Existing messages are indexed as stored. On startup the app reindexes
+-- assistant replies by their reply text, which needs their JSON envelope
+-- parsed.
+UPDATE messages SET search_text = content WHERE role IN ('user', 'assistant');
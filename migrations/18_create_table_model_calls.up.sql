@@ -0,0 +1,16 @@
+-- Model calls made for a conversation outside its turns, such as titles and
+-- history summaries
+CREATE TABLE model_calls (
+    id SERIAL PRIMARY KEY,
+    conversation_id INT NOT NULL,
+    purpose VARCHAR(32) NOT NULL,
+    provider VARCHAR(64) NOT NULL DEFAULT '',
+    model VARCHAR(255) NOT NULL DEFAULT '',
+    prompt_tokens INT NOT NULL DEFAULT 0,
+    completion_tokens INT NOT NULL DEFAULT 0,
+    latency_ms INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_model_calls_conversation_id ON model_calls (conversation_id, created_at);
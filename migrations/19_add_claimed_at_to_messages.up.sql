@@ -0,0 +1,4 @@
+-- When a request last took on the turn of a user message. Status updates
+-- compare it, so a request that lost its turn to a retry cannot change it.
+ALTER TABLE messages ADD COLUMN claimed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+UPDATE messages SET claimed_at = created_at;
@@ -0,0 +1 @@
+ALTER TABLE conversations ADD COLUMN version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,14 @@
+ALTER TABLE conversations ADD COLUMN chat_history JSONB NOT NULL DEFAULT '[]'::JSONB;
+
+UPDATE conversations c
+SET chat_history = m.history
+FROM (
+    SELECT conversation_id, jsonb_agg(jsonb_build_object('role', role, 'content', content) ORDER BY id) AS history
+    FROM messages
+    GROUP BY conversation_id
+) m
+WHERE m.conversation_id = c.id;
+
+ALTER TABLE conversations ALTER COLUMN chat_history DROP DEFAULT;
+
+DROP TABLE IF EXISTS messages;
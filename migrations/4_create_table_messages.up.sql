@@ -0,0 +1,23 @@
+CREATE TABLE messages (
+    id SERIAL PRIMARY KEY,
+    conversation_id INT NOT NULL,
+    role VARCHAR(32) NOT NULL,
+    content TEXT NOT NULL,
+    model VARCHAR(255) NOT NULL DEFAULT '',
+    prompt_tokens INT NOT NULL DEFAULT 0,
+    completion_tokens INT NOT NULL DEFAULT 0,
+    latency_ms INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_messages_conversation_id ON messages (conversation_id, id);
+
+-- Backfill the JSONB histories in their original order
+INSERT INTO messages (conversation_id, role, content, created_at)
+SELECT c.id, COALESCE(m.value->>'role', 'user'), COALESCE(m.value->>'content', ''), c.created_at
+FROM conversations c
+CROSS JOIN LATERAL jsonb_array_elements(c.chat_history) WITH ORDINALITY AS m(value, position)
+ORDER BY c.id, m.position;
+
+ALTER TABLE conversations DROP COLUMN chat_history;
@@ -0,0 +1,3 @@
+ALTER TABLE messages DROP COLUMN IF EXISTS locations;
+DROP TABLE IF EXISTS gazetteer_names;
+DROP TABLE IF EXISTS gazetteer;
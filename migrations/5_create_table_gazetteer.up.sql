@@ -0,0 +1,25 @@
+CREATE TABLE gazetteer (
+    geoname_id BIGINT PRIMARY KEY,
+    name VARCHAR(200) NOT NULL,
+    ascii_name VARCHAR(200) NOT NULL DEFAULT '',
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    feature_class VARCHAR(1) NOT NULL DEFAULT '',
+    feature_code VARCHAR(10) NOT NULL DEFAULT '',
+    country_code VARCHAR(2) NOT NULL DEFAULT '',
+    country VARCHAR(200) NOT NULL DEFAULT '',
+    admin1_code VARCHAR(20) NOT NULL DEFAULT '',
+    admin1 VARCHAR(200) NOT NULL DEFAULT '',
+    population BIGINT NOT NULL DEFAULT 0
+);
+
+-- Normalized lookup names, including GeoNames alternate names
+CREATE TABLE gazetteer_names (
+    geoname_id BIGINT NOT NULL,
+    name VARCHAR(400) NOT NULL,
+    FOREIGN KEY (geoname_id) REFERENCES gazetteer (geoname_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_gazetteer_names_name ON gazetteer_names (name);
+
+ALTER TABLE messages ADD COLUMN locations JSONB NOT NULL DEFAULT '[]'::JSONB;
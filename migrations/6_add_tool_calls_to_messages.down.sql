@@ -0,0 +1,2 @@
+ALTER TABLE messages DROP COLUMN IF EXISTS tool_call_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS tool_calls;
@@ -0,0 +1,2 @@
+ALTER TABLE messages ADD COLUMN tool_calls JSONB NOT NULL DEFAULT '[]'::JSONB;
+ALTER TABLE messages ADD COLUMN tool_call_id VARCHAR(255) NOT NULL DEFAULT '';
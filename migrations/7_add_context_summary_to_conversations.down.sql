@@ -0,0 +1,2 @@
+ALTER TABLE conversations DROP COLUMN IF EXISTS context_summary_message_id;
+ALTER TABLE conversations DROP COLUMN IF EXISTS context_summary;
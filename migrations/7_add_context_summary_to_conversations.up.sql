@@ -0,0 +1,2 @@
+ALTER TABLE conversations ADD COLUMN context_summary TEXT NOT NULL DEFAULT '';
+ALTER TABLE conversations ADD COLUMN context_summary_message_id INT NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE conversations ADD COLUMN settings JSONB NOT NULL DEFAULT '{}'::JSONB;
@@ -0,0 +1 @@
+ALTER TABLE messages ADD COLUMN provider VARCHAR(50) NOT NULL DEFAULT '';
@@ -0,0 +1,9 @@
+package model
+
+// GeoAnswer is the structured form of an assistant reply
+type GeoAnswer struct {
+	// Locations are the key places mentioned in the reply
+	Locations []string `json:"locations"`
+	// Message is the detailed response to the user's query
+	Message string `json:"message"`
+}
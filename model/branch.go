@@ -0,0 +1,75 @@
+package model
+
+// BranchRequest selects a message of a conversation, see the branch and
+// fork endpoints
+type BranchRequest struct {
+	MessageID uint `json:"message_id" binding:"required" example:"42"`
+}
+
+// EditMessageRequest replaces an earlier user message on a new branch
+type EditMessageRequest struct {
+	// Content is the new user input
+	Content string `json:"content" binding:"required"`
+	// Optional model and sampling parameters for this turn only
+	ChatSettings
+}
+
+// FindMessage returns the message with the given ID from any branch
+func (c *Conversation) FindMessage(messageID uint) (Message, bool) {
+	for _, message := range c.Messages {
+		if message.ID == messageID {
+			return message, true
+		}
+	}
+	return Message{}, false
+}
+
+// Branch returns the messages from the system prompt to messageID with
+// their sibling IDs filled in, or nil when the message is unknown
+func (c *Conversation) Branch(messageID uint) []Message {
+	index := make(map[uint]int, len(c.Messages))
+	children := make(map[uint][]uint)
+	for i, message := range c.Messages {
+		index[message.ID] = i
+		children[message.ParentID] = append(children[message.ParentID], message.ID)
+	}
+
+	var branch []Message
+	for id := messageID; id != 0 && len(branch) < len(c.Messages); {
+		i, ok := index[id]
+		if !ok {
+			return nil
+		}
+		message := c.Messages[i]
+		if siblings := children[message.ParentID]; len(siblings) > 1 {
+			message.SiblingIDs = siblings
+		}
+		branch = append(branch, message)
+		id = message.ParentID
+	}
+
+	// Walked from the leaf, so reverse into conversation order
+	for i, j := 0, len(branch)-1; i < j; i, j = i+1, j-1 {
+		branch[i], branch[j] = branch[j], branch[i]
+	}
+	return branch
+}
+
+// LatestLeaf follows the newest reply from messageID down to the last
+// message of its branch
+func (c *Conversation) LatestLeaf(messageID uint) uint {
+	newest := make(map[uint]uint)
+	for _, message := range c.Messages {
+		if message.ID > newest[message.ParentID] {
+			newest[message.ParentID] = message.ID
+		}
+	}
+
+	for {
+		child, ok := newest[messageID]
+		if !ok {
+			return messageID
+		}
+		messageID = child
+	}
+}
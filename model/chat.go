@@ -4,4 +4,21 @@ package model
 type ChatRequest struct {
 	// Content is the user's input to the chat
 	Content string `json:"content" binding:"required"`
+	// Persona selects the system prompt when a conversation starts, see
+	// GET /prompts. It is ignored for existing conversations.
+	Persona string `json:"persona,omitempty" example:"geo-planner"`
+	// Optional model and sampling parameters. On the first turn they are
+	// saved as the conversation settings, later turns override them for
+	// that turn only.
+	ChatSettings
+}
+
+// ChatResponse represents the response body of the /chat endpoint
+type ChatResponse struct {
+	// ConversationID is the UUID to continue the conversation with
+	ConversationID string `json:"conversation_id"`
+	// Response is the assistant's reply
+	Response Message `json:"response"`
+	// Answer is the structured form of the reply, omitted when it could not be parsed
+	Answer *GeoAnswer `json:"answer,omitempty"`
 }
@@ -3,10 +3,77 @@ package model
 import "time"
 
 type Conversation struct {
-	ID             uint                `json:"id"`
-	UserID         uint                `json:"user_id"`
-	ConversationID string              `json:"conversation_id"`
-	ChatHistory    []map[string]string `json:"chat_history"`
-	CreatedAt      time.Time           `json:"created_at"`
-	UpdatedAt      time.Time           `json:"updated_at"`
+	ID             uint   `json:"id"`
+	UserID         uint   `json:"user_id"`
+	ConversationID string `json:"conversation_id"`
+	Title          string `json:"title"`
+	Summary        string `json:"summary,omitempty"`
+	// Archived conversations are only listed on request
+	Pinned   bool     `json:"pinned"`
+	Archived bool     `json:"archived"`
+	Tags     []string `json:"tags"`
+	// ChatHistory is the active branch from the system prompt to
+	// ActiveMessageID. It is not loaded for conversation lists.
+	ChatHistory []Message `json:"chat_history,omitempty"`
+	// ActiveMessageID is the last message of the active branch
+	ActiveMessageID uint `json:"active_message_id,omitempty"`
+	// Messages holds every message of every branch. It is only loaded to
+	// switch branches.
+	Messages []Message `json:"-"`
+	Version  int       `json:"version"`
+	// Settings are the model and sampling parameters used for every turn
+	Settings ChatSettings `json:"settings"`
+	// PromptID is the prompt version the conversation started with, 0 for
+	// the built-in prompt
+	PromptID      uint   `json:"-"`
+	Persona       string `json:"persona,omitempty"`
+	PromptVersion int    `json:"prompt_version,omitempty"`
+	// ContextSummary condenses the messages up to ContextSummaryMessageID
+	// for the summary history policy
+	ContextSummary          string    `json:"-"`
+	ContextSummaryMessageID uint      `json:"-"`
+	CreatedAt               time.Time `json:"created_at"`
+	UpdatedAt               time.Time `json:"updated_at"`
+}
+
+// UpdateConversationRequest changes the details of a conversation, omitted
+// fields are left unchanged
+type UpdateConversationRequest struct {
+	Title    *string `json:"title" binding:"omitempty,max=200"`
+	Summary  *string `json:"summary"`
+	Pinned   *bool   `json:"pinned"`
+	Archived *bool   `json:"archived"`
+	// Tags replace the existing tags
+	Tags *[]string `json:"tags" binding:"omitempty,max=20,dive,min=1,max=50"`
+	// Settings set here replace the conversation defaults, e.g.
+	// {"cache": false} keeps later turns out of the response cache
+	Settings *ChatSettings `json:"settings"`
+}
+
+// ConversationListItem is the projection of a conversation in lists
+type ConversationListItem struct {
+	ID             uint      `json:"id"`
+	ConversationID string    `json:"conversation_id"`
+	Title          string    `json:"title"`
+	Summary        string    `json:"summary,omitempty"`
+	Persona        string    `json:"persona,omitempty"`
+	Pinned         bool      `json:"pinned"`
+	Archived       bool      `json:"archived"`
+	Tags           []string  `json:"tags"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// ConversationList is a page of conversations
+type ConversationList struct {
+	Conversations []ConversationListItem `json:"conversations"`
+	// NextCursor fetches the following page, omitted on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// MessagePage is a page of the active branch of a conversation
+type MessagePage struct {
+	Messages []Message `json:"messages"`
+	// NextCursor fetches the following page, omitted on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
 }
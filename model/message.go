@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+// Statuses of a user message's turn
+const (
+	MessagePending  = "pending"
+	MessageAnswered = "answered"
+	MessageFailed   = "failed"
+)
+
+// Message represents a single message stored in a conversation
+type Message struct {
+	ID             uint `json:"id"`
+	ConversationID uint `json:"-"`
+	// ParentID is the message this one follows, 0 for the system prompt
+	ParentID uint `json:"parent_id,omitempty"`
+	// SiblingIDs lists the alternatives sharing the parent, including this
+	// message, when there is more than one
+	SiblingIDs []uint `json:"sibling_ids,omitempty"`
+	// ForkedFromID is the original of a message copied by a fork
+	ForkedFromID     uint       `json:"forked_from_id,omitempty"`
+	Role             string     `json:"role"`
+	Content          string     `json:"content"`
+	Model            string     `json:"model,omitempty"`
+	Provider         string     `json:"provider,omitempty"`
+	PromptTokens     int        `json:"prompt_tokens,omitempty"`
+	CompletionTokens int        `json:"completion_tokens,omitempty"`
+	LatencyMS        int        `json:"latency_ms,omitempty"`
+	Locations        []Location `json:"locations,omitempty"`
+	// ToolCalls are the tools an assistant message asked to run
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID links a tool result message to its call
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	// Status tracks whether a user message got its reply, Error holds the
+	// failure detail of failed turns
+	Status    string    `json:"status" example:"answered"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// ClaimedAt is when a request last took on the turn of a user message
+	ClaimedAt time.Time `json:"-"`
+}
+
+// ToolCall is a tool invocation requested by the model
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
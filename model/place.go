@@ -0,0 +1,28 @@
+package model
+
+// Place is a gazetteer entry
+type Place struct {
+	GeonameID    int64   `json:"geoname_id"`
+	Name         string  `json:"name"`
+	ASCIIName    string  `json:"ascii_name,omitempty"`
+	Latitude     float64 `json:"latitude"`
+	Longitude    float64 `json:"longitude"`
+	CountryCode  string  `json:"country_code"`
+	Country      string  `json:"country,omitempty"`
+	Admin1Code   string  `json:"admin1_code,omitempty"`
+	Admin1       string  `json:"admin1,omitempty"`
+	FeatureClass string  `json:"feature_class"`
+	FeatureCode  string  `json:"feature_code"`
+	Population   int64   `json:"population"`
+}
+
+// Location is a place name mentioned in an answer
+type Location struct {
+	// Name is the location as written by the model
+	Name string `json:"name"`
+	// Place is the gazetteer match, nil when the name could not be resolved
+	Place *Place `json:"place,omitempty"`
+	// Confidence is 1 for a match on the place's main name, lower for
+	// alternate names and 0 when unresolved
+	Confidence float64 `json:"confidence"`
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// Prompt is a version of a named persona's system prompt
+type Prompt struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name" example:"geo-planner"`
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+	// Content is the persona's instructions, the answer format is added by the server
+	Content string `json:"content"`
+	// Active marks the version used for new conversations
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreatePromptRequest creates a persona or a new version of it
+type CreatePromptRequest struct {
+	Name        string `json:"name" binding:"required" example:"geo-planner"`
+	Description string `json:"description"`
+	Content     string `json:"content" binding:"required"`
+}
+
+// UpdatePromptRequest adds a new version of a persona
+type UpdatePromptRequest struct {
+	Description string `json:"description"`
+	Content     string `json:"content" binding:"required"`
+}
+
+// RollbackPromptRequest selects the version to make active again
+type RollbackPromptRequest struct {
+	Version int `json:"version" binding:"required"`
+}
@@ -0,0 +1,10 @@
+package model
+
+// UserQuota overrides the default rate limits and token quotas of a user.
+// Nil fields keep the defaults, 0 removes the limit.
+type UserQuota struct {
+	UserID            uint `json:"user_id"`
+	RequestsPerMinute *int `json:"requests_per_minute"`
+	TokensPerDay      *int `json:"tokens_per_day"`
+	TokensPerMonth    *int `json:"tokens_per_month"`
+}
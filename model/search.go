@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// SearchResult is a conversation matching a search, with its best matching
+// messages
+type SearchResult struct {
+	ConversationID string `json:"conversation_id"`
+	Title          string `json:"title"`
+	// TitleHighlight marks the matched words of the title and summary,
+	// empty when only messages matched
+	TitleHighlight string        `json:"title_highlight,omitempty"`
+	Rank           float64       `json:"rank"`
+	Matches        []SearchMatch `json:"matches"`
+}
+
+// SearchMatch is a matching message. MessageID anchors it in the
+// conversation and Snippet, escaped for HTML, marks the matched words with
+// <mark> tags.
+type SearchMatch struct {
+	MessageID uint      `json:"message_id"`
+	Role      string    `json:"role"`
+	Snippet   string    `json:"snippet"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SearchResponse is the response body of GET /search
+type SearchResponse struct {
+	Query   string         `json:"query"`
+	Results []SearchResult `json:"results"`
+}
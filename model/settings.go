@@ -0,0 +1,51 @@
+package model
+
+// ChatSettings are the model and sampling parameters of a chat turn. Unset
+// fields fall back to the conversation settings, then to the provider
+// defaults.
+type ChatSettings struct {
+	// Model must be one of the models listed by GET /models
+	Model       string   `json:"model,omitempty" example:"llama-3.3-70b-versatile"`
+	Temperature *float64 `json:"temperature,omitempty" example:"0.7"`
+	MaxTokens   *int     `json:"max_tokens,omitempty" example:"1024"`
+	TopP        *float64 `json:"top_p,omitempty" example:"1"`
+	Seed        *int     `json:"seed,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	// Cache set to false keeps context-dependent turns out of the response cache
+	Cache *bool `json:"cache,omitempty"`
+}
+
+// Merge returns the settings with the fields set in override replaced
+func (s ChatSettings) Merge(override ChatSettings) ChatSettings {
+	if override.Model != "" {
+		s.Model = override.Model
+	}
+	if override.Temperature != nil {
+		s.Temperature = override.Temperature
+	}
+	if override.MaxTokens != nil {
+		s.MaxTokens = override.MaxTokens
+	}
+	if override.TopP != nil {
+		s.TopP = override.TopP
+	}
+	if override.Seed != nil {
+		s.Seed = override.Seed
+	}
+	if override.Stop != nil {
+		s.Stop = override.Stop
+	}
+	if override.Cache != nil {
+		s.Cache = override.Cache
+	}
+	return s
+}
+
+// ModelInfo describes a model clients may select
+type ModelInfo struct {
+	Name string `json:"name"`
+	// ContextWindow and MaxOutputTokens are in tokens, 0 when unknown
+	ContextWindow   int  `json:"context_window"`
+	MaxOutputTokens int  `json:"max_output_tokens"`
+	Default         bool `json:"default"`
+}
@@ -0,0 +1,58 @@
+package model
+
+import "time"
+
+// Price is the cost of a model in USD per million tokens
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// UsageRow is the token usage of one model within a group, as aggregated
+// by the repository
+type UsageRow struct {
+	Group            string
+	Model            string
+	Calls            int
+	PromptTokens     int
+	CompletionTokens int
+	LatencyMS        int
+}
+
+// UsageGroup is the usage of a day or a model
+type UsageGroup struct {
+	// Key is the day as YYYY-MM-DD or the model name
+	Key              string  `json:"key"`
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	AvgLatencyMS     int     `json:"avg_latency_ms"`
+	EstimatedCost    float64 `json:"estimated_cost"`
+}
+
+// UsageReport is the LLM usage of a user over a time range
+type UsageReport struct {
+	UserID  uint      `json:"user_id"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	GroupBy string    `json:"group_by" example:"day"`
+	// Currency of the estimated costs
+	Currency string       `json:"currency" example:"USD"`
+	Groups   []UsageGroup `json:"groups"`
+	Total    UsageGroup   `json:"total"`
+	// UnpricedModels have no price configured and count as free
+	UnpricedModels []string `json:"unpriced_models,omitempty"`
+}
+
+// ModelCall is a model call made for a conversation outside its turns, such
+// as generating its title or summarizing its history
+type ModelCall struct {
+	ConversationID   uint
+	Purpose          string
+	Provider         string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	LatencyMS        int
+}
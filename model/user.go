@@ -12,6 +12,14 @@ type User struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// UserList is a page of users
+type UserList struct {
+	Status string `json:"status" example:"success"`
+	Data   []User `json:"data"`
+	// NextCursor fetches the following page, omitted on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
 type CreateUserRequest struct {
 	Username string `json:"username" binding:"required"`
 	Email    string `json:"email" binding:"required"`
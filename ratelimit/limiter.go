@@ -0,0 +1,191 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Quota holds the limits of a user, 0 disables a limit
+type Quota struct {
+	RequestsPerMinute int
+	TokensPerDay      int
+	TokensPerMonth    int
+}
+
+// Config holds the default limits
+type Config struct {
+	// User is the default quota of every user, overridable per user in the store
+	User Quota
+	// IPRequestsPerMinute limits the requests per client IP, 0 disables it
+	IPRequestsPerMinute int
+}
+
+// Bucket is the state of a token bucket after a request took from it
+type Bucket struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is the wait until a token is available when not allowed
+	RetryAfter time.Duration
+	// Reset is the wait until the bucket is full again
+	Reset time.Duration
+}
+
+// Store keeps request buckets, token usage and quota overrides. MemoryStore
+// suits single-instance deployments, PostgresStore shares the limits
+// between instances.
+type Store interface {
+	// Take removes one request from the bucket under key, which holds up
+	// to perMinute requests and refills continuously
+	Take(ctx context.Context, key string, perMinute int, now time.Time) (Bucket, error)
+	// TokensUsed returns the LLM tokens a user spent in each period
+	TokensUsed(ctx context.Context, userID uint, periods []string) ([]int, error)
+	// AddTokens adds LLM tokens to each period of a user
+	AddTokens(ctx context.Context, userID uint, periods []string, tokens int) error
+	// Quota returns the defaults with the user's overrides applied
+	Quota(ctx context.Context, userID uint, defaults Quota) (Quota, error)
+}
+
+// Window is a limit, what is left of it and when it resets
+type Window struct {
+	Limit     int
+	Remaining int
+	Reset     time.Duration
+}
+
+// Decision is the outcome of a rate limit check
+type Decision struct {
+	Allowed    bool
+	Reason     string
+	RetryAfter time.Duration
+	// Requests and Tokens are the tightest limits that applied, nil when none did
+	Requests *Window
+	Tokens   *Window
+}
+
+// Limiter enforces per-IP and per-user request rates and per-user LLM token
+// quotas per calendar day and month in UTC. Token quotas are checked before
+// a request, so its own tokens may overshoot the quota once.
+type Limiter struct {
+	config Config
+	store  Store
+}
+
+// NewLimiter creates a new instance of Limiter
+func NewLimiter(config Config, store Store) *Limiter {
+	return &Limiter{config: config, store: store}
+}
+
+// Allow checks a request of a user from an IP address. A user ID of 0 only
+// applies the IP limit.
+func (l *Limiter) Allow(ctx context.Context, userID uint, ip string, now time.Time) (*Decision, error) {
+	now = now.UTC()
+	decision := &Decision{Allowed: true}
+
+	if l.config.IPRequestsPerMinute > 0 {
+		bucket, err := l.store.Take(ctx, "ip:"+ip, l.config.IPRequestsPerMinute, now)
+		if err != nil {
+			return nil, err
+		}
+		decision.Requests = tighter(decision.Requests, &Window{l.config.IPRequestsPerMinute, bucket.Remaining, bucket.Reset})
+		if !bucket.Allowed {
+			decision.deny("Too many requests from this IP address", bucket.RetryAfter)
+			return decision, nil
+		}
+	}
+
+	if userID == 0 {
+		return decision, nil
+	}
+
+	quota, err := l.store.Quota(ctx, userID, l.config.User)
+	if err != nil {
+		return nil, err
+	}
+
+	if quota.RequestsPerMinute > 0 {
+		bucket, err := l.store.Take(ctx, "user:"+strconv.Itoa(int(userID)), quota.RequestsPerMinute, now)
+		if err != nil {
+			return nil, err
+		}
+		decision.Requests = tighter(decision.Requests, &Window{quota.RequestsPerMinute, bucket.Remaining, bucket.Reset})
+		if !bucket.Allowed {
+			decision.deny("Too many requests, please slow down", bucket.RetryAfter)
+			return decision, nil
+		}
+	}
+
+	if quota.TokensPerDay <= 0 && quota.TokensPerMonth <= 0 {
+		return decision, nil
+	}
+
+	day, month := periods(now)
+	used, err := l.store.TokensUsed(ctx, userID, []string{day, month})
+	if err != nil {
+		return nil, err
+	}
+	nextDay := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	nextMonth := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+
+	if quota.TokensPerDay > 0 {
+		decision.Tokens = tighter(decision.Tokens, &Window{quota.TokensPerDay, max(quota.TokensPerDay-used[0], 0), nextDay.Sub(now)})
+	}
+	if quota.TokensPerMonth > 0 {
+		decision.Tokens = tighter(decision.Tokens, &Window{quota.TokensPerMonth, max(quota.TokensPerMonth-used[1], 0), nextMonth.Sub(now)})
+	}
+	if decision.Tokens.Remaining == 0 {
+		decision.deny("Token quota exceeded", decision.Tokens.Reset)
+	}
+	return decision, nil
+}
+
+// Record adds the LLM tokens spent by a user to the current day and month
+func (l *Limiter) Record(ctx context.Context, userID uint, tokens int, now time.Time) error {
+	day, month := periods(now.UTC())
+	return l.store.AddTokens(ctx, userID, []string{day, month}, tokens)
+}
+
+func (d *Decision) deny(reason string, retryAfter time.Duration) {
+	d.Allowed = false
+	d.Reason = reason
+	d.RetryAfter = retryAfter
+}
+
+// periods returns the token usage keys of the day and month of now
+func periods(now time.Time) (string, string) {
+	return "day:" + now.Format("2006-01-02"), "month:" + now.Format("2006-01")
+}
+
+// tighter returns the window with the fewest remaining units
+func tighter(current, candidate *Window) *Window {
+	if current == nil || candidate.Remaining < current.Remaining {
+		return candidate
+	}
+	return current
+}
+
+// refill returns the tokens of a bucket holding capacity tokens that
+// refills at capacity per minute, after elapsed time
+func refill(tokens float64, elapsed time.Duration, capacity int) float64 {
+	if elapsed > 0 {
+		tokens += elapsed.Minutes() * float64(capacity)
+	}
+	if tokens > float64(capacity) {
+		tokens = float64(capacity)
+	}
+	return tokens
+}
+
+// newBucket describes a bucket holding tokens after a take
+func newBucket(tokens float64, allowed bool, capacity int) Bucket {
+	perToken := time.Minute / time.Duration(capacity)
+	bucket := Bucket{
+		Allowed:   allowed,
+		Remaining: int(tokens),
+		Reset:     time.Duration((float64(capacity) - tokens) * float64(perToken)),
+	}
+	if !allowed {
+		bucket.RetryAfter = time.Duration((1 - tokens) * float64(perToken))
+	}
+	return bucket
+}
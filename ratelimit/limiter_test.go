@@ -0,0 +1,170 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"geoai-app/model"
+)
+
+func TestRefill(t *testing.T) {
+	tests := []struct {
+		tokens   float64
+		elapsed  time.Duration
+		capacity int
+		want     float64
+	}{
+		{0, 0, 60, 0},
+		{0, time.Second, 60, 1},
+		{0, 30 * time.Second, 60, 30},
+		{10, time.Hour, 60, 60},
+		{5, -time.Second, 60, 5},
+		{0, 20 * time.Second, 6, 2},
+	}
+	for _, tt := range tests {
+		if got := refill(tt.tokens, tt.elapsed, tt.capacity); got != tt.want {
+			t.Errorf("refill(%v, %v, %d) = %v, want %v", tt.tokens, tt.elapsed, tt.capacity, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		after       time.Duration
+		wantAllowed bool
+		wantLeft    int
+		wantRetry   time.Duration
+	}{
+		{0, true, 1, 0},
+		{0, true, 0, 0},
+		{0, false, 0, 30 * time.Second},
+		{10 * time.Second, false, 0, 20 * time.Second},
+		{20 * time.Second, true, 0, 0},
+		{time.Hour, true, 1, 0},
+	}
+	for i, step := range steps {
+		now = now.Add(step.after)
+		bucket, err := store.Take(ctx, "user:1", 2, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bucket.Allowed != step.wantAllowed || bucket.Remaining != step.wantLeft || bucket.RetryAfter != step.wantRetry {
+			t.Errorf("step %d: got %+v, want allowed %v, %d left, retry after %v", i, bucket, step.wantAllowed, step.wantLeft, step.wantRetry)
+		}
+	}
+}
+
+func TestLimiterRequests(t *testing.T) {
+	limiter := NewLimiter(Config{User: Quota{RequestsPerMinute: 2}, IPRequestsPerMinute: 3}, NewMemoryStore())
+	ctx := context.Background()
+	now := time.Now()
+
+	steps := []struct {
+		userID uint
+		ip     string
+		want   bool
+		reason string
+	}{
+		{1, "10.0.0.1", true, ""},
+		{1, "10.0.0.1", true, ""},
+		{1, "10.0.0.1", false, "Too many requests, please slow down"},
+		{2, "10.0.0.1", false, "Too many requests from this IP address"},
+		{2, "10.0.0.2", true, ""},
+		{0, "10.0.0.3", true, ""},
+	}
+	for i, step := range steps {
+		decision, err := limiter.Allow(ctx, step.userID, step.ip, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decision.Allowed != step.want || decision.Reason != step.reason {
+			t.Errorf("step %d: allowed %v (%q), want %v (%q)", i, decision.Allowed, decision.Reason, step.want, step.reason)
+		}
+		if !decision.Allowed && decision.RetryAfter <= 0 {
+			t.Errorf("step %d: denied without Retry-After", i)
+		}
+	}
+}
+
+func TestLimiterTokenQuotaRollover(t *testing.T) {
+	limiter := NewLimiter(Config{User: Quota{TokensPerDay: 100, TokensPerMonth: 150}}, NewMemoryStore())
+	ctx := context.Background()
+	allowed := func(now time.Time) *Decision {
+		t.Helper()
+		decision, err := limiter.Allow(ctx, 1, "10.0.0.1", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decision
+	}
+
+	day1 := time.Date(2024, 5, 31, 22, 0, 0, 0, time.UTC)
+	limiter.Record(ctx, 1, 100, day1)
+	decision := allowed(day1)
+	if decision.Allowed || decision.RetryAfter != 2*time.Hour {
+		t.Fatalf("day quota: %+v, want denied until midnight", decision)
+	}
+
+	// June starts a new day and month
+	day2 := day1.Add(3 * time.Hour)
+	if decision := allowed(day2); !decision.Allowed || decision.Tokens.Remaining != 100 {
+		t.Fatalf("next day: %+v, want allowed with the full day quota", decision)
+	}
+
+	limiter.Record(ctx, 1, 100, day2)
+	day3 := day2.Add(24 * time.Hour)
+	limiter.Record(ctx, 1, 50, day3)
+	decision = allowed(day3)
+	if decision.Allowed || decision.Reason != "Token quota exceeded" || decision.Tokens.Limit != 150 {
+		t.Fatalf("month quota: %+v, want denied by the month quota", decision)
+	}
+	if want := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC).Sub(day3); decision.RetryAfter != want {
+		t.Errorf("retry after %v, want %v until the next month", decision.RetryAfter, want)
+	}
+}
+
+func TestMemoryStoreOverrides(t *testing.T) {
+	store := NewMemoryStore()
+	defaults := Quota{RequestsPerMinute: 20, TokensPerDay: 1000, TokensPerMonth: 10000}
+	requests, unlimited := 60, 0
+	store.SetOverride(model.UserQuota{UserID: 1, RequestsPerMinute: &requests, TokensPerDay: &unlimited})
+
+	tests := []struct {
+		userID uint
+		want   Quota
+	}{
+		{1, Quota{RequestsPerMinute: 60, TokensPerDay: 0, TokensPerMonth: 10000}},
+		{2, defaults},
+	}
+	for _, tt := range tests {
+		got, err := store.Quota(context.Background(), tt.userID, defaults)
+		if err != nil || got != tt.want {
+			t.Errorf("user %d: quota %+v, %v, want %+v", tt.userID, got, err, tt.want)
+		}
+	}
+}
+
+func TestMemoryStorePrune(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	start := time.Date(2024, 5, 31, 23, 55, 0, 0, time.UTC)
+
+	store.Take(ctx, "ip:idle", 10, start)
+	store.AddTokens(ctx, 1, []string{"day:2024-05-31", "month:2024-05"}, 10)
+	store.Take(ctx, "ip:busy", 1, start.Add(pruneInterval))
+
+	if _, ok := store.buckets["ip:idle"]; ok {
+		t.Error("full bucket kept")
+	}
+	if _, ok := store.buckets["ip:busy"]; !ok {
+		t.Error("empty bucket pruned")
+	}
+	if len(store.usage) != 0 {
+		t.Errorf("usage of past periods kept: %v", store.usage)
+	}
+}
@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"geoai-app/model"
+)
+
+// pruneInterval is how often idle buckets and past periods are dropped
+const pruneInterval = 10 * time.Minute
+
+type memoryBucket struct {
+	tokens    float64
+	capacity  int
+	updatedAt time.Time
+}
+
+// MemoryStore keeps the limits in process memory. It suits single-instance
+// deployments; limits reset when the process restarts.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	usage     map[uint]map[string]int
+	overrides map[uint]model.UserQuota
+	lastPrune time.Time
+}
+
+// NewMemoryStore creates a new instance of MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   make(map[string]*memoryBucket),
+		usage:     make(map[uint]map[string]int),
+		overrides: make(map[uint]model.UserQuota),
+	}
+}
+
+// SetOverride applies the set fields of a user_quotas row to the defaults
+// of the user
+func (s *MemoryStore) SetOverride(override model.UserQuota) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides[override.UserID] = override
+}
+
+// Take removes one request from the bucket under key
+func (s *MemoryStore) Take(_ context.Context, key string, perMinute int, now time.Time) (Bucket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(now)
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: float64(perMinute), updatedAt: now}
+		s.buckets[key] = bucket
+	}
+	bucket.capacity = perMinute
+	bucket.tokens = refill(bucket.tokens, now.Sub(bucket.updatedAt), perMinute)
+	if now.After(bucket.updatedAt) {
+		bucket.updatedAt = now
+	}
+
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	return newBucket(bucket.tokens, allowed, perMinute), nil
+}
+
+// TokensUsed returns the LLM tokens a user spent in each period
+func (s *MemoryStore) TokensUsed(_ context.Context, userID uint, periods []string) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	used := make([]int, len(periods))
+	for i, period := range periods {
+		used[i] = s.usage[userID][period]
+	}
+	return used, nil
+}
+
+// AddTokens adds LLM tokens to each period of a user
+func (s *MemoryStore) AddTokens(_ context.Context, userID uint, periods []string, tokens int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.usage[userID] == nil {
+		s.usage[userID] = make(map[string]int)
+	}
+	for _, period := range periods {
+		s.usage[userID][period] += tokens
+	}
+	return nil
+}
+
+// Quota returns the user's override or the defaults
+func (s *MemoryStore) Quota(_ context.Context, userID uint, defaults Quota) (Quota, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if override, ok := s.overrides[userID]; ok {
+		return applyOverride(defaults, override), nil
+	}
+	return defaults, nil
+}
+
+// prune drops full buckets and the usage of past periods
+func (s *MemoryStore) prune(now time.Time) {
+	if now.Sub(s.lastPrune) < pruneInterval {
+		return
+	}
+	s.lastPrune = now
+
+	for key, bucket := range s.buckets {
+		if refill(bucket.tokens, now.Sub(bucket.updatedAt), bucket.capacity) >= float64(bucket.capacity) {
+			delete(s.buckets, key)
+		}
+	}
+
+	day, month := periods(now.UTC())
+	for userID, usage := range s.usage {
+		for period := range usage {
+			if period != day && period != month {
+				delete(usage, period)
+			}
+		}
+		if len(usage) == 0 {
+			delete(s.usage, userID)
+		}
+	}
+}
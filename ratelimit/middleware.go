@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tokensKey is the gin context key handlers record spent LLM tokens under
+const tokensKey = "ratelimit.tokens"
+
+// RecordTokens notes LLM tokens spent by the current request so the
+// middleware can charge them to the user's quota. Background work started by
+// the request may record tokens on a c.Copy() after the response; those are
+// charged straight away.
+func RecordTokens(c *gin.Context, tokens int) {
+	if m, ok := c.Value(tokensKey).(*meter); ok {
+		m.add(tokens)
+	}
+}
+
+// meter sums the tokens of a request until it finishes, then charges later
+// tokens as they are recorded
+type meter struct {
+	mu     sync.Mutex
+	tokens int
+	done   bool
+	charge func(tokens int)
+}
+
+func (m *meter) add(tokens int) {
+	m.mu.Lock()
+	if !m.done {
+		m.tokens += tokens
+		m.mu.Unlock()
+		return
+	}
+	m.mu.Unlock()
+	if tokens > 0 {
+		m.charge(tokens)
+	}
+}
+
+// finish returns the tokens of the request, later tokens are charged by add
+func (m *meter) finish() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.done = true
+	return m.tokens
+}
+
+// Middleware enforces the limiter on the user in the user_id query
+// parameter and the client IP. It answers 429 when a limit is exhausted and
+// reports the tightest limits in X-RateLimit-* headers. Store failures let
+// the request through.
+func Middleware(limiter *Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := strconv.ParseUint(c.Query("user_id"), 10, 32)
+
+		decision, err := limiter.Allow(c.Request.Context(), uint(userID), c.ClientIP(), time.Now())
+		if err != nil {
+			fmt.Printf("Error checking rate limits: %v\n", err)
+			c.Next()
+			return
+		}
+
+		setHeaders(c.Writer.Header(), "Requests", decision.Requests)
+		setHeaders(c.Writer.Header(), "Tokens", decision.Tokens)
+		if !decision.Allowed {
+			c.Header("Retry-After", strconv.Itoa(seconds(decision.RetryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": decision.Reason})
+			return
+		}
+
+		m := &meter{charge: func(tokens int) {
+			if userID == 0 {
+				return
+			}
+			if err := limiter.Record(context.Background(), uint(userID), tokens, time.Now()); err != nil {
+				fmt.Printf("Error recording token usage: %v\n", err)
+			}
+		}}
+		c.Set(tokensKey, m)
+
+		c.Next()
+
+		if tokens := m.finish(); tokens > 0 {
+			m.charge(tokens)
+		}
+	}
+}
+
+func setHeaders(header http.Header, kind string, window *Window) {
+	if window == nil {
+		return
+	}
+	header.Set("X-RateLimit-Limit-"+kind, strconv.Itoa(window.Limit))
+	header.Set("X-RateLimit-Remaining-"+kind, strconv.Itoa(window.Remaining))
+	header.Set("X-RateLimit-Reset-"+kind, strconv.Itoa(seconds(window.Reset)))
+}
+
+// seconds rounds a duration up to whole seconds
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := NewMemoryStore()
+	limiter := NewLimiter(Config{User: Quota{RequestsPerMinute: 1, TokensPerDay: 1000}}, store)
+
+	router := gin.New()
+	router.POST("/chat", Middleware(limiter), func(c *gin.Context) {
+		RecordTokens(c, 300)
+		RecordTokens(c, 200)
+		c.Status(http.StatusOK)
+	})
+	post := func() *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/chat?user_id=1", nil))
+		return recorder
+	}
+
+	first := post()
+	if first.Code != http.StatusOK {
+		t.Fatalf("first request: %d, want 200", first.Code)
+	}
+	for header, want := range map[string]string{
+		"X-RateLimit-Limit-Requests":     "1",
+		"X-RateLimit-Remaining-Requests": "0",
+		"X-RateLimit-Reset-Requests":     "60",
+		"X-RateLimit-Limit-Tokens":       "1000",
+		"X-RateLimit-Remaining-Tokens":   "1000",
+	} {
+		if got := first.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	// The tokens recorded by the handler are charged once it returns
+	day, month := periods(time.Now().UTC())
+	used, _ := store.TokensUsed(context.Background(), 1, []string{day, month})
+	if used[0] != 500 || used[1] != 500 {
+		t.Errorf("charged %v tokens, want 500 for the day and month", used)
+	}
+
+	second := post()
+	if second.Code != http.StatusTooManyRequests || second.Header().Get("Retry-After") != "60" {
+		t.Fatalf("second request: %d with Retry-After %q, want 429 after 60s", second.Code, second.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"geoai-app/model"
+	"geoai-app/repository"
+)
+
+// PostgresStore keeps the limits in Postgres so they are shared between
+// instances. Quota overrides come from the user_quotas table.
+type PostgresStore struct {
+	Repo repository.RateLimitRepositoryInterface
+}
+
+// NewPostgresStore creates a new instance of PostgresStore
+func NewPostgresStore(repo repository.RateLimitRepositoryInterface) *PostgresStore {
+	return &PostgresStore{Repo: repo}
+}
+
+// Take removes one request from the bucket under key
+func (s *PostgresStore) Take(_ context.Context, key string, perMinute int, now time.Time) (Bucket, error) {
+	tokens, allowed, err := s.Repo.TakeToken(key, perMinute, float64(perMinute)/60, now)
+	if err != nil {
+		return Bucket{}, err
+	}
+	return newBucket(tokens, allowed, perMinute), nil
+}
+
+// TokensUsed returns the LLM tokens a user spent in each period
+func (s *PostgresStore) TokensUsed(_ context.Context, userID uint, periods []string) ([]int, error) {
+	return s.Repo.GetTokenUsage(userID, periods)
+}
+
+// AddTokens adds LLM tokens to each period of a user
+func (s *PostgresStore) AddTokens(_ context.Context, userID uint, periods []string, tokens int) error {
+	return s.Repo.AddTokenUsage(userID, periods, tokens)
+}
+
+// Quota returns the defaults with the user's overrides applied
+func (s *PostgresStore) Quota(_ context.Context, userID uint, defaults Quota) (Quota, error) {
+	override, err := s.Repo.GetUserQuota(userID)
+	if err != nil || override == nil {
+		return defaults, err
+	}
+	return applyOverride(defaults, *override), nil
+}
+
+// StartPruning deletes idle buckets and the token usage of past periods
+// every pruneInterval until ctx is done
+func (s *PostgresStore) StartPruning(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(pruneInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if _, err := s.Repo.PruneBuckets(now.Add(-time.Minute)); err != nil {
+					log.Printf("Failed to prune rate limit buckets: %v", err)
+				}
+				day, month := periods(now.UTC())
+				if _, err := s.Repo.PruneTokenUsage([]string{day, month}); err != nil {
+					log.Printf("Failed to prune token usage: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// applyOverride returns the defaults with the set fields of override
+func applyOverride(defaults Quota, override model.UserQuota) Quota {
+	quota := defaults
+	if override.RequestsPerMinute != nil {
+		quota.RequestsPerMinute = *override.RequestsPerMinute
+	}
+	if override.TokensPerDay != nil {
+		quota.TokensPerDay = *override.TokensPerDay
+	}
+	if override.TokensPerMonth != nil {
+		quota.TokensPerMonth = *override.TokensPerMonth
+	}
+	return quota
+}
@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"geoai-app/model"
+	"geoai-app/repository"
+)
+
+// fakeRateLimits serves the quota override of one user
+type fakeRateLimits struct {
+	repository.RateLimitRepositoryInterface
+	override *model.UserQuota
+}
+
+func (f *fakeRateLimits) GetUserQuota(userID uint) (*model.UserQuota, error) {
+	if f.override == nil || f.override.UserID != userID {
+		return nil, nil
+	}
+	return f.override, nil
+}
+
+func (f *fakeRateLimits) TakeToken(key string, capacity int, perSecond float64, now time.Time) (float64, bool, error) {
+	return float64(capacity) - 1, true, nil
+}
+
+func TestPostgresStoreQuota(t *testing.T) {
+	defaults := Quota{RequestsPerMinute: 20, TokensPerDay: 1000, TokensPerMonth: 10000}
+	perDay := 5000
+	store := NewPostgresStore(&fakeRateLimits{override: &model.UserQuota{UserID: 1, TokensPerDay: &perDay}})
+
+	tests := []struct {
+		userID uint
+		want   Quota
+	}{
+		{1, Quota{RequestsPerMinute: 20, TokensPerDay: 5000, TokensPerMonth: 10000}},
+		{2, defaults},
+	}
+	for _, tt := range tests {
+		got, err := store.Quota(context.Background(), tt.userID, defaults)
+		if err != nil || got != tt.want {
+			t.Errorf("user %d: quota %+v, %v, want %+v", tt.userID, got, err, tt.want)
+		}
+	}
+}
+
+func TestPostgresStoreTake(t *testing.T) {
+	store := NewPostgresStore(&fakeRateLimits{})
+	bucket, err := store.Take(context.Background(), "user:1", 60, time.Now())
+	if err != nil || !bucket.Allowed || bucket.Remaining != 59 || bucket.Reset != time.Second {
+		t.Fatalf("bucket %+v, %v, want allowed with 59 left and a second to refill", bucket, err)
+	}
+}
@@ -3,18 +3,41 @@ package repository
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
+	"geoai-app/geojson"
 	"geoai-app/model"
+	"github.com/lib/pq"
 )
 
 type ConversationRepositoryInterface interface {
-	GetConversationsByUserID(userID string) ([]model.Conversation, error)
+	GetConversationsByUserID(userID string, filter ConversationFilter, page Page) ([]model.ConversationListItem, string, error)
 	GetConversationByUUID(uuid string) (*model.Conversation, error)
 	CreateConversation(conversation *model.Conversation) error
-	UpdateConversation(conversation *model.Conversation) error
+	DeleteConversation(conversation *model.Conversation) error
+	RestoreConversation(userID uint, uuid string) (bool, error)
+	GetMessages(conversation *model.Conversation, page Page) (*model.MessagePage, error)
+	AppendMessages(conversation *model.Conversation, messages []model.Message) error
+	BranchMessages(conversation *model.Conversation, parentID uint, messages []model.Message) error
+	AnswerMessage(conversation *model.Conversation, question *model.Message, messages []model.Message) error
+	UpdateMessageStatus(conversation *model.Conversation, message *model.Message, status, detail string) error
+	ReopenMessage(conversation *model.Conversation, question *model.Message) error
+	SwitchBranch(conversation *model.Conversation, messageID uint) error
+	GetBranch(conversation *model.Conversation, messageID uint) ([]model.Message, error)
+	GetMessageTree(conversation *model.Conversation) error
+	GetConversationLocations(conversation *model.Conversation) (*geojson.FeatureCollection, error)
+	UpdateContextSummary(conversation *model.Conversation, summary string, upToMessageID uint) error
+	SetGeneratedTitle(conversationID uint, title, summary string) error
+	UpdateConversationDetails(conversation *model.Conversation) error
+	RecordModelCall(call model.ModelCall) error
 }
 
+// ErrVersionConflict is returned when a conversation was changed by another
+// request since it was loaded
+var ErrVersionConflict = errors.New("conversation was modified by another request")
+
 type ConversationRepository struct {
 	DB *sql.DB
 }
@@ -23,50 +46,124 @@ func NewConversationRepository(db *sql.DB) ConversationRepositoryInterface {
 	return &ConversationRepository{DB: db}
 }
 
-// GetConversationsByUserID retrieves all conversations for a user
-func (r *ConversationRepository) GetConversationsByUserID(userID string) ([]model.Conversation, error) {
-	rows, err := r.DB.Query(
-		"SELECT id, user_id, conversation_id, chat_history, created_at, updated_at FROM conversations WHERE user_id = $1 ORDER BY created_at ASC",
-		userID,
+// conversationColumns selects a conversation joined with its prompt as c and p
+const conversationColumns = `c.id, c.user_id, c.conversation_id, c.title, c.summary, c.pinned, c.archived, c.tags, COALESCE(c.active_message_id, 0), c.version, c.settings, c.context_summary,
+	c.context_summary_message_id, COALESCE(c.prompt_id, 0), COALESCE(p.name, ''), COALESCE(p.version, 0), c.created_at, c.updated_at`
+
+// ConversationFilter narrows a conversation list, nil fields do not filter
+type ConversationFilter struct {
+	Archived     bool
+	Pinned       *bool
+	Tag          string
+	HasLocations *bool
+}
+
+// conversationSorts are the sort keys of conversation lists
+var conversationSorts = map[string]string{
+	"created_at": "c.created_at",
+	"updated_at": "c.updated_at",
+}
+
+// messageSorts are the sort keys of message lists
+var messageSorts = map[string]string{
+	"created_at": "created_at",
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanConversation(row rowScanner) (*model.Conversation, error) {
+	var conversation model.Conversation
+	var settings []byte
+
+	err := row.Scan(
+		&conversation.ID, &conversation.UserID, &conversation.ConversationID, &conversation.Title, &conversation.Summary,
+		&conversation.Pinned, &conversation.Archived, pq.Array(&conversation.Tags), &conversation.ActiveMessageID, &conversation.Version, &settings, &conversation.ContextSummary, &conversation.ContextSummaryMessageID, &conversation.PromptID,
+		&conversation.Persona, &conversation.PromptVersion, &conversation.CreatedAt, &conversation.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var conversations []model.Conversation
-	for rows.Next() {
-		var conversation model.Conversation
-		var chatHistory string
+	if err := json.Unmarshal(settings, &conversation.Settings); err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
 
-		err := rows.Scan(&conversation.ID, &conversation.UserID, &conversation.ConversationID, &chatHistory, &conversation.CreatedAt, &conversation.UpdatedAt)
-		if err != nil {
-			return nil, err
+// GetConversationsByUserID retrieves a page of the conversations of a user
+// as list items, along with the cursor of the next page. Archived
+// conversations are only listed when the filter asks for them, deleted ones
+// never.
+func (r *ConversationRepository) GetConversationsByUserID(userID string, filter ConversationFilter, page Page) ([]model.ConversationListItem, string, error) {
+	conditions := []string{"c.user_id = $1", "c.archived = $2", "c.deleted_at IS NULL"}
+	args := []interface{}{userID, filter.Archived}
+	if filter.Pinned != nil {
+		args = append(args, *filter.Pinned)
+		conditions = append(conditions, fmt.Sprintf("c.pinned = $%d", len(args)))
+	}
+	if filter.Tag != "" {
+		args = append(args, filter.Tag)
+		conditions = append(conditions, fmt.Sprintf("$%d = ANY(c.tags)", len(args)))
+	}
+	if filter.HasLocations != nil {
+		exists := "EXISTS (SELECT 1 FROM messages m WHERE m.conversation_id = c.id AND m.locations <> '[]'::JSONB)"
+		if !*filter.HasLocations {
+			exists = "NOT " + exists
 		}
+		conditions = append(conditions, exists)
+	}
+
+	pageConditions, order, args, err := page.keyset(conversationSorts, "c.id", args)
+	if err != nil {
+		return nil, "", err
+	}
+	rows, err := r.DB.Query(
+		`SELECT c.id, c.conversation_id, c.title, c.summary, COALESCE(p.name, ''), c.pinned, c.archived, c.tags, c.created_at, c.updated_at
+		FROM conversations c LEFT JOIN prompts p ON p.id = c.prompt_id `+whereClause(append(conditions, pageConditions...))+" "+order,
+		args...,
+	)
+	if err != nil {
+		fmt.Printf("SQL Error while listing conversations: %v\n", err)
+		return nil, "", err
+	}
+	defer rows.Close()
 
-		// Unmarshal JSON chat history
-		err = json.Unmarshal([]byte(chatHistory), &conversation.ChatHistory)
+	conversations := []model.ConversationListItem{}
+	for rows.Next() {
+		var item model.ConversationListItem
+		err := rows.Scan(
+			&item.ID, &item.ConversationID, &item.Title, &item.Summary, &item.Persona,
+			&item.Pinned, &item.Archived, pq.Array(&item.Tags), &item.CreatedAt, &item.UpdatedAt,
+		)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
-
-		conversations = append(conversations, conversation)
+		conversations = append(conversations, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
 	}
 
-	return conversations, nil
+	conversations, next := pageOf(page, conversations, func(item model.ConversationListItem) (time.Time, uint) {
+		if page.Sort == "updated_at" {
+			return item.UpdatedAt, item.ID
+		}
+		return item.CreatedAt, item.ID
+	})
+	return conversations, next, nil
 }
 
-// GetConversationByUUID retrieves a conversation by its UUID
+// GetConversationByUUID retrieves a conversation by its UUID unless it was
+// deleted
 func (r *ConversationRepository) GetConversationByUUID(uuid string) (*model.Conversation, error) {
 	row := r.DB.QueryRow(
-		"SELECT id, user_id, conversation_id, chat_history, created_at, updated_at FROM conversations WHERE conversation_id = $1",
+		"SELECT "+conversationColumns+" FROM conversations c LEFT JOIN prompts p ON p.id = c.prompt_id WHERE c.conversation_id = $1 AND c.deleted_at IS NULL",
 		uuid,
 	)
 
-	var conversation model.Conversation
-	var chatHistory string
-
-	err := row.Scan(&conversation.ID, &conversation.UserID, &conversation.ConversationID, &chatHistory, &conversation.CreatedAt, &conversation.UpdatedAt)
+	conversation, err := scanConversation(row)
 	if err == sql.ErrNoRows {
 		return nil, nil // No existing conversation
 	}
@@ -74,51 +171,512 @@ func (r *ConversationRepository) GetConversationByUUID(uuid string) (*model.Conv
 		return nil, err
 	}
 
-	// Unmarshal JSON chat history
-	err = json.Unmarshal([]byte(chatHistory), &conversation.ChatHistory)
+	// Conversations whose active message was deleted continue on the newest one
+	if conversation.ActiveMessageID == 0 {
+		if err := r.GetMessageTree(conversation); err != nil {
+			return nil, err
+		}
+		if len(conversation.Messages) > 0 {
+			conversation.ActiveMessageID = conversation.LatestLeaf(conversation.Messages[0].ID)
+		}
+	}
+
+	// Only the active branch is loaded, the other branches are only needed
+	// to switch between them
+	conversation.ChatHistory, err = getBranch(r.DB, conversation.ID, conversation.ActiveMessageID)
 	if err != nil {
 		return nil, err
 	}
 
-	return &conversation, nil
+	return conversation, nil
 }
 
-// CreateConversation creates a new conversation in the database
+// CreateConversation creates a new conversation and its initial messages, in
+// order on a single branch, in the database
 func (r *ConversationRepository) CreateConversation(conversation *model.Conversation) error {
-	// Marshal chat history to JSON
-	chatHistoryJSON, err := json.Marshal(conversation.ChatHistory)
+	tx, err := r.DB.Begin()
 	if err != nil {
-		fmt.Printf("Error marshaling chat history: %v\n", err)
 		return err
 	}
+	defer tx.Rollback()
+
+	settings, err := json.Marshal(conversation.Settings)
+	if err != nil {
+		return err
+	}
+	if conversation.Tags == nil {
+		conversation.Tags = []string{}
+	}
 
 	// Insert into database
-	err = r.DB.QueryRow(
-		"INSERT INTO conversations (user_id, conversation_id, chat_history) VALUES ($1, $2, $3::JSONB) RETURNING id, created_at, updated_at",
-		conversation.UserID, conversation.ConversationID, chatHistoryJSON,
-	).Scan(&conversation.ID, &conversation.CreatedAt, &conversation.UpdatedAt)
+	err = tx.QueryRow(
+		`INSERT INTO conversations (user_id, conversation_id, title, tags, settings, prompt_id)
+		VALUES ($1, $2, $3, $4, $5::JSONB, NULLIF($6, 0)) RETURNING id, version, created_at, updated_at`,
+		conversation.UserID, conversation.ConversationID, conversation.Title, pq.Array(conversation.Tags), settings, conversation.PromptID,
+	).Scan(&conversation.ID, &conversation.Version, &conversation.CreatedAt, &conversation.UpdatedAt)
 	if err != nil {
 		fmt.Printf("SQL Error while creating conversation: %v\n", err)
+		return err
+	}
+
+	var parentID uint
+	for i := range conversation.ChatHistory {
+		conversation.ChatHistory[i].ConversationID = conversation.ID
+		conversation.ChatHistory[i].ParentID = parentID
+		if err := insertMessage(tx, &conversation.ChatHistory[i]); err != nil {
+			fmt.Printf("SQL Error while creating message: %v\n", err)
+			return err
+		}
+		parentID = conversation.ChatHistory[i].ID
+	}
+
+	_, err = tx.Exec("UPDATE conversations SET active_message_id = NULLIF($1, 0) WHERE id = $2", parentID, conversation.ID)
+	if err != nil {
+		fmt.Printf("SQL Error while creating conversation: %v\n", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	conversation.ActiveMessageID = parentID
+	return nil
+}
+
+// AppendMessages inserts new messages at the end of the active branch. The
+// write only succeeds if the stored version still matches
+// conversation.Version.
+func (r *ConversationRepository) AppendMessages(conversation *model.Conversation, messages []model.Message) error {
+	return r.appendMessages(conversation, conversation.ActiveMessageID, messages, nil)
+}
+
+// BranchMessages inserts new messages after parentID, starting a new branch
+// that becomes the active one
+func (r *ConversationRepository) BranchMessages(conversation *model.Conversation, parentID uint, messages []model.Message) error {
+	return r.appendMessages(conversation, parentID, messages, nil)
+}
+
+// AnswerMessage appends the reply to a pending user message and marks it
+// answered in the same transaction
+func (r *ConversationRepository) AnswerMessage(conversation *model.Conversation, question *model.Message, messages []model.Message) error {
+	err := r.appendMessages(conversation, question.ID, messages, func(tx *sql.Tx) error {
+		return updateMessageStatus(tx, question, model.MessageAnswered, "")
+	})
+	if err != nil {
+		return err
+	}
+
+	setMessageStatus(conversation, question, model.MessageAnswered, "")
+	return nil
+}
+
+// UpdateMessageStatus changes the status of a user message. It fails with
+// ErrVersionConflict if the stored status or claim no longer matches
+// message, so two requests cannot both retry the same turn and a request
+// whose turn was retried cannot change it.
+func (r *ConversationRepository) UpdateMessageStatus(conversation *model.Conversation, message *model.Message, status, detail string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := updateMessageStatus(tx, message, status, detail); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	setMessageStatus(conversation, message, status, detail)
+	return nil
+}
+
+// updateMessageStatus changes the status if the stored status and claim
+// still match message. Reopening a turn claims it anew.
+func updateMessageStatus(tx *sql.Tx, message *model.Message, status, detail string) error {
+	var claimedAt time.Time
+	err := tx.QueryRow(
+		`UPDATE messages SET status = $1, error = $2,
+			claimed_at = CASE WHEN $1 = 'pending' THEN CURRENT_TIMESTAMP ELSE claimed_at END
+		WHERE id = $3 AND status = $4 AND claimed_at = $5
+		RETURNING claimed_at`,
+		status, detail, message.ID, message.Status, message.ClaimedAt,
+	).Scan(&claimedAt)
+	if err == sql.ErrNoRows {
+		return ErrVersionConflict
+	}
+	if err != nil {
+		fmt.Printf("SQL Error while updating message status: %v\n", err)
+		return err
+	}
+	message.ClaimedAt = claimedAt
+	return nil
+}
+
+// ReopenMessage makes a user message the end of the active branch again and
+// marks it pending, so a new reply can be generated next to the existing
+// ones or a failed or abandoned turn can be retried. It claims the next
+// conversation version, so only one request reopens a turn.
+func (r *ConversationRepository) ReopenMessage(conversation *model.Conversation, question *model.Message) error {
+	err := r.setActiveMessage(conversation, question.ID, func(tx *sql.Tx) error {
+		return updateMessageStatus(tx, question, model.MessagePending, "")
+	})
+	if err != nil {
+		return err
+	}
+
+	setMessageStatus(conversation, question, model.MessagePending, "")
+	return nil
+}
+
+// SwitchBranch activates the branch through messageID, continuing to its
+// newest reply. It needs the message tree loaded by GetMessageTree.
+func (r *ConversationRepository) SwitchBranch(conversation *model.Conversation, messageID uint) error {
+	return r.setActiveMessage(conversation, conversation.LatestLeaf(messageID), nil)
+}
+
+// GetBranch retrieves the messages from the system prompt to messageID, or
+// nil when messageID is not a message of the conversation
+func (r *ConversationRepository) GetBranch(conversation *model.Conversation, messageID uint) ([]model.Message, error) {
+	return getBranch(r.DB, conversation.ID, messageID)
+}
+
+// GetMessageTree loads every message of every branch into
+// conversation.Messages
+func (r *ConversationRepository) GetMessageTree(conversation *model.Conversation) error {
+	messages, err := getMessagesByConversationIDs(r.DB, []uint{conversation.ID})
+	if err != nil {
+		fmt.Printf("SQL Error while fetching messages: %v\n", err)
+		return err
+	}
+	conversation.Messages = messages[conversation.ID]
+	return nil
+}
+
+// setMessageStatus keeps the message and the loaded history in step with
+// the stored status and claim
+func setMessageStatus(conversation *model.Conversation, message *model.Message, status, detail string) {
+	message.Status = status
+	message.Error = detail
+	for _, messages := range [][]model.Message{conversation.ChatHistory, conversation.Messages} {
+		for i := range messages {
+			if messages[i].ID == message.ID {
+				messages[i].Status = status
+				messages[i].Error = detail
+				messages[i].ClaimedAt = message.ClaimedAt
+			}
+		}
+	}
+}
+
+// appendMessages claims the next conversation version, runs the optional
+// update and inserts the messages after parentID in one transaction. The
+// last message becomes the end of the active branch.
+func (r *ConversationRepository) appendMessages(conversation *model.Conversation, parentID uint, messages []model.Message, update func(tx *sql.Tx) error) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version, updatedAt, err := claimVersion(tx, conversation)
+	if err != nil {
+		return err
+	}
+
+	if update != nil {
+		if err := update(tx); err != nil {
+			return err
+		}
+	}
+
+	leafID := parentID
+	for i := range messages {
+		messages[i].ConversationID = conversation.ID
+		messages[i].ParentID = leafID
+		if err := insertMessage(tx, &messages[i]); err != nil {
+			fmt.Printf("SQL Error while appending message: %v\n", err)
+			return err
+		}
+		leafID = messages[i].ID
+	}
+
+	branch, err := getBranch(tx, conversation.ID, leafID)
+	if err != nil {
+		return err
+	}
+	if err := activateBranch(tx, conversation, leafID, branch); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	conversation.Version = version
+	conversation.UpdatedAt = updatedAt
+	if conversation.Messages != nil {
+		conversation.Messages = append(conversation.Messages, messages...)
+	}
+	setActiveBranch(conversation, leafID, branch)
+	return nil
+}
+
+// setActiveMessage claims the next conversation version, runs the optional
+// update and makes leafID the end of the active branch in one transaction
+func (r *ConversationRepository) setActiveMessage(conversation *model.Conversation, leafID uint, update func(tx *sql.Tx) error) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version, updatedAt, err := claimVersion(tx, conversation)
+	if err != nil {
+		return err
+	}
+
+	if update != nil {
+		if err := update(tx); err != nil {
+			return err
+		}
+	}
+
+	branch, err := getBranch(tx, conversation.ID, leafID)
+	if err != nil {
+		return err
+	}
+	if err := activateBranch(tx, conversation, leafID, branch); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	conversation.Version = version
+	conversation.UpdatedAt = updatedAt
+	setActiveBranch(conversation, leafID, branch)
+	return nil
+}
+
+// claimVersion bumps the conversation version, failing with
+// ErrVersionConflict if it no longer matches conversation.Version
+func claimVersion(tx *sql.Tx, conversation *model.Conversation) (int, time.Time, error) {
+	var version int
+	var updatedAt time.Time
+	err := tx.QueryRow(
+		"UPDATE conversations SET version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND version = $2 RETURNING version, updated_at",
+		conversation.ID, conversation.Version,
+	).Scan(&version, &updatedAt)
+	if err == sql.ErrNoRows {
+		return 0, time.Time{}, ErrVersionConflict
+	}
+	if err != nil {
+		fmt.Printf("SQL Error while updating conversation: %v\n", err)
+	}
+	return version, updatedAt, err
+}
+
+// activateBranch stores the end of the active branch. The rolling context
+// summary is dropped when it covers messages of another branch.
+func activateBranch(tx *sql.Tx, conversation *model.Conversation, leafID uint, branch []model.Message) error {
+	summary, summaryMessageID := conversation.ContextSummary, conversation.ContextSummaryMessageID
+	if !branchContains(branch, summaryMessageID) {
+		summary, summaryMessageID = "", 0
+	}
+
+	_, err := tx.Exec(
+		"UPDATE conversations SET active_message_id = NULLIF($1, 0), context_summary = $2, context_summary_message_id = $3 WHERE id = $4",
+		leafID, summary, summaryMessageID, conversation.ID,
+	)
+	if err != nil {
+		fmt.Printf("SQL Error while switching branch: %v\n", err)
 	}
 	return err
 }
 
-// UpdateConversation updates an existing conversation in the database
-func (r *ConversationRepository) UpdateConversation(conversation *model.Conversation) error {
-	// Marshal chat history to JSON
-	chatHistoryJSON, err := json.Marshal(conversation.ChatHistory)
+// setActiveBranch updates the loaded conversation after activateBranch
+func setActiveBranch(conversation *model.Conversation, leafID uint, branch []model.Message) {
+	conversation.ActiveMessageID = leafID
+	conversation.ChatHistory = branch
+	if !branchContains(branch, conversation.ContextSummaryMessageID) {
+		conversation.ContextSummary = ""
+		conversation.ContextSummaryMessageID = 0
+	}
+}
+
+func branchContains(branch []model.Message, messageID uint) bool {
+	if messageID == 0 {
+		return true
+	}
+	for _, message := range branch {
+		if message.ID == messageID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetConversationLocations retrieves the resolved locations of the messages
+// on the active branch of a conversation as a single GeoJSON feature
+// collection
+func (r *ConversationRepository) GetConversationLocations(conversation *model.Conversation) (*geojson.FeatureCollection, error) {
+	ids := make([]int64, len(conversation.ChatHistory))
+	for i, message := range conversation.ChatHistory {
+		ids[i] = int64(message.ID)
+	}
+
+	rows, err := r.DB.Query(
+		"SELECT "+messageColumns+" FROM messages WHERE conversation_id = $1 AND id = ANY($2) AND locations <> '[]'::JSONB ORDER BY id",
+		conversation.ID, pq.Array(ids),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collection := geojson.NewFeatureCollection()
+	collection.ForeignMembers["conversation_id"] = conversation.ConversationID
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		collection.AddMessage(message)
+	}
+	return collection, rows.Err()
+}
+
+// UpdateContextSummary stores the rolling summary of a conversation. It does
+// not change the conversation version since the messages stay untouched.
+func (r *ConversationRepository) UpdateContextSummary(conversation *model.Conversation, summary string, upToMessageID uint) error {
+	_, err := r.DB.Exec(
+		"UPDATE conversations SET context_summary = $1, context_summary_message_id = $2 WHERE id = $3",
+		summary, upToMessageID, conversation.ID,
+	)
 	if err != nil {
-		fmt.Printf("Error marshaling chat history: %v\n", err)
+		fmt.Printf("SQL Error while updating context summary: %v\n", err)
 		return err
 	}
 
-	// Update the conversation in the database
-	_, err = r.DB.Exec(
-		"UPDATE conversations SET chat_history = $1::JSONB, updated_at = CURRENT_TIMESTAMP WHERE conversation_id = $2",
-		chatHistoryJSON, conversation.ConversationID,
+	conversation.ContextSummary = summary
+	conversation.ContextSummaryMessageID = upToMessageID
+	return nil
+}
+
+// SetGeneratedTitle stores a generated title and summary unless the
+// conversation already has a title
+func (r *ConversationRepository) SetGeneratedTitle(conversationID uint, title, summary string) error {
+	_, err := r.DB.Exec(
+		"UPDATE conversations SET title = $1, summary = $2 WHERE id = $3 AND title = ''",
+		title, summary, conversationID,
 	)
+	if err != nil {
+		fmt.Printf("SQL Error while setting conversation title: %v\n", err)
+	}
+	return err
+}
+
+// UpdateConversationDetails stores the title, summary, pin, archive flag and
+// tags of a conversation. Like the context summary, details do not change
+// the conversation version.
+func (r *ConversationRepository) UpdateConversationDetails(conversation *model.Conversation) error {
+	if conversation.Tags == nil {
+		conversation.Tags = []string{}
+	}
+	settings, err := json.Marshal(conversation.Settings)
+	if err != nil {
+		return err
+	}
+
+	err = r.DB.QueryRow(
+		`UPDATE conversations SET title = $1, summary = $2, pinned = $3, archived = $4, tags = $5, settings = $6::JSONB, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $7 RETURNING updated_at`,
+		conversation.Title, conversation.Summary, conversation.Pinned, conversation.Archived, pq.Array(conversation.Tags), settings, conversation.ID,
+	).Scan(&conversation.UpdatedAt)
 	if err != nil {
 		fmt.Printf("SQL Error while updating conversation: %v\n", err)
 	}
 	return err
 }
+
+// RecordModelCall stores the usage of a model call made for a conversation
+// outside its turns
+func (r *ConversationRepository) RecordModelCall(call model.ModelCall) error {
+	_, err := r.DB.Exec(
+		`INSERT INTO model_calls (conversation_id, purpose, provider, model, prompt_tokens, completion_tokens, latency_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		call.ConversationID, call.Purpose, call.Provider, call.Model, call.PromptTokens, call.CompletionTokens, call.LatencyMS,
+	)
+	if err != nil {
+		fmt.Printf("SQL Error while recording model call: %v\n", err)
+	}
+	return err
+}
+
+// DeleteConversation hides a conversation until it is restored
+func (r *ConversationRepository) DeleteConversation(conversation *model.Conversation) error {
+	_, err := r.DB.Exec("UPDATE conversations SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1", conversation.ID)
+	if err != nil {
+		fmt.Printf("SQL Error while deleting conversation: %v\n", err)
+	}
+	return err
+}
+
+// RestoreConversation brings back a deleted conversation of the user. It
+// reports false when there is no such deleted conversation.
+func (r *ConversationRepository) RestoreConversation(userID uint, uuid string) (bool, error) {
+	result, err := r.DB.Exec(
+		"UPDATE conversations SET deleted_at = NULL WHERE conversation_id = $1 AND user_id = $2 AND deleted_at IS NOT NULL",
+		uuid, userID,
+	)
+	if err != nil {
+		fmt.Printf("SQL Error while restoring conversation: %v\n", err)
+		return false, err
+	}
+	restored, err := result.RowsAffected()
+	return restored > 0, err
+}
+
+// GetMessages retrieves a page of the active branch of a conversation
+func (r *ConversationRepository) GetMessages(conversation *model.Conversation, page Page) (*model.MessagePage, error) {
+	conditions, order, args, err := page.keyset(messageSorts, "id", []interface{}{conversation.ActiveMessageID, conversation.ID})
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.DB.Query(
+		`WITH RECURSIVE branch AS (
+			SELECT id, parent_id FROM messages WHERE id = $1 AND conversation_id = $2
+			UNION ALL
+			SELECT m.id, m.parent_id FROM messages m JOIN branch b ON m.id = b.parent_id
+		)
+		SELECT `+messageColumns+` FROM messages `+whereClause(append([]string{"id IN (SELECT id FROM branch)"}, conditions...))+" "+order,
+		args...,
+	)
+	if err != nil {
+		fmt.Printf("SQL Error while fetching messages: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []model.Message{}
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := &model.MessagePage{}
+	result.Messages, result.NextCursor = pageOf(page, messages, func(message model.Message) (time.Time, uint) {
+		return message.CreatedAt, message.ID
+	})
+	return result, nil
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+
+	"geoai-app/model"
+	"github.com/google/uuid"
+)
+
+// branchIDs lists the message IDs of a branch with the sibling IDs of each
+func branchIDs(branch []model.Message) [][]uint {
+	var ids [][]uint
+	for _, message := range branch {
+		ids = append(ids, append([]uint{message.ID}, message.SiblingIDs...))
+	}
+	return ids
+}
+
+func TestConversationBranches(t *testing.T) {
+	db := openTestDB(t)
+	userID := createTestUser(t, db)
+	repo := NewConversationRepository(db)
+
+	conversation := &model.Conversation{
+		UserID:         userID,
+		ConversationID: uuid.NewString(),
+		ChatHistory: []model.Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "Where is Paris?"},
+			{Role: "assistant", Content: "In France"},
+		},
+	}
+	if err := repo.CreateConversation(conversation); err != nil {
+		t.Fatal(err)
+	}
+	system, question, reply := conversation.ChatHistory[0], conversation.ChatHistory[1], conversation.ChatHistory[2]
+
+	// Editing the question starts a second branch after the system prompt
+	edit := []model.Message{{Role: "user", Content: "Where is Rome?"}, {Role: "assistant", Content: "In Italy"}}
+	if err := repo.BranchMessages(conversation, system.ID, edit); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]uint{{system.ID}, {edit[0].ID, question.ID, edit[0].ID}, {edit[1].ID}}
+	if got := branchIDs(conversation.ChatHistory); !reflect.DeepEqual(got, want) {
+		t.Errorf("branch after edit = %v, want %v", got, want)
+	}
+
+	// Only the active branch is loaded
+	loaded, err := repo.GetConversationByUUID(conversation.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := branchIDs(loaded.ChatHistory); !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded branch = %v, want %v", got, want)
+	}
+	if loaded.Messages != nil {
+		t.Errorf("loaded %d messages of every branch", len(loaded.Messages))
+	}
+
+	branch, err := repo.GetBranch(loaded, reply.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = [][]uint{{system.ID}, {question.ID, question.ID, edit[0].ID}, {reply.ID}}
+	if got := branchIDs(branch); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetBranch() = %v, want %v", got, want)
+	}
+	if branch, err := repo.GetBranch(loaded, edit[1].ID+1000); err != nil || branch != nil {
+		t.Errorf("GetBranch() of an unknown message = %v, %v, want nil", branch, err)
+	}
+
+	// Switching needs the whole tree
+	if err := repo.GetMessageTree(loaded); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Messages) != 5 {
+		t.Fatalf("tree has %d messages, want 5", len(loaded.Messages))
+	}
+	if err := repo.SwitchBranch(loaded, question.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := branchIDs(loaded.ChatHistory); !reflect.DeepEqual(got, want) {
+		t.Errorf("branch after switching = %v, want %v", got, want)
+	}
+
+	// Replies go to the end of the active branch
+	followUp := []model.Message{{Role: "user", Content: "And Lyon?"}}
+	if err := repo.AppendMessages(loaded, followUp); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := repo.GetConversationByUUID(conversation.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, []uint{followUp[0].ID})
+	if got := branchIDs(reloaded.ChatHistory); !reflect.DeepEqual(got, want) || reloaded.ActiveMessageID != followUp[0].ID {
+		t.Errorf("reloaded branch = %v ending at %d, want %v", got, reloaded.ActiveMessageID, want)
+	}
+}
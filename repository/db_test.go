@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	_ "github.com/lib/pq"
+)
+
+var (
+	migrateOnce sync.Once
+	migrateErr  error
+)
+
+// openTestDB connects to the Postgres database in TEST_DB_URL and applies
+// the migrations, skipping the test when it is not set. Tests create their
+// own users so they can share the database.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DB_URL")
+	if url == "" {
+		t.Skip("TEST_DB_URL is not set")
+	}
+
+	migrateOnce.Do(func() {
+		// The migrate driver closes its connection when done
+		db, err := sql.Open("postgres", url)
+		if err != nil {
+			migrateErr = err
+			return
+		}
+		driver, err := postgres.WithInstance(db, &postgres.Config{})
+		if err != nil {
+			migrateErr = err
+			return
+		}
+		m, err := migrate.NewWithDatabaseInstance("file://../migrations/", "geoaistore", driver)
+		if err != nil {
+			migrateErr = err
+			return
+		}
+		if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+			migrateErr = err
+		}
+		m.Close()
+	})
+	if migrateErr != nil {
+		t.Fatalf("migrating test database: %v", migrateErr)
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// createTestUser adds a user that is deleted with its data when the test ends
+func createTestUser(t *testing.T, db *sql.DB) uint {
+	t.Helper()
+	name := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	var id uint
+	err := db.QueryRow(
+		"INSERT INTO users (username, password, email) VALUES ($1, '', $2) RETURNING id",
+		name, name+"@example.com",
+	).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM users WHERE id = $1", id) })
+	return id
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"geoai-app/model"
+	"github.com/lib/pq"
+)
+
+type GazetteerRepositoryInterface interface {
+	CountPlaces() (int, error)
+	FindPlaceByName(name string) (*model.Place, error)
+	BeginImport() (GazetteerImport, error)
+}
+
+// GazetteerImport replaces the gazetteer in a single transaction, so an
+// interrupted import leaves the tables as they were
+type GazetteerImport interface {
+	ImportPlaces(places []model.Place, names [][]string) error
+	Commit() error
+	Rollback() error
+}
+
+type GazetteerRepository struct {
+	DB *sql.DB
+}
+
+func NewGazetteerRepository(db *sql.DB) GazetteerRepositoryInterface {
+	return &GazetteerRepository{DB: db}
+}
+
+// CountPlaces returns the number of imported gazetteer entries
+func (r *GazetteerRepository) CountPlaces() (int, error) {
+	var count int
+	err := r.DB.QueryRow("SELECT COUNT(*) FROM gazetteer").Scan(&count)
+	return count, err
+}
+
+// FindPlaceByName retrieves the best ranked place for a normalized name.
+// Populated places and administrative areas win over other features, then
+// larger populations win.
+func (r *GazetteerRepository) FindPlaceByName(name string) (*model.Place, error) {
+	row := r.DB.QueryRow(`
+		SELECT g.geoname_id, g.name, g.ascii_name, g.latitude, g.longitude, g.country_code, g.country,
+			g.admin1_code, g.admin1, g.feature_class, g.feature_code, g.population
+		FROM gazetteer_names n
+		JOIN gazetteer g ON g.geoname_id = n.geoname_id
+		WHERE n.name = $1
+		ORDER BY CASE WHEN g.feature_class IN ('P', 'A') THEN 0 ELSE 1 END, g.population DESC, g.geoname_id
+		LIMIT 1
+	`, name)
+
+	var place model.Place
+	err := row.Scan(
+		&place.GeonameID, &place.Name, &place.ASCIIName, &place.Latitude, &place.Longitude, &place.CountryCode, &place.Country,
+		&place.Admin1Code, &place.Admin1, &place.FeatureClass, &place.FeatureCode, &place.Population,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &place, nil
+}
+
+type gazetteerImport struct {
+	tx *sql.Tx
+}
+
+// BeginImport starts replacing the gazetteer, including the leftovers of
+// an earlier import. The tables are locked until the import ends.
+func (r *GazetteerRepository) BeginImport() (GazetteerImport, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("TRUNCATE gazetteer, gazetteer_names"); err != nil {
+		fmt.Printf("SQL Error while clearing gazetteer: %v\n", err)
+		tx.Rollback()
+		return nil, err
+	}
+	return &gazetteerImport{tx: tx}, nil
+}
+
+// ImportPlaces bulk loads places and their normalized lookup names.
+// names[i] holds the lookup names of places[i].
+func (i *gazetteerImport) ImportPlaces(places []model.Place, names [][]string) error {
+	placeStmt, err := i.tx.Prepare(pq.CopyIn("gazetteer",
+		"geoname_id", "name", "ascii_name", "latitude", "longitude", "country_code", "country",
+		"admin1_code", "admin1", "feature_class", "feature_code", "population",
+	))
+	if err != nil {
+		return err
+	}
+	for _, place := range places {
+		_, err := placeStmt.Exec(
+			place.GeonameID, place.Name, place.ASCIIName, place.Latitude, place.Longitude, place.CountryCode, place.Country,
+			place.Admin1Code, place.Admin1, place.FeatureClass, place.FeatureCode, place.Population,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := placeStmt.Exec(); err != nil {
+		return err
+	}
+	if err := placeStmt.Close(); err != nil {
+		return err
+	}
+
+	nameStmt, err := i.tx.Prepare(pq.CopyIn("gazetteer_names", "geoname_id", "name"))
+	if err != nil {
+		return err
+	}
+	for j, place := range places {
+		for _, name := range names[j] {
+			if _, err := nameStmt.Exec(place.GeonameID, name); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := nameStmt.Exec(); err != nil {
+		return err
+	}
+	return nameStmt.Close()
+}
+
+// Commit makes the imported gazetteer visible
+func (i *gazetteerImport) Commit() error {
+	return i.tx.Commit()
+}
+
+// Rollback abandons the import, it does nothing after Commit
+func (i *gazetteerImport) Rollback() error {
+	err := i.tx.Rollback()
+	if err == sql.ErrTxDone {
+		return nil
+	}
+	return err
+}
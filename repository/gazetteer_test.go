@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"testing"
+
+	"geoai-app/model"
+)
+
+func TestGazetteerImport(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewGazetteerRepository(db)
+	t.Cleanup(func() { db.Exec("TRUNCATE gazetteer, gazetteer_names") })
+
+	places := []model.Place{
+		{GeonameID: 2988507, Name: "Paris", ASCIIName: "Paris", Latitude: 48.85341, Longitude: 2.3488, CountryCode: "FR", Country: "France", FeatureClass: "P", FeatureCode: "PPLC", Population: 2138551},
+		{GeonameID: 4717560, Name: "Paris", ASCIIName: "Paris", Latitude: 33.66094, Longitude: -95.55551, CountryCode: "US", FeatureClass: "P", FeatureCode: "PPLA2", Population: 24782},
+		{GeonameID: 2867714, Name: "München", ASCIIName: "Muenchen", Latitude: 48.13743, Longitude: 11.57549, CountryCode: "DE", FeatureClass: "P", FeatureCode: "PPLA", Population: 1260391},
+		{GeonameID: 3001234, Name: "Munich", ASCIIName: "Munich", CountryCode: "DE", FeatureClass: "T", FeatureCode: "MT", Population: 9000000},
+	}
+	names := [][]string{{"paris", "parigi"}, {"paris"}, {"münchen", "muenchen", "munich"}, {"munich"}}
+
+	// An abandoned import leaves the gazetteer as it was
+	gazetteer, err := repo.BeginImport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gazetteer.ImportPlaces(places[:1], names[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := gazetteer.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	gazetteer, err = repo.BeginImport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gazetteer.Rollback()
+	if err := gazetteer.ImportPlaces(places[:2], names[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := gazetteer.ImportPlaces(places[2:], names[2:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := gazetteer.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if count, err := repo.CountPlaces(); err != nil || count != 4 {
+		t.Errorf("CountPlaces() = %d, %v, want 4", count, err)
+	}
+
+	tests := []struct {
+		name string
+		want int64
+	}{
+		{"paris", 2988507},
+		{"parigi", 2988507},
+		{"munich", 2867714},
+		{"atlantis", 0},
+	}
+	for _, tt := range tests {
+		place, err := repo.FindPlaceByName(tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got int64
+		if place != nil {
+			got = place.GeonameID
+		}
+		if got != tt.want {
+			t.Errorf("FindPlaceByName(%q) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	place, _ := repo.FindPlaceByName("muenchen")
+	if place == nil || *place != places[2] {
+		t.Errorf("FindPlaceByName() = %+v, want %+v", place, places[2])
+	}
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"geoai-app/geoanswer"
+	"geoai-app/model"
+	"github.com/lib/pq"
+)
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+const messageColumns = "id, conversation_id, COALESCE(parent_id, 0), COALESCE(forked_from_id, 0), role, content, model, provider, prompt_tokens, completion_tokens, latency_ms, locations, tool_calls, tool_call_id, status, error, created_at, claimed_at"
+
+// scanMessage scans the messageColumns of a row, followed by the extra
+// columns the query selects
+func scanMessage(rows *sql.Rows, extra ...interface{}) (model.Message, error) {
+	var message model.Message
+	var locations, toolCalls []byte
+	dest := []interface{}{
+		&message.ID, &message.ConversationID, &message.ParentID, &message.ForkedFromID, &message.Role, &message.Content, &message.Model,
+		&message.Provider, &message.PromptTokens, &message.CompletionTokens, &message.LatencyMS, &locations,
+		&toolCalls, &message.ToolCallID, &message.Status, &message.Error, &message.CreatedAt, &message.ClaimedAt,
+	}
+	err := rows.Scan(append(dest, extra...)...)
+	if err != nil {
+		return message, err
+	}
+
+	// Unmarshal JSON locations and tool calls
+	if err := json.Unmarshal(locations, &message.Locations); err != nil {
+		return message, err
+	}
+	err = json.Unmarshal(toolCalls, &message.ToolCalls)
+	return message, err
+}
+
+// getMessagesByConversationIDs retrieves the messages of several conversations
+// in insertion order, grouped by conversation ID
+func getMessagesByConversationIDs(q queryer, conversationIDs []uint) (map[uint][]model.Message, error) {
+	ids := make([]int64, len(conversationIDs))
+	for i, id := range conversationIDs {
+		ids[i] = int64(id)
+	}
+
+	rows, err := q.Query(
+		"SELECT "+messageColumns+" FROM messages WHERE conversation_id = ANY($1) ORDER BY conversation_id, id",
+		pq.Array(ids),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make(map[uint][]model.Message)
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages[message.ConversationID] = append(messages[message.ConversationID], message)
+	}
+	return messages, rows.Err()
+}
+
+// getBranch retrieves the messages from the system prompt to leafID, walking
+// up the parents from leafID rather than loading every branch, with their
+// sibling IDs filled in like model.Conversation.Branch. It is empty when
+// leafID is not a message of the conversation.
+func getBranch(q queryer, conversationID, leafID uint) ([]model.Message, error) {
+	// Parents are inserted before their replies, so ID order is branch order
+	rows, err := q.Query(
+		`WITH RECURSIVE branch AS (
+			SELECT id, parent_id FROM messages WHERE id = $1 AND conversation_id = $2
+			UNION ALL
+			SELECT m.id, m.parent_id FROM messages m JOIN branch b ON m.id = b.parent_id
+		)
+		SELECT `+messageColumns+`, ARRAY(
+			SELECT s.id FROM messages s
+			WHERE s.conversation_id = messages.conversation_id AND s.parent_id IS NOT DISTINCT FROM messages.parent_id
+			ORDER BY s.id
+		)
+		FROM messages WHERE id IN (SELECT id FROM branch) ORDER BY id`,
+		leafID, conversationID,
+	)
+	if err != nil {
+		fmt.Printf("SQL Error while fetching branch: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var branch []model.Message
+	for rows.Next() {
+		var siblings []int64
+		message, err := scanMessage(rows, pq.Array(&siblings))
+		if err != nil {
+			return nil, err
+		}
+		if len(siblings) > 1 {
+			for _, id := range siblings {
+				message.SiblingIDs = append(message.SiblingIDs, uint(id))
+			}
+		}
+		branch = append(branch, message)
+	}
+	return branch, rows.Err()
+}
+
+// insertMessage inserts a message after message.ParentID and fills in its ID,
+// creation and claim time
+func insertMessage(q queryer, message *model.Message) error {
+	locations := message.Locations
+	if locations == nil {
+		locations = []model.Location{}
+	}
+	locationsJSON, err := json.Marshal(locations)
+	if err != nil {
+		return err
+	}
+	toolCalls := message.ToolCalls
+	if toolCalls == nil {
+		toolCalls = []model.ToolCall{}
+	}
+	toolCallsJSON, err := json.Marshal(toolCalls)
+	if err != nil {
+		return err
+	}
+	if message.Status == "" {
+		message.Status = model.MessageAnswered
+	}
+
+	return q.QueryRow(
+		`INSERT INTO messages (conversation_id, parent_id, forked_from_id, role, content, model, provider, prompt_tokens, completion_tokens, latency_ms, locations, tool_calls, tool_call_id, status, error, search_text)
+		VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), $4, $5, $6, $7, $8, $9, $10, $11::JSONB, $12::JSONB, $13, $14, $15, $16)
+		RETURNING id, created_at, claimed_at`,
+		message.ConversationID, message.ParentID, message.ForkedFromID, message.Role, message.Content, message.Model,
+		message.Provider, message.PromptTokens, message.CompletionTokens, message.LatencyMS, locationsJSON,
+		toolCallsJSON, message.ToolCallID, message.Status, message.Error, searchText(message),
+	).Scan(&message.ID, &message.CreatedAt, &message.ClaimedAt)
+}
+
+// searchText returns the text of a message indexed for search. Structured
+// answers are indexed by their reply and locations rather than their JSON,
+// system prompts and tool messages are not indexed.
+func searchText(message *model.Message) string {
+	switch message.Role {
+	case "user":
+		return message.Content
+	case "assistant":
+		if answer, err := geoanswer.Parse(message.Content); err == nil {
+			return answer.Message + "\n" + strings.Join(answer.Locations, ", ")
+		}
+		return message.Content
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultPageSize and MaxPageSize bound the rows of a list page
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ErrInvalidPage is returned for unknown sort keys and cursors that do not
+// belong to the requested order
+var ErrInvalidPage = errors.New("invalid page request")
+
+// Page selects one page of a list with keyset pagination. Rows are ordered
+// by the Sort column with the row ID breaking ties, so pages stay stable
+// while rows are added. From (inclusive) and To (exclusive) bound the Sort
+// column when set.
+type Page struct {
+	Limit int
+	// Cursor is the NextCursor of the previous page, empty for the first
+	Cursor string
+	Sort   string
+	Desc   bool
+	From   time.Time
+	To     time.Time
+}
+
+// cursor is the position of the last row of a page
+type cursor struct {
+	Sort  string    `json:"s"`
+	Desc  bool      `json:"d,omitempty"`
+	Value time.Time `json:"v"`
+	ID    uint      `json:"id"`
+}
+
+// keyset returns the conditions selecting the page, to be combined with the
+// list's own conditions, and its ORDER BY and LIMIT clause. sorts maps the
+// supported sort keys to columns. Arguments are appended to args.
+func (p Page) keyset(sorts map[string]string, idColumn string, args []interface{}) ([]string, string, []interface{}, error) {
+	column, ok := sorts[p.Sort]
+	if !ok {
+		return nil, "", nil, fmt.Errorf("%w: unknown sort %q", ErrInvalidPage, p.Sort)
+	}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	var conditions []string
+	if !p.From.IsZero() {
+		conditions = append(conditions, column+" >= "+arg(p.From))
+	}
+	if !p.To.IsZero() {
+		conditions = append(conditions, column+" < "+arg(p.To))
+	}
+
+	direction, operator := "ASC", ">"
+	if p.Desc {
+		direction, operator = "DESC", "<"
+	}
+	if p.Cursor != "" {
+		after, err := decodeCursor(p.Cursor)
+		if err != nil || after.Sort != p.Sort || after.Desc != p.Desc {
+			return nil, "", nil, fmt.Errorf("%w: cursor does not match the sort order", ErrInvalidPage)
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, %s) %s (%s, %s)", column, idColumn, operator, arg(after.Value), arg(after.ID)))
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	order := fmt.Sprintf("ORDER BY %s %s, %s %s LIMIT %s", column, direction, idColumn, direction, arg(p.Limit+1))
+	return conditions, order, args, nil
+}
+
+// pageOf trims the extra row fetched by keyset and returns the cursor of the
+// next page, empty on the last page. key returns a row's sort value and ID.
+func pageOf[T any](p Page, rows []T, key func(T) (time.Time, uint)) ([]T, string) {
+	if len(rows) <= p.Limit {
+		return rows, ""
+	}
+
+	rows = rows[:p.Limit]
+	value, id := key(rows[len(rows)-1])
+	data, err := json.Marshal(cursor{Sort: p.Sort, Desc: p.Desc, Value: value, ID: id})
+	if err != nil {
+		return rows, ""
+	}
+	return rows, base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (cursor, error) {
+	var after cursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return after, err
+	}
+	err = json.Unmarshal(data, &after)
+	return after, err
+}
+
+// whereClause joins conditions into a WHERE clause, empty without conditions
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"geoai-app/model"
+	"github.com/lib/pq"
+)
+
+type PromptRepositoryInterface interface {
+	GetActivePrompts() ([]model.Prompt, error)
+	GetActivePrompt(name string) (*model.Prompt, error)
+	GetPromptVersions(name string) ([]model.Prompt, error)
+	CreatePromptVersion(prompt *model.Prompt) error
+	ActivatePromptVersion(name string, version int) (*model.Prompt, error)
+	DeactivatePrompt(name string) (bool, error)
+}
+
+// ErrPromptExists is returned when a persona is created twice, or when two
+// versions of it are created concurrently
+var ErrPromptExists = errors.New("prompt already exists")
+
+type PromptRepository struct {
+	DB *sql.DB
+}
+
+func NewPromptRepository(db *sql.DB) PromptRepositoryInterface {
+	return &PromptRepository{DB: db}
+}
+
+const promptColumns = "id, name, version, description, content, active, created_at"
+
+func scanPrompts(rows *sql.Rows) ([]model.Prompt, error) {
+	defer rows.Close()
+
+	var prompts []model.Prompt
+	for rows.Next() {
+		var prompt model.Prompt
+		err := rows.Scan(&prompt.ID, &prompt.Name, &prompt.Version, &prompt.Description, &prompt.Content, &prompt.Active, &prompt.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		prompts = append(prompts, prompt)
+	}
+	return prompts, rows.Err()
+}
+
+// GetActivePrompts retrieves the active version of every persona
+func (r *PromptRepository) GetActivePrompts() ([]model.Prompt, error) {
+	rows, err := r.DB.Query("SELECT " + promptColumns + " FROM prompts WHERE active ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	return scanPrompts(rows)
+}
+
+// GetActivePrompt retrieves the active version of a persona, nil when it
+// does not exist or was deleted
+func (r *PromptRepository) GetActivePrompt(name string) (*model.Prompt, error) {
+	rows, err := r.DB.Query("SELECT "+promptColumns+" FROM prompts WHERE name = $1 AND active", name)
+	if err != nil {
+		return nil, err
+	}
+	prompts, err := scanPrompts(rows)
+	if err != nil || len(prompts) == 0 {
+		return nil, err
+	}
+	return &prompts[0], nil
+}
+
+// GetPromptVersions retrieves all versions of a persona, newest first
+func (r *PromptRepository) GetPromptVersions(name string) ([]model.Prompt, error) {
+	rows, err := r.DB.Query("SELECT "+promptColumns+" FROM prompts WHERE name = $1 ORDER BY version DESC", name)
+	if err != nil {
+		return nil, err
+	}
+	return scanPrompts(rows)
+}
+
+// CreatePromptVersion stores the prompt as the next version of its persona
+// and makes it the active one. A version of 1 creates the persona and fails
+// with ErrPromptExists if it already exists.
+func (r *PromptRepository) CreatePromptVersion(prompt *model.Prompt) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var latest int
+	err = tx.QueryRow("SELECT COALESCE(MAX(version), 0) FROM prompts WHERE name = $1", prompt.Name).Scan(&latest)
+	if err != nil {
+		return err
+	}
+	if prompt.Version == 1 && latest > 0 {
+		return ErrPromptExists
+	}
+
+	if _, err := tx.Exec("UPDATE prompts SET active = FALSE WHERE name = $1 AND active", prompt.Name); err != nil {
+		fmt.Printf("SQL Error while deactivating prompt: %v\n", err)
+		return err
+	}
+
+	prompt.Version = latest + 1
+	prompt.Active = true
+	err = tx.QueryRow(
+		"INSERT INTO prompts (name, version, description, content, active) VALUES ($1, $2, $3, $4, TRUE) RETURNING id, created_at",
+		prompt.Name, prompt.Version, prompt.Description, prompt.Content,
+	).Scan(&prompt.ID, &prompt.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrPromptExists
+	}
+	if err != nil {
+		fmt.Printf("SQL Error while creating prompt: %v\n", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ActivatePromptVersion makes a version the active one of its persona,
+// returning nil when the version does not exist
+func (r *PromptRepository) ActivatePromptVersion(name string, version int) (*model.Prompt, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE prompts SET active = FALSE WHERE name = $1 AND active AND version <> $2", name, version); err != nil {
+		fmt.Printf("SQL Error while deactivating prompt: %v\n", err)
+		return nil, err
+	}
+	rows, err := tx.Query("UPDATE prompts SET active = TRUE WHERE name = $1 AND version = $2 RETURNING "+promptColumns, name, version)
+	if err != nil {
+		fmt.Printf("SQL Error while activating prompt: %v\n", err)
+		return nil, err
+	}
+	prompts, err := scanPrompts(rows)
+	if err != nil || len(prompts) == 0 {
+		return nil, err
+	}
+
+	return &prompts[0], tx.Commit()
+}
+
+// DeactivatePrompt hides a persona from new conversations. Its versions are
+// kept for the conversations that use them and for rollback.
+func (r *PromptRepository) DeactivatePrompt(name string) (bool, error) {
+	result, err := r.DB.Exec("UPDATE prompts SET active = FALSE WHERE name = $1 AND active", name)
+	if err != nil {
+		fmt.Printf("SQL Error while deactivating prompt: %v\n", err)
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	return updated > 0, err
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
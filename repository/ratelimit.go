@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"geoai-app/model"
+	"github.com/lib/pq"
+)
+
+type RateLimitRepositoryInterface interface {
+	TakeToken(key string, capacity int, perSecond float64, now time.Time) (float64, bool, error)
+	GetTokenUsage(userID uint, periods []string) ([]int, error)
+	AddTokenUsage(userID uint, periods []string, tokens int) error
+	GetUserQuota(userID uint) (*model.UserQuota, error)
+	GetUserQuotas() ([]model.UserQuota, error)
+	PruneBuckets(before time.Time) (int64, error)
+	PruneTokenUsage(keep []string) (int64, error)
+}
+
+type RateLimitRepository struct {
+	DB *sql.DB
+}
+
+func NewRateLimitRepository(db *sql.DB) RateLimitRepositoryInterface {
+	return &RateLimitRepository{DB: db}
+}
+
+// TakeToken refills the token bucket under key up to capacity at perSecond
+// tokens per second and removes one token if available. It returns the
+// tokens left and whether one was taken.
+func (r *RateLimitRepository) TakeToken(key string, capacity int, perSecond float64, now time.Time) (float64, bool, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	// New buckets start full, existing ones refill for the time elapsed
+	var tokens float64
+	err = tx.QueryRow(
+		`INSERT INTO rate_limit_buckets AS b (key, tokens, updated_at) VALUES ($1, $2, $4)
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = LEAST($2, b.tokens + GREATEST(0, EXTRACT(EPOCH FROM ($4 - b.updated_at))) * $3),
+			updated_at = GREATEST(b.updated_at, $4)
+		RETURNING tokens`,
+		key, capacity, perSecond, now,
+	).Scan(&tokens)
+	if err != nil {
+		fmt.Printf("SQL Error while refilling rate limit bucket: %v\n", err)
+		return 0, false, err
+	}
+
+	taken := tokens >= 1
+	if taken {
+		tokens--
+		_, err = tx.Exec("UPDATE rate_limit_buckets SET tokens = $1 WHERE key = $2", tokens, key)
+		if err != nil {
+			fmt.Printf("SQL Error while taking rate limit token: %v\n", err)
+			return 0, false, err
+		}
+	}
+
+	return tokens, taken, tx.Commit()
+}
+
+// PruneBuckets deletes the buckets last used before the given time. A
+// bucket refills within a minute, so older buckets are full and the same
+// as a new one.
+func (r *RateLimitRepository) PruneBuckets(before time.Time) (int64, error) {
+	result, err := r.DB.Exec("DELETE FROM rate_limit_buckets WHERE updated_at < $1", before)
+	if err != nil {
+		fmt.Printf("SQL Error while pruning rate limit buckets: %v\n", err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetTokenUsage returns the LLM tokens a user spent in each period
+func (r *RateLimitRepository) GetTokenUsage(userID uint, periods []string) ([]int, error) {
+	rows, err := r.DB.Query(
+		"SELECT period, tokens FROM token_usage WHERE user_id = $1 AND period = ANY($2)",
+		userID, pq.Array(periods),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byPeriod := make(map[string]int)
+	for rows.Next() {
+		var period string
+		var tokens int
+		if err := rows.Scan(&period, &tokens); err != nil {
+			return nil, err
+		}
+		byPeriod[period] = tokens
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	usage := make([]int, len(periods))
+	for i, period := range periods {
+		usage[i] = byPeriod[period]
+	}
+	return usage, nil
+}
+
+// AddTokenUsage adds the tokens to each period of a user
+func (r *RateLimitRepository) AddTokenUsage(userID uint, periods []string, tokens int) error {
+	_, err := r.DB.Exec(
+		`INSERT INTO token_usage (user_id, period, tokens)
+		SELECT $1, period, $3 FROM UNNEST($2::TEXT[]) AS period
+		ON CONFLICT (user_id, period) DO UPDATE SET tokens = token_usage.tokens + EXCLUDED.tokens`,
+		userID, pq.Array(periods), tokens,
+	)
+	if err != nil {
+		fmt.Printf("SQL Error while adding token usage: %v\n", err)
+	}
+	return err
+}
+
+// GetUserQuota retrieves the quota overrides of a user, nil when there are none
+func (r *RateLimitRepository) GetUserQuota(userID uint) (*model.UserQuota, error) {
+	var requestsPerMinute, tokensPerDay, tokensPerMonth sql.NullInt64
+	err := r.DB.QueryRow(
+		"SELECT requests_per_minute, tokens_per_day, tokens_per_month FROM user_quotas WHERE user_id = $1",
+		userID,
+	).Scan(&requestsPerMinute, &tokensPerDay, &tokensPerMonth)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.UserQuota{
+		UserID:            userID,
+		RequestsPerMinute: nullIntPointer(requestsPerMinute),
+		TokensPerDay:      nullIntPointer(tokensPerDay),
+		TokensPerMonth:    nullIntPointer(tokensPerMonth),
+	}, nil
+}
+
+// PruneTokenUsage deletes the token usage of every period but keep
+func (r *RateLimitRepository) PruneTokenUsage(keep []string) (int64, error) {
+	result, err := r.DB.Exec("DELETE FROM token_usage WHERE period <> ALL($1)", pq.Array(keep))
+	if err != nil {
+		fmt.Printf("SQL Error while pruning token usage: %v\n", err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetUserQuotas retrieves the quota overrides of every user
+func (r *RateLimitRepository) GetUserQuotas() ([]model.UserQuota, error) {
+	rows, err := r.DB.Query("SELECT user_id, requests_per_minute, tokens_per_day, tokens_per_month FROM user_quotas")
+	if err != nil {
+		fmt.Printf("SQL Error while fetching user quotas: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quotas []model.UserQuota
+	for rows.Next() {
+		var userID uint
+		var requestsPerMinute, tokensPerDay, tokensPerMonth sql.NullInt64
+		if err := rows.Scan(&userID, &requestsPerMinute, &tokensPerDay, &tokensPerMonth); err != nil {
+			return nil, err
+		}
+		quotas = append(quotas, model.UserQuota{
+			UserID:            userID,
+			RequestsPerMinute: nullIntPointer(requestsPerMinute),
+			TokensPerDay:      nullIntPointer(tokensPerDay),
+			TokensPerMonth:    nullIntPointer(tokensPerMonth),
+		})
+	}
+	return quotas, rows.Err()
+}
+
+func nullIntPointer(value sql.NullInt64) *int {
+	if !value.Valid {
+		return nil
+	}
+	v := int(value.Int64)
+	return &v
+}
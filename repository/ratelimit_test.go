@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTakeToken(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewRateLimitRepository(db)
+	key := fmt.Sprintf("test:%d", time.Now().UnixNano())
+	t.Cleanup(func() { db.Exec("DELETE FROM rate_limit_buckets WHERE key = $1", key) })
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		after     time.Duration
+		wantLeft  float64
+		wantTaken bool
+	}{
+		{0, 1, true},                   // new buckets start full
+		{0, 0, true},                   // and lose a token per take
+		{0, 0, false},                  // until they are empty
+		{15 * time.Second, 0.5, false}, // refilling at capacity per minute
+		{15 * time.Second, 0, true},
+		{-time.Minute, 0, false}, // time going back does not refill
+		{time.Hour, 1, true},     // nor past the capacity
+	}
+	now := start
+	for i, step := range steps {
+		now = now.Add(step.after)
+		left, taken, err := repo.TakeToken(key, 2, 2.0/60, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if taken != step.wantTaken || left < step.wantLeft-1e-6 || left > step.wantLeft+1e-6 {
+			t.Errorf("step %d: %.3f left, taken %v, want %.3f, %v", i, left, taken, step.wantLeft, step.wantTaken)
+		}
+	}
+
+	// Buckets idle for a minute are full and pruned
+	pruned, err := repo.PruneBuckets(now.Add(time.Minute))
+	if err != nil || pruned < 1 {
+		t.Fatalf("pruned %d buckets, %v, want the idle one", pruned, err)
+	}
+	if left, _, _ := repo.TakeToken(key, 2, 2.0/60, now.Add(time.Minute)); left != 1 {
+		t.Errorf("%.3f left after pruning, want a new full bucket", left)
+	}
+}
+
+func TestTokenUsage(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewRateLimitRepository(db)
+	userID := createTestUser(t, db)
+
+	if err := repo.AddTokenUsage(userID, []string{"day:2024-05-31", "month:2024-05"}, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AddTokenUsage(userID, []string{"day:2024-06-01", "month:2024-06"}, 30); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AddTokenUsage(userID, []string{"day:2024-06-01", "month:2024-06"}, 20); err != nil {
+		t.Fatal(err)
+	}
+
+	periods := []string{"day:2024-05-31", "month:2024-05", "day:2024-06-01", "month:2024-06", "day:2024-06-02"}
+	usage, err := repo.GetTokenUsage(userID, periods)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{100, 100, 50, 50, 0}; fmt.Sprint(usage) != fmt.Sprint(want) {
+		t.Errorf("usage = %v, want %v", usage, want)
+	}
+
+	if _, err := repo.PruneTokenUsage([]string{"day:2024-06-01", "month:2024-06"}); err != nil {
+		t.Fatal(err)
+	}
+	usage, err = repo.GetTokenUsage(userID, periods)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{0, 0, 50, 50, 0}; fmt.Sprint(usage) != fmt.Sprint(want) {
+		t.Errorf("usage after pruning = %v, want %v", usage, want)
+	}
+}
+
+func TestUserQuotas(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewRateLimitRepository(db)
+	userID := createTestUser(t, db)
+
+	if quota, err := repo.GetUserQuota(userID); err != nil || quota != nil {
+		t.Fatalf("quota %+v, %v, want none", quota, err)
+	}
+	if _, err := db.Exec("INSERT INTO user_quotas (user_id, tokens_per_day) VALUES ($1, 0)", userID); err != nil {
+		t.Fatal(err)
+	}
+
+	quota, err := repo.GetUserQuota(userID)
+	if err != nil || quota == nil || quota.RequestsPerMinute != nil || quota.TokensPerDay == nil || *quota.TokensPerDay != 0 {
+		t.Fatalf("quota %+v, %v, want only tokens_per_day set to 0", quota, err)
+	}
+
+	quotas, err := repo.GetUserQuotas()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, q := range quotas {
+		found = found || q.UserID == userID
+	}
+	if !found {
+		t.Errorf("user %d missing from %+v", userID, quotas)
+	}
+}
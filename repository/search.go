@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+
+	"geoai-app/model"
+)
+
+type SearchRepositoryInterface interface {
+	Search(userID uint, query string, limit int) ([]model.SearchResult, error)
+	BackfillSearchText() (int, error)
+}
+
+type SearchRepository struct {
+	DB *sql.DB
+}
+
+func NewSearchRepository(db *sql.DB) SearchRepositoryInterface {
+	return &SearchRepository{DB: db}
+}
+
+// matchesPerResult caps the messages returned per conversation
+const matchesPerResult = 3
+
+// Placeholders ts_headline puts around matched words. Snippets are HTML
+// escaped before they are replaced with <mark> tags.
+const (
+	headlineStart = "\uE000"
+	headlineStop  = "\uE001"
+)
+
+// headlineOptions configures the ts_headline snippets
+const headlineOptions = "StartSel=" + headlineStart + ", StopSel=" + headlineStop + ", MinWords=10, MaxWords=30, MaxFragments=2, FragmentDelimiter=\" … \""
+
+// backfillBatchSize is the number of messages reindexed per query
+const backfillBatchSize = 500
+
+// Search finds the conversations of a user whose messages, title or summary
+// match a web search style query, best matches first. Deleted conversations
+// are left out.
+func (r *SearchRepository) Search(userID uint, query string, limit int) ([]model.SearchResult, error) {
+	rows, err := r.DB.Query(
+		`WITH query AS (SELECT websearch_to_tsquery('english', $2) AS q),
+		hits AS (
+			SELECT m.conversation_id, m.id, m.role, m.search_text, m.created_at, ts_rank(m.search_vector, query.q) AS rank,
+				ROW_NUMBER() OVER (PARTITION BY m.conversation_id ORDER BY ts_rank(m.search_vector, query.q) DESC, m.id) AS position
+			FROM messages m JOIN conversations c ON c.id = m.conversation_id, query
+			WHERE c.user_id = $1 AND c.deleted_at IS NULL AND m.search_vector @@ query.q
+		),
+		ranked AS (
+			SELECT conversation_id, MAX(rank) AS rank FROM (
+				SELECT conversation_id, rank FROM hits
+				UNION ALL
+				SELECT c.id, ts_rank(c.search_vector, query.q) FROM conversations c, query
+				WHERE c.user_id = $1 AND c.deleted_at IS NULL AND c.search_vector @@ query.q
+			) matches
+			GROUP BY conversation_id
+			ORDER BY rank DESC, conversation_id DESC
+			LIMIT $3
+		)
+		SELECT c.conversation_id, c.title,
+			CASE WHEN c.search_vector @@ query.q THEN ts_headline('english', c.title || ' ' || c.summary, query.q, $4) ELSE '' END,
+			ranked.rank, COALESCE(h.id, 0), COALESCE(h.role, ''),
+			COALESCE(ts_headline('english', h.search_text, query.q, $4), ''), h.created_at
+		FROM ranked
+		JOIN conversations c ON c.id = ranked.conversation_id
+		CROSS JOIN query
+		LEFT JOIN hits h ON h.conversation_id = ranked.conversation_id AND h.position <= $5
+		ORDER BY ranked.rank DESC, ranked.conversation_id DESC, h.position`,
+		userID, query, limit, headlineOptions, matchesPerResult,
+	)
+	if err != nil {
+		fmt.Printf("SQL Error while searching conversations: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []model.SearchResult{}
+	for rows.Next() {
+		var result model.SearchResult
+		var match model.SearchMatch
+		var createdAt sql.NullTime
+		err := rows.Scan(
+			&result.ConversationID, &result.Title, &result.TitleHighlight, &result.Rank,
+			&match.MessageID, &match.Role, &match.Snippet, &createdAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		result.TitleHighlight = highlight(result.TitleHighlight)
+		match.Snippet = highlight(match.Snippet)
+
+		// Rows come grouped by conversation, one per matching message
+		if len(results) == 0 || results[len(results)-1].ConversationID != result.ConversationID {
+			result.Matches = []model.SearchMatch{}
+			results = append(results, result)
+		}
+		if match.MessageID != 0 {
+			match.CreatedAt = createdAt.Time
+			last := &results[len(results)-1]
+			last.Matches = append(last.Matches, match)
+		}
+	}
+	return results, rows.Err()
+}
+
+// highlight escapes a ts_headline snippet of user or model text for HTML
+// and marks the matched words with <mark> tags
+func highlight(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	return strings.NewReplacer(headlineStart, "<mark>", headlineStop, "</mark>").Replace(escaped)
+}
+
+// BackfillSearchText reindexes assistant messages still indexed by their
+// stored content, which for structured answers is the JSON envelope, and
+// returns how many were changed
+func (r *SearchRepository) BackfillSearchText() (int, error) {
+	updated := 0
+	var lastID uint
+	for {
+		rows, err := r.DB.Query(
+			`SELECT id, role, content FROM messages
+			WHERE role = 'assistant' AND search_text = content AND content <> '' AND id > $1
+			ORDER BY id LIMIT $2`,
+			lastID, backfillBatchSize,
+		)
+		if err != nil {
+			fmt.Printf("SQL Error while reading messages to reindex: %v\n", err)
+			return updated, err
+		}
+		var messages []model.Message
+		for rows.Next() {
+			var message model.Message
+			if err := rows.Scan(&message.ID, &message.Role, &message.Content); err != nil {
+				rows.Close()
+				return updated, err
+			}
+			messages = append(messages, message)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return updated, err
+		}
+		if len(messages) == 0 {
+			return updated, nil
+		}
+
+		for _, message := range messages {
+			text := searchText(&message)
+			if text == message.Content {
+				continue
+			}
+			if _, err := r.DB.Exec("UPDATE messages SET search_text = $1 WHERE id = $2", text, message.ID); err != nil {
+				fmt.Printf("SQL Error while reindexing message: %v\n", err)
+				return updated, err
+			}
+			updated++
+		}
+		lastID = messages[len(messages)-1].ID
+	}
+}
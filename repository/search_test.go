@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+
+	"geoai-app/model"
+	"github.com/google/uuid"
+)
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		snippet string
+		want    string
+	}{
+		{"flood zones", "flood zones"},
+		{headlineStart + "flood" + headlineStop + " zones", "<mark>flood</mark> zones"},
+		{`<script>alert("x")</script> ` + headlineStart + "Jakarta" + headlineStop, `&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; <mark>Jakarta</mark>`},
+		{"a < b & " + headlineStart + "c" + headlineStop, "a &lt; b &amp; <mark>c</mark>"},
+	}
+	for _, tt := range tests {
+		if got := highlight(tt.snippet); got != tt.want {
+			t.Errorf("highlight(%q) = %q, want %q", tt.snippet, got, tt.want)
+		}
+	}
+}
+
+// createSearchConversation stores a conversation with a user question and a
+// structured answer
+func createSearchConversation(t *testing.T, repo ConversationRepositoryInterface, userID uint, title, question, answer string) *model.Conversation {
+	t.Helper()
+	conversation := &model.Conversation{
+		UserID:         userID,
+		ConversationID: uuid.NewString(),
+		Title:          title,
+		ChatHistory: []model.Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: question},
+			{Role: "assistant", Content: answer},
+		},
+	}
+	if err := repo.CreateConversation(conversation); err != nil {
+		t.Fatal(err)
+	}
+	return conversation
+}
+
+func TestSearch(t *testing.T) {
+	db := openTestDB(t)
+	userID := createTestUser(t, db)
+	otherID := createTestUser(t, db)
+	conversations := NewConversationRepository(db)
+	repo := NewSearchRepository(db)
+
+	flood := createSearchConversation(t, conversations, userID, "",
+		`Which <script>alert(1)</script> districts of Jakarta flood?`,
+		`{"messages": "North Jakarta floods most often.", "locations": ["North Jakarta"]}`)
+	trip := createSearchConversation(t, conversations, userID, "Weekend trip",
+		"Where should I stop?", `{"messages": "Try Koblenz.", "locations": ["Koblenz"]}`)
+	if _, err := db.Exec("UPDATE conversations SET summary = 'Cycling along the Rhine' WHERE id = $1", trip.ID); err != nil {
+		t.Fatal(err)
+	}
+	createSearchConversation(t, conversations, otherID, "", "Does Jakarta flood?", `{"messages": "Yes.", "locations": []}`)
+
+	t.Run("escapes matched messages", func(t *testing.T) {
+		results, err := repo.Search(userID, "jakarta", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].ConversationID != flood.ConversationID {
+			t.Fatalf("results = %+v, want only the user's flood conversation", results)
+		}
+		for _, match := range results[0].Matches {
+			if strings.Contains(match.Snippet, "<script>") || !strings.Contains(match.Snippet, "<mark>Jakarta</mark>") {
+				t.Errorf("snippet %q is not escaped and marked", match.Snippet)
+			}
+		}
+	})
+
+	t.Run("indexes the reply text rather than its JSON", func(t *testing.T) {
+		results, err := repo.Search(userID, "locations", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 0 {
+			t.Errorf("results = %+v, want none for a JSON key", results)
+		}
+	})
+
+	t.Run("highlights summary matches", func(t *testing.T) {
+		results, err := repo.Search(userID, "rhine", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || !strings.Contains(results[0].TitleHighlight, "<mark>Rhine</mark>") {
+			t.Fatalf("results = %+v, want the summary highlighted", results)
+		}
+	})
+}
+
+func TestBackfillSearchText(t *testing.T) {
+	db := openTestDB(t)
+	userID := createTestUser(t, db)
+	conversation := createSearchConversation(t, NewConversationRepository(db), userID, "",
+		"Is Bandung cooler?", `{"messages": "Bandung is cooler than Jakarta.", "locations": ["Bandung"]}`)
+
+	// Replies stored before search was added were indexed as their JSON
+	answerID := conversation.ChatHistory[2].ID
+	if _, err := db.Exec("UPDATE messages SET search_text = content WHERE id = $1", answerID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewSearchRepository(db).BackfillSearchText(); err != nil {
+		t.Fatal(err)
+	}
+	var text string
+	if err := db.QueryRow("SELECT search_text FROM messages WHERE id = $1", answerID).Scan(&text); err != nil {
+		t.Fatal(err)
+	}
+	if text != "Bandung is cooler than Jakarta.\nBandung" {
+		t.Errorf("search_text = %q, want the reply text and locations", text)
+	}
+}
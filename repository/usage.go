@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"geoai-app/model"
+)
+
+type UsageRepositoryInterface interface {
+	GetUsage(userID uint, from, to time.Time, groupBy string) ([]model.UsageRow, error)
+}
+
+type UsageRepository struct {
+	DB *sql.DB
+}
+
+func NewUsageRepository(db *sql.DB) UsageRepositoryInterface {
+	return &UsageRepository{DB: db}
+}
+
+// usageGroups maps the supported groupings to their SQL expressions
+var usageGroups = map[string]string{
+	"day":   "TO_CHAR(m.created_at, 'YYYY-MM-DD')",
+	"model": "m.model",
+}
+
+// GetUsage aggregates the model calls of a user between from (inclusive)
+// and to (exclusive) per group and model: the replies of every turn and the
+// calls writing titles and history summaries
+func (r *UsageRepository) GetUsage(userID uint, from, to time.Time, groupBy string) ([]model.UsageRow, error) {
+	group, ok := usageGroups[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown usage grouping %q", groupBy)
+	}
+
+	rows, err := r.DB.Query(
+		`SELECT `+group+` AS usage_group, m.model, COUNT(*), SUM(m.prompt_tokens), SUM(m.completion_tokens), SUM(m.latency_ms)
+		FROM (
+			SELECT m.created_at, m.model, m.prompt_tokens, m.completion_tokens, m.latency_ms
+			FROM messages m
+			JOIN conversations c ON c.id = m.conversation_id
+			WHERE c.user_id = $1 AND m.role = 'assistant' AND m.model <> '' AND m.provider <> 'cache' AND m.forked_from_id IS NULL AND m.created_at >= $2 AND m.created_at < $3
+			UNION ALL
+			SELECT mc.created_at, mc.model, mc.prompt_tokens, mc.completion_tokens, mc.latency_ms
+			FROM model_calls mc
+			JOIN conversations c ON c.id = mc.conversation_id
+			WHERE c.user_id = $1 AND mc.model <> '' AND mc.created_at >= $2 AND mc.created_at < $3
+		) m
+		GROUP BY usage_group, m.model
+		ORDER BY usage_group, m.model`,
+		userID, from, to,
+	)
+	if err != nil {
+		fmt.Printf("SQL Error while aggregating usage: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []model.UsageRow
+	for rows.Next() {
+		var row model.UsageRow
+		err := rows.Scan(&row.Group, &row.Model, &row.Calls, &row.PromptTokens, &row.CompletionTokens, &row.LatencyMS)
+		if err != nil {
+			return nil, err
+		}
+		usage = append(usage, row)
+	}
+	return usage, rows.Err()
+}
@@ -2,11 +2,14 @@ package repository
 
 import (
 	"database/sql"
+	"fmt"
+	"time"
+
 	"geoai-app/model"
 )
 
 type UserRepositoryInterface interface {
-	GetAllUsers() ([]model.User, error)
+	GetAllUsers(page Page) ([]model.User, string, error)
 	GetUserByID(ID string) (*model.User, error)
 	CreateUser(user *model.User) error
 }
@@ -19,24 +22,46 @@ func NewUserRepository(db *sql.DB) UserRepositoryInterface {
 	return &UserRepository{DB: db}
 }
 
-// GetAllUsers retrieves all users from the database
-func (r *UserRepository) GetAllUsers() ([]model.User, error) {
-	rows, err := r.DB.Query("SELECT id, username, email, created_at, updated_at FROM users")
+// userSorts are the sort keys of user lists
+var userSorts = map[string]string{
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// GetAllUsers retrieves a page of users from the database along with the
+// cursor of the next page
+func (r *UserRepository) GetAllUsers(page Page) ([]model.User, string, error) {
+	conditions, order, args, err := page.keyset(userSorts, "id", nil)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	rows, err := r.DB.Query("SELECT id, username, email, created_at, updated_at FROM users "+whereClause(conditions)+" "+order, args...)
+	if err != nil {
+		fmt.Printf("SQL Error while listing users: %v\n", err)
+		return nil, "", err
 	}
 	defer rows.Close()
 
-	var users []model.User
+	users := []model.User{}
 	for rows.Next() {
 		var user model.User
 		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.UpdatedAt)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		users = append(users, user)
 	}
-	return users, nil
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	users, next := pageOf(page, users, func(user model.User) (time.Time, uint) {
+		if page.Sort == "updated_at" {
+			return user.UpdatedAt, user.ID
+		}
+		return user.CreatedAt, user.ID
+	})
+	return users, next, nil
 }
 
 // GetUserByID retrieves a user by their ID
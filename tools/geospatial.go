@@ -0,0 +1,251 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"geoai-app/geo"
+	"geoai-app/geocode"
+)
+
+// pointArg is a tool argument given as a place name or as coordinates
+type pointArg struct {
+	Name      string   `json:"name"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+const pointSchema = `{
+	"type": "object",
+	"description": "A place name or a latitude/longitude pair",
+	"properties": {
+		"name": {"type": "string", "description": "Place name to geocode"},
+		"latitude": {"type": "number"},
+		"longitude": {"type": "number"}
+	}
+}`
+
+const polygonSchema = `{
+	"type": "array",
+	"description": "Closed or open ring of [longitude, latitude] positions",
+	"items": {"type": "array", "items": {"type": "number"}, "minItems": 2, "maxItems": 2},
+	"minItems": 3
+}`
+
+// geospatial implements the geospatial tools
+type geospatial struct {
+	geocoder geocode.Geocoder
+}
+
+// GeospatialTools returns the geospatial tools. Place names in arguments are
+// only accepted when a geocoder is configured.
+func GeospatialTools(geocoder geocode.Geocoder) []Tool {
+	g := &geospatial{geocoder: geocoder}
+	tools := []Tool{
+		{
+			Name:        "distance",
+			Description: "Geodesic distance in kilometres between two places",
+			Parameters:  json.RawMessage(`{"type": "object", "properties": {"from": ` + pointSchema + `, "to": ` + pointSchema + `}, "required": ["from", "to"]}`),
+			Call:        g.distance,
+		},
+		{
+			Name:        "bearing",
+			Description: "Initial compass bearing in degrees from one place to another",
+			Parameters:  json.RawMessage(`{"type": "object", "properties": {"from": ` + pointSchema + `, "to": ` + pointSchema + `}, "required": ["from", "to"]}`),
+			Call:        g.bearing,
+		},
+		{
+			Name:        "polygon_area",
+			Description: "Area in square kilometres and perimeter in kilometres of a polygon on the earth's surface",
+			Parameters:  json.RawMessage(`{"type": "object", "properties": {"polygon": ` + polygonSchema + `}, "required": ["polygon"]}`),
+			Call:        g.polygonArea,
+		},
+		{
+			Name:        "buffer",
+			Description: "Polygon approximating a circle of the given radius around a place",
+			Parameters:  json.RawMessage(`{"type": "object", "properties": {"center": ` + pointSchema + `, "radius_km": {"type": "number"}, "segments": {"type": "integer", "description": "Number of vertices, defaults to 32"}}, "required": ["center", "radius_km"]}`),
+			Call:        g.buffer,
+		},
+		{
+			Name:        "point_in_polygon",
+			Description: "Whether a place lies inside a polygon, optionally with holes. Points on the boundary are inside.",
+			Parameters:  json.RawMessage(`{"type": "object", "properties": {"point": ` + pointSchema + `, "polygon": ` + polygonSchema + `, "holes": {"type": "array", "description": "Rings cut out of the polygon", "items": ` + polygonSchema + `}}, "required": ["point", "polygon"]}`),
+			Call:        g.pointInPolygon,
+		},
+	}
+
+	if geocoder != nil {
+		tools = append([]Tool{{
+			Name:        "geocode",
+			Description: "Coordinates, country and admin region of a place name",
+			Parameters:  json.RawMessage(`{"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"]}`),
+			Call:        g.geocode,
+		}}, tools...)
+	}
+	return tools
+}
+
+func (g *geospatial) resolve(ctx context.Context, arg pointArg) (geo.Point, error) {
+	if arg.Latitude != nil && arg.Longitude != nil {
+		p := geo.Point{Latitude: *arg.Latitude, Longitude: *arg.Longitude}
+		return p, p.Validate()
+	}
+	if arg.Name == "" {
+		return geo.Point{}, fmt.Errorf("a place name or latitude and longitude are required")
+	}
+	if g.geocoder == nil {
+		return geo.Point{}, fmt.Errorf("geocoding is not available, pass latitude and longitude for %q", arg.Name)
+	}
+
+	place, err := g.geocoder.Geocode(ctx, arg.Name)
+	if err != nil {
+		return geo.Point{}, err
+	}
+	if place == nil {
+		return geo.Point{}, fmt.Errorf("place %q not found", arg.Name)
+	}
+	return geo.Point{Latitude: place.Latitude, Longitude: place.Longitude}, nil
+}
+
+func (g *geospatial) geocode(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+	var args struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+
+	place, err := g.geocoder.Geocode(ctx, args.Name)
+	if err != nil {
+		return nil, err
+	}
+	if place == nil {
+		return nil, fmt.Errorf("place %q not found", args.Name)
+	}
+	return place, nil
+}
+
+func (g *geospatial) fromTo(ctx context.Context, arguments json.RawMessage) (geo.Point, geo.Point, error) {
+	var args struct {
+		From pointArg `json:"from"`
+		To   pointArg `json:"to"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return geo.Point{}, geo.Point{}, err
+	}
+
+	from, err := g.resolve(ctx, args.From)
+	if err != nil {
+		return geo.Point{}, geo.Point{}, err
+	}
+	to, err := g.resolve(ctx, args.To)
+	if err != nil {
+		return geo.Point{}, geo.Point{}, err
+	}
+	return from, to, nil
+}
+
+func (g *geospatial) distance(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+	from, to, err := g.fromTo(ctx, arguments)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"from": from, "to": to, "distance_km": geo.Distance(from, to) / 1000}, nil
+}
+
+func (g *geospatial) bearing(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+	from, to, err := g.fromTo(ctx, arguments)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"from": from, "to": to, "bearing_degrees": geo.InitialBearing(from, to)}, nil
+}
+
+func (g *geospatial) polygonArea(_ context.Context, arguments json.RawMessage) (interface{}, error) {
+	var args struct {
+		Polygon [][2]float64 `json:"polygon"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	ring := toRing(args.Polygon)
+	area, err := geo.Area(ring)
+	if err != nil {
+		return nil, err
+	}
+	perimeter, err := geo.Perimeter(ring)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]float64{"area_km2": area / 1e6, "perimeter_km": perimeter / 1000}, nil
+}
+
+func (g *geospatial) buffer(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+	var args struct {
+		Center   pointArg `json:"center"`
+		RadiusKm float64  `json:"radius_km"`
+		Segments int      `json:"segments"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	if args.RadiusKm <= 0 {
+		return nil, fmt.Errorf("radius_km must be positive")
+	}
+	if args.Segments < 4 || args.Segments > 360 {
+		args.Segments = 32
+	}
+
+	center, err := g.resolve(ctx, args.Center)
+	if err != nil {
+		return nil, err
+	}
+
+	ring, err := geo.Buffer(center, args.RadiusKm*1000, args.Segments)
+	if err != nil {
+		return nil, err
+	}
+	polygon := make([][2]float64, 0, len(ring))
+	for _, p := range ring {
+		polygon = append(polygon, p.LonLat())
+	}
+
+	return map[string]interface{}{"center": center, "radius_km": args.RadiusKm, "polygon": polygon}, nil
+}
+
+func (g *geospatial) pointInPolygon(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+	var args struct {
+		Point   pointArg       `json:"point"`
+		Polygon [][2]float64   `json:"polygon"`
+		Holes   [][][2]float64 `json:"holes"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	if len(args.Polygon) < 3 {
+		return nil, geo.ErrTooFewPoints
+	}
+	holes := make([][]geo.Point, 0, len(args.Holes))
+	for _, hole := range args.Holes {
+		if len(hole) < 3 {
+			return nil, geo.ErrTooFewPoints
+		}
+		holes = append(holes, toRing(hole))
+	}
+
+	p, err := g.resolve(ctx, args.Point)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"point": p, "inside": geo.PointInPolygonWithHoles(p, toRing(args.Polygon), holes...)}, nil
+}
+
+// toRing converts [longitude, latitude] positions to points
+func toRing(positions [][2]float64) []geo.Point {
+	ring := make([]geo.Point, 0, len(positions))
+	for _, position := range positions {
+		ring = append(ring, geo.FromLonLat(position))
+	}
+	return ring
+}
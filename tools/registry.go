@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"geoai-app/llm"
+)
+
+// Tool is a Go function the model can call
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments
+	Parameters json.RawMessage
+	Call       func(ctx context.Context, arguments json.RawMessage) (interface{}, error)
+}
+
+// Registry holds the tools offered to the model
+type Registry struct {
+	tools  []Tool
+	byName map[string]Tool
+}
+
+// NewRegistry creates a new instance of Registry
+func NewRegistry(tools ...Tool) *Registry {
+	r := &Registry{byName: make(map[string]Tool)}
+	for _, tool := range tools {
+		r.Register(tool)
+	}
+	return r
+}
+
+// Register adds a tool, replacing any tool with the same name
+func (r *Registry) Register(tool Tool) {
+	if _, exists := r.byName[tool.Name]; !exists {
+		r.tools = append(r.tools, tool)
+	} else {
+		for i := range r.tools {
+			if r.tools[i].Name == tool.Name {
+				r.tools[i] = tool
+			}
+		}
+	}
+	r.byName[tool.Name] = tool
+}
+
+// Len returns the number of registered tools
+func (r *Registry) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.tools)
+}
+
+// Definitions describes the registered tools for the provider
+func (r *Registry) Definitions() []llm.ToolDefinition {
+	definitions := make([]llm.ToolDefinition, 0, len(r.tools))
+	for _, tool := range r.tools {
+		definitions = append(definitions, llm.ToolDefinition{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	return definitions
+}
+
+// Call runs a tool call and returns its JSON encoded result. Failures are
+// returned as an {"error": ...} result so the model can recover.
+func (r *Registry) Call(ctx context.Context, call llm.ToolCall) string {
+	result, err := r.call(ctx, call)
+	if err != nil {
+		result = map[string]string{"error": err.Error()}
+	}
+
+	content, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Sprintf(`{"error": %q}`, err.Error())
+	}
+	return string(content)
+}
+
+func (r *Registry) call(ctx context.Context, call llm.ToolCall) (interface{}, error) {
+	tool, ok := r.byName[call.Function.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tool %q", call.Function.Name)
+	}
+
+	arguments := json.RawMessage(call.Function.Arguments)
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	if !json.Valid(arguments) {
+		return nil, fmt.Errorf("arguments are not valid JSON")
+	}
+	return tool.Call(ctx, arguments)
+}